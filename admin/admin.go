@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+)
+
+// CheckFunc reports the health of a component.
+// It returns nil error when the component is healthy.
+type CheckFunc func(ctx context.Context) error
+
+// Config is the configuration for the admin handler.
+// Use [New] to create a new admin handler.
+// Endpoints are mounted only when the corresponding field is set.
+type Config struct {
+	// Metrics is the metrics exposition handler
+	// such as [github.com/aileron-projects/aileron-observability/metrics/prom.Metrics].
+	// It is mounted on "/metrics".
+	Metrics http.Handler
+	// ServiceGraph is the handler that responds the service graph edges
+	// such as [github.com/aileron-projects/aileron-observability/tracing/servicegraph.Graph].
+	// It is mounted on "/debug/servicegraph".
+	ServiceGraph http.Handler
+	// Pprof, if true, mounts pprof endpoints on "/debug/pprof/".
+	Pprof bool
+	// Checks is the named health checks reported by "/healthz".
+	// "/healthz" is always mounted and responds 200 OK when
+	// all checks succeeded or 503 Service Unavailable otherwise.
+	Checks map[string]CheckFunc
+	// CheckTimeout is the timeout for running all health checks.
+	// If zero or negative, 5 seconds is used.
+	CheckTimeout time.Duration
+	// LogLevel is the log level variable which can be
+	// read and changed through "/debug/loglevel".
+	LogLevel *slog.LevelVar
+	// Config returns the effective configuration dumped
+	// as JSON by "/debug/config".
+	// Secrets should be masked by the function.
+	Config func() any
+	// Middleware is applied to all endpoints.
+	// Typically an authentication middleware is given.
+	Middleware []zhttp.ServerMiddleware
+}
+
+// New returns a new admin handler.
+// The returned handler is intended to be served on
+// a separate admin listener rather than the application listener.
+//
+// Example:
+//
+//	h := admin.New(&admin.Config{Metrics: m, Pprof: true})
+//	svr := &http.Server{Addr: ":9090", Handler: h}
+//	go svr.ListenAndServe()
+func New(c *Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", &healthHandler{
+		checks:  c.Checks,
+		timeout: c.CheckTimeout,
+	})
+	if c.Metrics != nil {
+		mux.Handle("/metrics", c.Metrics)
+	}
+	if c.ServiceGraph != nil {
+		mux.Handle("/debug/servicegraph", c.ServiceGraph)
+	}
+	if c.Pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	if c.LogLevel != nil {
+		mux.Handle("/debug/loglevel", &logLevelHandler{level: c.LogLevel})
+	}
+	if c.Config != nil {
+		mux.Handle("/debug/config", &configHandler{config: c.Config})
+	}
+	return zhttp.NewHandler(mux, c.Middleware...)
+}
@@ -0,0 +1,640 @@
+package bootstrap
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/admin"
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/logging"
+	"github.com/aileron-projects/aileron-observability/metrics"
+	motel "github.com/aileron-projects/aileron-observability/metrics/otel"
+	"github.com/aileron-projects/aileron-observability/metrics/prom"
+	"github.com/aileron-projects/aileron-observability/recovery"
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/requestid"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/jaeger"
+	totel "github.com/aileron-projects/aileron-observability/tracing/otel"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
+	"github.com/aileron-projects/aileron-observability/tracing/zipkin"
+	"github.com/aileron-projects/go/znet/zhttp"
+	zipkingo "github.com/openzipkin/zipkin-go"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	zipkinlog "github.com/openzipkin/zipkin-go/reporter/log"
+	"github.com/prometheus/client_golang/prometheus"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	_ zhttp.ServerMiddleware = &Observability{}
+	_ zhttp.ClientMiddleware = &Observability{}
+)
+
+// Observability bundles the metrics, tracing, logging and profiling
+// features configured by a single [Config].
+// Use [New] to create a new instance of the Observability.
+type Observability struct {
+	config *Config
+
+	tracer  tracing.TraceMiddleware   // nil if tracing is disabled.
+	metrics metrics.MetricsMiddleware // nil if metrics are disabled.
+	logger  *slog.Logger
+	level   *slog.LevelVar
+
+	// checks is the health checks served by the admin handler.
+	checks map[string]admin.CheckFunc
+	// adminServer is the admin server started by ServeAdmin.
+	// closed is set by Shutdown so that ServeAdmin called
+	// after Shutdown does not start the server.
+	// They are protected by mu.
+	adminServer *http.Server
+	closed      bool
+	mu          sync.Mutex
+
+	// server is the server middleware chain.
+	// Request id comes first so the tracer and the metrics
+	// middleware are called with the request id.
+	// Recovery comes next so panics are recorded by
+	// the tracer and the metrics middleware before recovered.
+	// Tracer comes next so the metrics middleware
+	// is called with the span context.
+	server zhttp.ServerMiddlewareChain
+	// client is the client middleware chain.
+	client zhttp.ClientMiddlewareChain
+}
+
+// New creates a new [Observability] from the c.
+// Components created before an error occurred are finalized
+// when New returns an error.
+func New(ctx context.Context, c *Config) (*Observability, error) {
+	if c == nil {
+		c = &Config{}
+	}
+	o := &Observability{
+		config: c,
+		checks: map[string]admin.CheckFunc{},
+	}
+
+	logger, level, err := newLogger(c)
+	if err != nil {
+		return nil, err
+	}
+	o.logger, o.level = logger, level
+
+	// Export errors are logged instead of being
+	// handled by the global OpenTelemetry error handler.
+	onError := func(err error) {
+		logger.Error("export failed", "error", err)
+	}
+	var rd *redact.Redactor
+	if c.Redaction != nil {
+		if rd, err = redact.New(c.Redaction); err != nil {
+			return nil, fmt.Errorf("bootstrap: invalid redaction: %w", err)
+		}
+	}
+	if o.tracer, err = newTracer(ctx, c, rd, onError); err != nil {
+		return nil, err
+	}
+	if o.metrics, err = newMetrics(ctx, c, rd, onError); err != nil {
+		_ = o.Shutdown(ctx)
+		return nil, err
+	}
+	if err := o.registerMonitors(); err != nil {
+		_ = o.Shutdown(ctx)
+		return nil, err
+	}
+
+	if c.RequestID.Enabled {
+		rid := requestid.New(&requestid.Config{Header: c.RequestID.Header})
+		o.server.Add(rid)
+		o.client.Add(rid)
+	}
+	if c.Recovery.Enabled {
+		o.server.Add(recovery.New(&recovery.Config{RePanic: c.Recovery.RePanic, Logger: logger}))
+	}
+	if o.tracer != nil {
+		o.server.Add(o.tracer)
+		o.client.Add(o.tracer)
+	}
+	if o.metrics != nil {
+		o.server.Add(o.metrics)
+		o.client.Add(o.metrics)
+	}
+
+	if c.Profiling.Enabled {
+		runtime.SetBlockProfileRate(c.Profiling.BlockProfileRate)
+		runtime.SetMutexProfileFraction(c.Profiling.MutexProfileFraction)
+	}
+
+	return o, nil
+}
+
+// registerMonitors registers the exporter monitors of the tracer
+// and the metrics to the metrics backend and the health checks.
+func (o *Observability) registerMonitors() error {
+	type monitored interface{ Monitor() *health.Monitor }
+	type sampled interface{ Sampler() *sampling.Sampler }
+	type spanMetered interface{ SpanMetrics() *spanmetrics.Metrics }
+	type graphed interface{ ServiceGraph() *servicegraph.Graph }
+	type tailSampled interface {
+		TailSampler() *totel.TailSamplingProcessor
+	}
+	if t, ok := o.tracer.(monitored); ok {
+		o.checks["tracer"] = t.Monitor().Check
+		if err := o.registerCollector(t.Monitor()); err != nil {
+			return fmt.Errorf("bootstrap: failed to register tracer monitor: %w", err)
+		}
+	}
+	if t, ok := o.tracer.(sampled); ok && t.Sampler() != nil {
+		if err := o.registerCollector(t.Sampler()); err != nil {
+			return fmt.Errorf("bootstrap: failed to register sampler metrics: %w", err)
+		}
+	}
+	if t, ok := o.tracer.(spanMetered); ok && t.SpanMetrics() != nil {
+		if err := o.registerCollector(t.SpanMetrics()); err != nil {
+			return fmt.Errorf("bootstrap: failed to register span metrics: %w", err)
+		}
+	}
+	if t, ok := o.tracer.(graphed); ok && t.ServiceGraph() != nil {
+		if err := o.registerCollector(t.ServiceGraph()); err != nil {
+			return fmt.Errorf("bootstrap: failed to register service graph metrics: %w", err)
+		}
+	}
+	if t, ok := o.tracer.(tailSampled); ok && t.TailSampler() != nil {
+		if err := o.registerCollector(t.TailSampler()); err != nil {
+			return fmt.Errorf("bootstrap: failed to register tail sampler metrics: %w", err)
+		}
+	}
+	if m, ok := o.metrics.(monitored); ok {
+		o.checks["metrics"] = m.Monitor().Check
+	}
+	return nil
+}
+
+// collector is the internal metrics source which
+// can be registered to both metrics backends.
+type collector interface {
+	prometheus.Collector
+	RegisterMeter(meter metric.Meter) (metric.Registration, error)
+}
+
+// registerCollector registers the c to the configured metrics backend.
+func (o *Observability) registerCollector(c collector) error {
+	switch m := o.metrics.(type) {
+	case *prom.Metrics:
+		return m.Registry().Register(c)
+	case *motel.Metrics:
+		_, err := c.RegisterMeter(m.MeterProvider().Meter(motel.ScopeName))
+		return err
+	}
+	return nil
+}
+
+// Config returns the config used to create o.
+func (o *Observability) Config() *Config {
+	return o.config
+}
+
+// Tracer returns the configured tracer.
+// It returns nil if tracing is disabled.
+// Use [tracing.Noop] in place of nil if needed.
+func (o *Observability) Tracer() tracing.TraceMiddleware {
+	return o.tracer
+}
+
+// Metrics returns the configured metrics middleware.
+// It returns nil if metrics are disabled.
+// Use [metrics.Noop] in place of nil if needed.
+func (o *Observability) Metrics() metrics.MetricsMiddleware {
+	return o.metrics
+}
+
+// Logger returns the configured logger.
+func (o *Observability) Logger() *slog.Logger {
+	return o.logger
+}
+
+// LogLevel returns the level variable of the logger.
+// Log level can be changed at runtime through it.
+func (o *Observability) LogLevel() *slog.LevelVar {
+	return o.level
+}
+
+// AdminHandler returns the admin handler that serves health,
+// log level and config endpoints, and metrics and pprof endpoints if enabled.
+// Given middleware, typically for authentication, are applied to all endpoints.
+// See [admin.New] for the endpoints.
+// It is intended to be served on a separate admin listener.
+func (o *Observability) AdminHandler(ms ...zhttp.ServerMiddleware) http.Handler {
+	ac := &admin.Config{
+		Pprof:      o.config.Profiling.Enabled,
+		Checks:     o.checks,
+		LogLevel:   o.level,
+		Config:     func() any { return o.config.masked() },
+		Middleware: ms,
+	}
+	if h, ok := o.metrics.(http.Handler); ok {
+		ac.Metrics = h
+	}
+	if t, ok := o.tracer.(interface{ ServiceGraph() *servicegraph.Graph }); ok && t.ServiceGraph() != nil {
+		ac.ServiceGraph = t.ServiceGraph()
+	}
+	return admin.New(ac)
+}
+
+// ServeAdmin serves the [Observability.AdminHandler] on the
+// address configured by [AdminConfig.Address].
+// ServeAdmin blocks until the server stops and always
+// returns a non-nil error. [http.ErrServerClosed] is returned
+// after the server was stopped by [Observability.Shutdown]
+// or when called after the shutdown.
+// ServeAdmin must not be called more than once.
+func (o *Observability) ServeAdmin(ms ...zhttp.ServerMiddleware) error {
+	if o.config.Admin.Address == "" {
+		return errors.New("bootstrap: admin address is not configured")
+	}
+	svr := &http.Server{
+		Addr:              o.config.Admin.Address,
+		Handler:           o.AdminHandler(ms...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	o.adminServer = svr
+	o.mu.Unlock()
+	// ListenAndServe returns immediately if
+	// Shutdown was called after the server was set.
+	return svr.ListenAndServe()
+}
+
+// ServerMiddleware applies request id, recovery, tracing and metrics middleware in this order.
+func (o *Observability) ServerMiddleware(next http.Handler) http.Handler {
+	return o.server.ServerMiddleware(next)
+}
+
+// ClientMiddleware applies request id, tracing and metrics middleware in this order.
+func (o *Observability) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	return o.client.ClientMiddleware(next)
+}
+
+// Shutdown stops the admin server if running,
+// and then finalizes the tracer and then the metrics
+// so that the data recorded while flushing spans are exported.
+// Errors are joined with [errors.Join].
+func (o *Observability) Shutdown(ctx context.Context) error {
+	var errs []error
+	o.mu.Lock()
+	svr := o.adminServer
+	o.closed = true
+	o.mu.Unlock()
+	if svr != nil {
+		if err := svr.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("bootstrap: failed to shutdown admin server: %w", err))
+		}
+	}
+	if o.tracer != nil {
+		if err := o.tracer.Finalize(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("bootstrap: failed to finalize tracer: %w", err))
+		}
+	}
+	if o.metrics != nil {
+		if err := o.metrics.Finalize(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("bootstrap: failed to finalize metrics: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newLogger(cfg *Config) (*slog.Logger, *slog.LevelVar, error) {
+	c := &cfg.Logging
+	level := &slog.LevelVar{}
+	if c.Level != "" {
+		if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+			return nil, nil, fmt.Errorf("bootstrap: invalid log level %q: %w", c.Level, err)
+		}
+	}
+	var w io.Writer
+	switch strings.ToLower(c.Output) {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		return nil, nil, fmt.Errorf("bootstrap: unsupported log output %q", c.Output)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(c.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, nil, fmt.Errorf("bootstrap: unsupported log format %q", c.Format)
+	}
+	if len(cfg.BaggageKeys) > 0 || cfg.RequestID.Enabled {
+		h = logging.NewHandler(h, &logging.Config{
+			BaggageKeys: cfg.BaggageKeys,
+			RequestID:   cfg.RequestID.Enabled,
+		})
+	}
+	return slog.New(h), level, nil
+}
+
+func newMetrics(ctx context.Context, c *Config, rd *redact.Redactor, onError health.ErrorHandler) (metrics.MetricsMiddleware, error) {
+	switch strings.ToLower(c.Metrics.Backend) {
+	case "", "none":
+		return nil, nil
+	case "prometheus", "prom":
+		return prom.New(&prom.Config{BaggageKeys: c.BaggageKeys, Redactor: rd, RecordPanics: c.Recovery.Enabled})
+	case "otel", "opentelemetry":
+		mc := &motel.Config{
+			ServiceName:  c.ServiceName,
+			ErrorHandler: onError,
+			BaggageKeys:  c.BaggageKeys,
+			Redactor:     rd,
+			RecordPanics: c.Recovery.Enabled,
+		}
+		if c.Metrics.Interval > 0 {
+			mc.ReaderOpts = append(mc.ReaderOpts, sdkmetric.WithInterval(time.Duration(c.Metrics.Interval)))
+		}
+		ec := c.Metrics.Exporter
+		var exp sdkmetric.Exporter
+		var err error
+		switch strings.ToLower(ec.Type) {
+		case "", "env":
+			if mc, err = motel.FromEnv(ctx, mc); err != nil {
+				return nil, err
+			}
+			return motel.New(mc)
+		case "otlpgrpc":
+			opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithHeaders(ec.Headers)}
+			if ec.Endpoint != "" {
+				opts = append(opts, otlpmetricgrpc.WithEndpoint(ec.Endpoint))
+			}
+			if ec.Insecure {
+				opts = append(opts, otlpmetricgrpc.WithInsecure())
+			}
+			exp, err = otlpmetricgrpc.New(ctx, opts...)
+		case "otlphttp":
+			opts := []otlpmetrichttp.Option{otlpmetrichttp.WithHeaders(ec.Headers)}
+			if ec.Endpoint != "" {
+				opts = append(opts, otlpmetrichttp.WithEndpoint(ec.Endpoint))
+			}
+			if ec.Insecure {
+				opts = append(opts, otlpmetrichttp.WithInsecure())
+			}
+			exp, err = otlpmetrichttp.New(ctx, opts...)
+		case "stdout":
+			exp, err = stdoutmetric.New()
+		default:
+			return nil, fmt.Errorf("bootstrap: unsupported otel metrics exporter %q", ec.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mc.Exporters = append(mc.Exporters, exp)
+		m, err := motel.New(mc)
+		if err != nil {
+			// New does not own the exporter when it fails
+			// before the provider is created.
+			_ = exp.Shutdown(ctx)
+			return nil, err
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("bootstrap: unsupported metrics backend %q", c.Metrics.Backend)
+	}
+}
+
+func newTracer(ctx context.Context, c *Config, rd *redact.Redactor, onError health.ErrorHandler) (tracing.TraceMiddleware, error) {
+	switch strings.ToLower(c.Tracing.Backend) {
+	case "", "none":
+		return nil, nil
+	case "otel", "opentelemetry":
+		return newOTelTracer(ctx, c, rd, onError)
+	case "jaeger":
+		return newJaegerTracer(c, rd, onError)
+	case "zipkin":
+		return newZipkinTracer(c, rd, onError)
+	default:
+		return nil, fmt.Errorf("bootstrap: unsupported tracing backend %q", c.Tracing.Backend)
+	}
+}
+
+func newOTelTracer(ctx context.Context, c *Config, rd *redact.Redactor, onError health.ErrorHandler) (tracing.TraceMiddleware, error) {
+	spanName, err := c.Tracing.SpanName.SpanNameFunc()
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: invalid span name: %w", err)
+	}
+	tc := &totel.Config{
+		ServiceName:     c.ServiceName,
+		AddCaller:       c.Tracing.AddCaller,
+		Caller:          c.Tracing.Caller,
+		ErrorHandler:    onError,
+		BaggageKeys:     c.BaggageKeys,
+		ResponseHeaders: c.Tracing.ResponseHeaders,
+		SpanMetrics:     c.Tracing.spanMetrics(),
+		ServiceGraph:    c.Tracing.serviceGraph(),
+		TailSampling:    c.Tracing.TailSampling.config(),
+		Redactor:        rd,
+		SpanNameFunc:    spanName,
+		RecordPanics:    c.Recovery.Enabled,
+	}
+	if len(c.Tracing.Propagators) > 0 {
+		prop, err := autoprop.TextMapPropagator(c.Tracing.Propagators...)
+		if err != nil {
+			return nil, fmt.Errorf("bootstrap: invalid propagators: %w", err)
+		}
+		tc.Props = []propagation.TextMapPropagator{prop}
+	}
+	if s := c.Tracing.Sampling; s.Ratio != nil || s.ParentBased {
+		sampler := sdktrace.AlwaysSample()
+		if s.Ratio != nil {
+			sampler = sdktrace.TraceIDRatioBased(*s.Ratio)
+		}
+		if s.ParentBased {
+			sampler = sdktrace.ParentBased(sampler)
+		}
+		tc.ProviderOpts = append(tc.ProviderOpts, sdktrace.WithSampler(totel.JoinSampler(sampler)))
+	}
+	tc.Sampling = c.Tracing.Sampling.rules()
+
+	ec := c.Tracing.Exporter
+	var exp sdktrace.SpanExporter
+	switch strings.ToLower(ec.Type) {
+	case "", "env":
+		if tc, err = totel.FromEnv(ctx, tc); err != nil {
+			return nil, err
+		}
+		return totel.New(tc)
+	case "otlpgrpc":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(ec.Headers)}
+		if ec.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(ec.Endpoint))
+		}
+		if ec.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err = otlptracegrpc.New(ctx, opts...)
+	case "otlphttp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(ec.Headers)}
+		if ec.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(ec.Endpoint))
+		}
+		if ec.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exp, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("bootstrap: unsupported otel tracing exporter %q", ec.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ec.Queue != nil {
+		// The persistent exporter is created by the tracer
+		// so that export failures are monitored under it.
+		if tc.Persistence, err = ec.Queue.diskqueue(onError); err != nil {
+			_ = exp.Shutdown(ctx)
+			return nil, err
+		}
+	}
+	tc.Exporters = append(tc.Exporters, exp)
+	t, err := totel.New(tc)
+	if err != nil {
+		// New does not own the exporter when it fails
+		// before the span processors are created.
+		// Shutting down twice has no effect.
+		_ = exp.Shutdown(ctx)
+		return nil, err
+	}
+	return t, nil
+}
+
+func newJaegerTracer(c *Config, rd *redact.Redactor, onError health.ErrorHandler) (tracing.TraceMiddleware, error) {
+	spanName, err := c.Tracing.SpanName.SpanNameFunc()
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: invalid span name: %w", err)
+	}
+	jc := jaegercfg.Configuration{
+		ServiceName: c.ServiceName,
+		Reporter:    &jaegercfg.ReporterConfig{},
+	}
+	if r := c.Tracing.Sampling.Ratio; r != nil {
+		jc.Sampler = &jaegercfg.SamplerConfig{Type: "probabilistic", Param: *r}
+	}
+	ec := c.Tracing.Exporter
+	switch strings.ToLower(ec.Type) {
+	case "", "udp":
+		jc.Reporter.LocalAgentHostPort = ec.Endpoint
+	case "http":
+		jc.Reporter.CollectorEndpoint = ec.Endpoint
+		jc.Reporter.HTTPHeaders = ec.Headers
+	default:
+		return nil, fmt.Errorf("bootstrap: unsupported jaeger exporter %q", ec.Type)
+	}
+	return jaeger.New(&jaeger.Config{
+		JaegerConfig:    jc,
+		AddCaller:       c.Tracing.AddCaller,
+		Caller:          c.Tracing.Caller,
+		ErrorHandler:    onError,
+		Sampling:        c.Tracing.Sampling.rules(),
+		BaggageKeys:     c.BaggageKeys,
+		ResponseHeaders: c.Tracing.ResponseHeaders,
+		SpanMetrics:     c.Tracing.spanMetrics(),
+		ServiceGraph:    c.Tracing.serviceGraph(),
+		Redactor:        rd,
+		SpanNameFunc:    spanName,
+		RecordPanics:    c.Recovery.Enabled,
+	})
+}
+
+func newZipkinTracer(c *Config, rd *redact.Redactor, onError health.ErrorHandler) (tracing.TraceMiddleware, error) {
+	spanName, err := c.Tracing.SpanName.SpanNameFunc()
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: invalid span name: %w", err)
+	}
+	zc := &zipkin.Config{
+		AddCaller:       c.Tracing.AddCaller,
+		Caller:          c.Tracing.Caller,
+		ErrorHandler:    onError,
+		Sampling:        c.Tracing.Sampling.rules(),
+		BaggageKeys:     c.BaggageKeys,
+		ResponseHeaders: c.Tracing.ResponseHeaders,
+		SpanMetrics:     c.Tracing.spanMetrics(),
+		ServiceGraph:    c.Tracing.serviceGraph(),
+		Redactor:        rd,
+		SpanNameFunc:    spanName,
+		RecordPanics:    c.Recovery.Enabled,
+	}
+	ec := c.Tracing.Exporter
+	switch strings.ToLower(ec.Type) {
+	case "", "http":
+		if ec.Endpoint == "" {
+			return nil, errors.New("bootstrap: zipkin exporter endpoint is required")
+		}
+		headers := ec.Headers
+		zc.HTTPEndpoint = ec.Endpoint
+		zc.HTTPOpts = append(zc.HTTPOpts, zipkinhttp.RequestCallback(func(r *http.Request) {
+			for k, v := range headers {
+				r.Header.Set(k, v)
+			}
+		}))
+		if ec.Queue != nil {
+			qc, err := ec.Queue.diskqueue(onError)
+			if err != nil {
+				return nil, err
+			}
+			zc.Persistence = qc
+		}
+	case "log":
+		zc.Reporter = zipkinlog.NewReporter(log.New(os.Stderr, "", log.LstdFlags))
+	default:
+		return nil, fmt.Errorf("bootstrap: unsupported zipkin exporter %q", ec.Type)
+	}
+
+	endpoint, err := zipkingo.NewEndpoint(cmp.Or(c.ServiceName, "aileron"), "")
+	if err != nil {
+		return nil, err
+	}
+	zc.TracerOpts = append(zc.TracerOpts, zipkingo.WithLocalEndpoint(endpoint))
+	if r := c.Tracing.Sampling.Ratio; r != nil {
+		sampler, err := zipkingo.NewBoundarySampler(*r, time.Now().UnixNano())
+		if err != nil {
+			return nil, err
+		}
+		zc.TracerOpts = append(zc.TracerOpts, zipkingo.WithSampler(sampler))
+	}
+	return zipkin.New(zc)
+}
@@ -0,0 +1,225 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/metrics"
+	"github.com/aileron-projects/aileron-observability/metrics/prom"
+	"github.com/aileron-projects/aileron-observability/recovery"
+	"github.com/aileron-projects/aileron-observability/requestid"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+)
+
+func TestNew_middlewareOrder(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	o, err := New(context.Background(), &Config{
+		Metrics:   MetricsConfig{Backend: "prometheus"},
+		Tracing:   TracingConfig{Backend: "otel"},
+		RequestID: RequestIDConfig{Enabled: true},
+		Recovery:  RecoveryConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Shutdown(context.Background())
+
+	if len(o.server) != 4 || len(o.client) != 3 {
+		t.Fatalf("unexpected chains %v %v", o.server, o.client)
+	}
+	if _, ok := o.server[0].(*requestid.Middleware); !ok {
+		t.Errorf("request id must be the first, got %T", o.server[0])
+	}
+	if _, ok := o.server[1].(*recovery.Middleware); !ok {
+		t.Errorf("recovery must be the second, got %T", o.server[1])
+	}
+	if o.server[2] != o.tracer || o.server[3] != o.metrics {
+		t.Errorf("tracer and metrics must follow, got %T %T", o.server[2], o.server[3])
+	}
+
+	// Panics are recorded by the metrics before recovered.
+	h := o.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError || w.Header().Get(requestid.DefaultHeader) == "" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	mfs, err := o.metrics.(*prom.Metrics).Registry().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	panics := 0.0
+	for _, mf := range mfs {
+		if mf.GetName() == "http_server_panics_total" {
+			for _, m := range mf.GetMetric() {
+				panics += m.GetCounter().GetValue()
+			}
+		}
+	}
+	if panics != 1 {
+		t.Errorf("want 1 panic recorded, got %v", panics)
+	}
+}
+
+func TestNew_tailSampling(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	o, err := New(context.Background(), &Config{
+		Metrics: MetricsConfig{Backend: "prometheus"},
+		Tracing: TracingConfig{
+			Backend:      "otel",
+			TailSampling: &TailSamplingConfig{Errors: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Shutdown(context.Background())
+
+	mfs, err := o.metrics.(*prom.Metrics).Registry().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, mf := range mfs {
+		found = found || mf.GetName() == "observability_tail_sampling_traces_buffered"
+	}
+	if !found {
+		t.Error("tail sampling metrics must be registered")
+	}
+}
+
+// finalizer records the order of Finalize calls.
+type finalizer struct {
+	name  string
+	order *[]string
+	err   error
+}
+
+func (f *finalizer) finalize() error {
+	*f.order = append(*f.order, f.name)
+	return f.err
+}
+
+type fakeTracer struct {
+	tracing.TraceMiddleware
+	finalizer
+}
+
+func (t *fakeTracer) Finalize(_ context.Context) error {
+	return t.finalize()
+}
+
+type fakeMetrics struct {
+	metrics.MetricsMiddleware
+	finalizer
+}
+
+func (m *fakeMetrics) Finalize(_ context.Context) error {
+	return m.finalize()
+}
+
+func TestObservability_Shutdown(t *testing.T) {
+	var order []string
+	o := &Observability{
+		config:  &Config{},
+		tracer:  &fakeTracer{finalizer: finalizer{name: "tracer", order: &order, err: errors.New("tracer error")}},
+		metrics: &fakeMetrics{finalizer: finalizer{name: "metrics", order: &order, err: errors.New("metrics error")}},
+	}
+	err := o.Shutdown(context.Background())
+	if strings.Join(order, ",") != "tracer,metrics" {
+		t.Errorf("tracer must be finalized before metrics: %v", order)
+	}
+	if err == nil || !strings.Contains(err.Error(), "tracer error") || !strings.Contains(err.Error(), "metrics error") {
+		t.Errorf("errors must be joined: %v", err)
+	}
+}
+
+func TestObservability_ServeAdmin(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	o, err := New(context.Background(), &Config{
+		Admin: AdminConfig{Address: addr},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- o.ServeAdmin() }()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if res, err := http.Get("http://" + addr + "/healthz"); err == nil {
+			res.Body.Close()
+			break
+		}
+	}
+	if err := o.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("want ErrServerClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("admin server not stopped")
+	}
+	if err := o.ServeAdmin(); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("admin server must not start after shutdown: %v", err)
+	}
+}
+
+func TestNew_cleanup(t *testing.T) {
+	invalidRatio := 2.0
+	tracingConfig := TracingConfig{
+		Backend:  "otel",
+		Exporter: ExporterConfig{Type: "otlpgrpc", Endpoint: "127.0.0.1:1", Insecure: true},
+	}
+	testCases := map[string]func(c *Config){
+		"invalid fsync": func(c *Config) {
+			c.Tracing.Exporter.Queue = &QueueConfig{Dir: t.TempDir(), Fsync: "invalid"}
+		},
+		"invalid sampling": func(c *Config) {
+			c.Tracing.Sampling.Rules = []sampling.Rule{{Ratio: &invalidRatio}}
+		},
+		"no tail sampling policies": func(c *Config) {
+			c.Tracing.TailSampling = &TailSamplingConfig{}
+		},
+		"invalid metrics": func(c *Config) {
+			c.Metrics.Backend = "invalid"
+		},
+		"invalid metrics exporter": func(c *Config) {
+			c.Metrics = MetricsConfig{Backend: "otel", Exporter: ExporterConfig{Type: "invalid"}}
+		},
+	}
+	for name, modify := range testCases {
+		t.Run(name, func(t *testing.T) {
+			base := runtime.NumGoroutine()
+			c := &Config{Tracing: tracingConfig}
+			modify(c)
+			if _, err := New(context.Background(), c); err == nil {
+				t.Fatal("want an error")
+			}
+			n := runtime.NumGoroutine()
+			for deadline := time.Now().Add(5 * time.Second); n > base && time.Now().Before(deadline); n = runtime.NumGoroutine() {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if n > base {
+				t.Errorf("goroutines leaked: %d > %d", n, base)
+			}
+		})
+	}
+}
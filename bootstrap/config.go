@@ -0,0 +1,475 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	totel "github.com/aileron-projects/aileron-observability/tracing/otel"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the declarative configuration of the [Observability].
+// Config can be loaded from YAML or JSON using [LoadFile].
+// Field names in both formats are the json tag names.
+//
+// Example YAML:
+//
+//	serviceName: my-service
+//	baggageKeys: [tenant.id]
+//	metrics:
+//	  backend: prometheus
+//	tracing:
+//	  backend: otel
+//	  exporter:
+//	    type: otlpgrpc
+//	    endpoint: localhost:4317
+//	    insecure: true
+//	  sampling:
+//	    ratio: 0.1
+//	logging:
+//	  level: info
+//	  format: json
+//	profiling:
+//	  enabled: true
+//	admin:
+//	  address: ":9090"
+type Config struct {
+	// ServiceName is the application service name.
+	// If empty, default "aileron" is used.
+	ServiceName string `json:"serviceName"`
+	// BaggageKeys is the list of baggage keys copied to
+	// span tags, metric labels and log records.
+	// Metric labels are added only to the http request counters.
+	BaggageKeys []string `json:"baggageKeys"`
+	// Metrics is the metrics configuration.
+	Metrics MetricsConfig `json:"metrics"`
+	// Tracing is the tracing configuration.
+	Tracing TracingConfig `json:"tracing"`
+	// Logging is the logging configuration.
+	Logging LoggingConfig `json:"logging"`
+	// Profiling is the profiling configuration.
+	Profiling ProfilingConfig `json:"profiling"`
+	// Admin is the admin server configuration.
+	Admin AdminConfig `json:"admin"`
+	// RequestID is the request id configuration.
+	RequestID RequestIDConfig `json:"requestID"`
+	// Recovery is the panic recovery configuration.
+	Recovery RecoveryConfig `json:"recovery"`
+	// Redaction is the redaction rules shared by the tracer
+	// and the metrics middleware. Span names, span attributes and
+	// the "host", "path" and baggage labels of http request counters
+	// are redacted before exported. If nil, nothing is redacted.
+	Redaction *redact.Config `json:"redaction"`
+}
+
+// masked returns a copy of c whose exporter
+// header values and redaction hash key are masked.
+func (c *Config) masked() *Config {
+	cc := *c
+	mask := func(h map[string]string) map[string]string {
+		if h == nil {
+			return nil
+		}
+		m := make(map[string]string, len(h))
+		for k := range h {
+			m[k] = "******"
+		}
+		return m
+	}
+	cc.Metrics.Exporter.Headers = mask(c.Metrics.Exporter.Headers)
+	cc.Tracing.Exporter.Headers = mask(c.Tracing.Exporter.Headers)
+	if c.Redaction != nil && c.Redaction.HashKey != "" {
+		rc := *c.Redaction
+		rc.HashKey = "******"
+		cc.Redaction = &rc
+	}
+	return &cc
+}
+
+// MetricsConfig is the metrics configuration.
+type MetricsConfig struct {
+	// Backend is the metrics backend.
+	// Valid values are "none", "prometheus" and "otel".
+	// If empty, metrics are disabled.
+	Backend string `json:"backend"`
+	// Exporter is the exporter configuration.
+	// This is used only for "otel" backend.
+	Exporter ExporterConfig `json:"exporter"`
+	// Interval is the export interval of the "otel" backend.
+	// If zero, the default interval of the periodic reader is used.
+	Interval Duration `json:"interval"`
+}
+
+// TracingConfig is the tracing configuration.
+type TracingConfig struct {
+	// Backend is the tracing backend.
+	// Valid values are "none", "otel", "jaeger" and "zipkin".
+	// If empty, tracing is disabled.
+	Backend string `json:"backend"`
+	// Exporter is the exporter configuration.
+	Exporter ExporterConfig `json:"exporter"`
+	// Sampling is the sampling configuration.
+	Sampling SamplingConfig `json:"sampling"`
+	// TailSampling enables tail based sampling.
+	// This is used only for "otel" backend.
+	// If nil, traces are not tail sampled.
+	TailSampling *TailSamplingConfig `json:"tailSampling"`
+	// Propagators is the list of propagator names
+	// such as "tracecontext", "baggage" and "b3".
+	// This is used only for "otel" backend.
+	Propagators []string `json:"propagators"`
+	// AddCaller, if true, add caller info to the root spans.
+	AddCaller bool `json:"addCaller"`
+	// Caller configures the skip depth, the skipped package prefixes
+	// and the stack frames of the caller info added by the AddCaller.
+	// If nil, frames of the net/http, zhttp and this module are skipped.
+	Caller *tracing.CallerConfig `json:"caller"`
+	// ResponseHeaders writes the trace id of the root server spans
+	// in the response headers. If nil, nothing is written.
+	ResponseHeaders *tracing.ResponseHeaderConfig `json:"responseHeaders"`
+	// SpanMetrics, if true, records the request, error and duration
+	// metrics of finished spans to the metrics backend.
+	// See [spanmetrics.Metrics] for the recorded metrics.
+	SpanMetrics bool `json:"spanMetrics"`
+	// ServiceGraph, if true, records the service graph built from
+	// the pairs of client and server spans to the metrics backend.
+	// Edges are served on "/debug/servicegraph" of the admin handler.
+	// See [servicegraph.Graph] for the recorded metrics.
+	ServiceGraph bool `json:"serviceGraph"`
+	// SpanName is the naming strategy of http spans.
+	// If nil, spans are named such as "server+/users/123"
+	// for the compatibility with existing dashboards.
+	SpanName *tracing.SpanNameConfig `json:"spanName"`
+}
+
+// spanMetrics returns the span metrics.
+// It returns nil if the span metrics are disabled.
+func (c *TracingConfig) spanMetrics() *spanmetrics.Metrics {
+	if !c.SpanMetrics {
+		return nil
+	}
+	return spanmetrics.New(&spanmetrics.Config{})
+}
+
+// serviceGraph returns the service graph.
+// It returns nil if the service graph is disabled.
+func (c *TracingConfig) serviceGraph() *servicegraph.Graph {
+	if !c.ServiceGraph {
+		return nil
+	}
+	return servicegraph.New(&servicegraph.Config{})
+}
+
+// ExporterConfig is the exporter configuration.
+type ExporterConfig struct {
+	// Type is the exporter type.
+	// Valid values depend on the backend.
+	//
+	//   - otel: "otlpgrpc", "otlphttp", "stdout", "env" (default)
+	//   - jaeger: "udp" (default), "http"
+	//   - zipkin: "http" (default), "log"
+	//
+	// "env" configures exporters from the OTEL_* environment variables.
+	Type string `json:"type"`
+	// Endpoint is the endpoint of the exporter.
+	// "host:port" for otlpgrpc, otlphttp and udp.
+	// URL for http of jaeger and zipkin.
+	Endpoint string `json:"endpoint"`
+	// Insecure, if true, disables TLS of otlp exporters.
+	Insecure bool `json:"insecure"`
+	// Headers is the additional headers sent to the backend.
+	Headers map[string]string `json:"headers"`
+	// Queue is the disk queue configuration.
+	// If nil, failed batches are not persisted.
+	// This is used only for tracing with "otel" backend
+	// except for "env" type and "zipkin" backend with "http" type.
+	Queue *QueueConfig `json:"queue"`
+}
+
+// QueueConfig is the disk queue configuration
+// that persists batches failed to be exported.
+type QueueConfig struct {
+	// Dir is the directory to store batches.
+	Dir string `json:"dir"`
+	// MaxBytes is the maximum total bytes of stored batches.
+	// If zero, 64 MiB is used.
+	MaxBytes int64 `json:"maxBytes"`
+	// MaxAge is the maximum age of stored batches.
+	// If zero, batches never expire.
+	MaxAge Duration `json:"maxAge"`
+	// Fsync is the fsync policy.
+	// Valid values are "always" (default) and "never".
+	Fsync string `json:"fsync"`
+}
+
+// diskqueue returns the diskqueue config.
+func (c *QueueConfig) diskqueue(onError health.ErrorHandler) (*diskqueue.Config, error) {
+	dc := &diskqueue.Config{
+		Dir:          c.Dir,
+		MaxBytes:     c.MaxBytes,
+		MaxAge:       time.Duration(c.MaxAge),
+		ErrorHandler: onError,
+	}
+	switch strings.ToLower(c.Fsync) {
+	case "", "always":
+		dc.Fsync = diskqueue.FsyncAlways
+	case "never":
+		dc.Fsync = diskqueue.FsyncNever
+	default:
+		return nil, fmt.Errorf("bootstrap: invalid fsync policy %q", c.Fsync)
+	}
+	return dc, nil
+}
+
+// SamplingConfig is the sampling configuration.
+type SamplingConfig struct {
+	// Ratio is the sampling ratio in the range of [0.0, 1.0].
+	// If nil, all traces are sampled.
+	Ratio *float64 `json:"ratio"`
+	// ParentBased, if true, respects the sampling decision
+	// of the parent span. This is used only for "otel" backend
+	// unless Rules are configured.
+	ParentBased bool `json:"parentBased"`
+	// Rules is the list of rules to sample server requests.
+	// If set, the first matched rule determines the sampling ratio
+	// and Ratio is used for requests that matched no rules.
+	// Rules work in the same way for all backends.
+	Rules []sampling.Rule `json:"rules"`
+	// RateLimit is the maximum number of sampled traces per second.
+	// Requests matched to the Rules with Force are not limited.
+	// If zero, sampled traces are not limited.
+	RateLimit float64 `json:"rateLimit"`
+	// Adaptive enables adaptive sampling for requests
+	// that matched no Rules. Ratio is ignored if set.
+	Adaptive *AdaptiveConfig `json:"adaptive"`
+}
+
+// AdaptiveConfig is the adaptive sampling configuration.
+type AdaptiveConfig struct {
+	// TargetPerSecond is the target number of
+	// sampled traces per second for each route.
+	TargetPerSecond float64 `json:"targetPerSecond"`
+	// Interval is the interval to adjust sampling ratios.
+	// If zero, 10 seconds is used.
+	Interval Duration `json:"interval"`
+	// MaxRoutes is the maximum number of routes tracked.
+	// If zero, 100 is used.
+	MaxRoutes int `json:"maxRoutes"`
+}
+
+// rules returns the rule based sampling config.
+// It returns nil if none of rules, rate limit
+// and adaptive sampling are configured.
+func (c *SamplingConfig) rules() *sampling.Config {
+	if len(c.Rules) == 0 && c.RateLimit <= 0 && c.Adaptive == nil {
+		return nil
+	}
+	sc := &sampling.Config{
+		Rules:        c.Rules,
+		DefaultRatio: c.Ratio,
+		ParentBased:  c.ParentBased,
+		RateLimit:    c.RateLimit,
+	}
+	if a := c.Adaptive; a != nil {
+		sc.Adaptive = &sampling.AdaptiveConfig{
+			TargetPerSecond: a.TargetPerSecond,
+			Interval:        time.Duration(a.Interval),
+			MaxRoutes:       a.MaxRoutes,
+		}
+	}
+	return sc
+}
+
+// TailSamplingConfig is the tail sampling configuration.
+// Traces are kept when any of the configured policies keeps them.
+// At least one policy must be configured.
+// See [totel.TailSamplingProcessor] for the recorded metrics.
+type TailSamplingConfig struct {
+	// DecisionWait is the time to wait for the spans of a trace
+	// before the sampling decision. If zero, 10 seconds is used.
+	DecisionWait Duration `json:"decisionWait"`
+	// MaxTraces is the maximum number of buffered traces.
+	// If zero, 10000 is used.
+	MaxTraces int `json:"maxTraces"`
+	// MaxSpansPerTrace is the maximum number of buffered
+	// spans of a trace. If zero, 1000 is used.
+	MaxSpansPerTrace int `json:"maxSpansPerTrace"`
+	// Errors, if true, keeps traces which have error spans.
+	Errors bool `json:"errors"`
+	// Latency keeps traces longer than the duration.
+	// If zero, traces are not kept by the latency.
+	Latency Duration `json:"latency"`
+	// Attributes keeps traces which have any of the string attributes.
+	Attributes map[string]string `json:"attributes"`
+	// Probability keeps the fraction of traces.
+	// If nil, traces are not kept by the probability.
+	Probability *float64 `json:"probability"`
+}
+
+// config returns the tail sampling config of the otel tracer.
+// It returns nil if the c is nil.
+func (c *TailSamplingConfig) config() *totel.TailSamplingConfig {
+	if c == nil {
+		return nil
+	}
+	tc := &totel.TailSamplingConfig{
+		DecisionWait:     time.Duration(c.DecisionWait),
+		MaxTraces:        c.MaxTraces,
+		MaxSpansPerTrace: c.MaxSpansPerTrace,
+	}
+	if c.Errors {
+		tc.Policies = append(tc.Policies, totel.ErrorPolicy())
+	}
+	if c.Latency > 0 {
+		tc.Policies = append(tc.Policies, totel.LatencyPolicy(time.Duration(c.Latency)))
+	}
+	if len(c.Attributes) > 0 {
+		kvs := make([]attribute.KeyValue, 0, len(c.Attributes))
+		for _, k := range slices.Sorted(maps.Keys(c.Attributes)) {
+			kvs = append(kvs, attribute.String(k, c.Attributes[k]))
+		}
+		tc.Policies = append(tc.Policies, totel.AttributePolicy(kvs...))
+	}
+	if c.Probability != nil {
+		tc.Policies = append(tc.Policies, totel.ProbabilityPolicy(*c.Probability))
+	}
+	return tc
+}
+
+// LoggingConfig is the logging configuration.
+type LoggingConfig struct {
+	// Level is the log level.
+	// Valid values are "debug", "info" (default), "warn" and "error".
+	Level string `json:"level"`
+	// Format is the log format.
+	// Valid values are "text" (default) and "json".
+	Format string `json:"format"`
+	// Output is the log output.
+	// Valid values are "stderr" (default) and "stdout".
+	Output string `json:"output"`
+}
+
+// RequestIDConfig is the request id configuration.
+type RequestIDConfig struct {
+	// Enabled, if true, applies the request id middleware
+	// before the tracing and metrics middleware.
+	// Request ids are added to logs as the "request_id" attribute.
+	Enabled bool `json:"enabled"`
+	// Header is the header name of the request id.
+	// If empty, "X-Request-ID" is used.
+	Header string `json:"header"`
+}
+
+// RecoveryConfig is the panic recovery configuration.
+type RecoveryConfig struct {
+	// Enabled, if true, records panics in http handlers
+	// in the spans and the "http_server_panics_total" metric,
+	// logs them with the stack and then replies 500.
+	Enabled bool `json:"enabled"`
+	// RePanic, if true, panics again after the panics
+	// were recorded and logged instead of replying 500.
+	RePanic bool `json:"rePanic"`
+}
+
+// ProfilingConfig is the profiling configuration.
+type ProfilingConfig struct {
+	// Enabled, if true, serves pprof endpoints
+	// on the admin handler.
+	Enabled bool `json:"enabled"`
+	// BlockProfileRate is the value passed to [runtime.SetBlockProfileRate].
+	// Block profiling is disabled when zero.
+	BlockProfileRate int `json:"blockProfileRate"`
+	// MutexProfileFraction is the value passed to [runtime.SetMutexProfileFraction].
+	// Mutex profiling is disabled when zero.
+	MutexProfileFraction int `json:"mutexProfileFraction"`
+}
+
+// AdminConfig is the admin server configuration.
+type AdminConfig struct {
+	// Address is the listen address of the admin server
+	// such as ":9090". The admin server is started
+	// by [Observability.ServeAdmin].
+	Address string `json:"address"`
+}
+
+// Duration is the [time.Duration] which is encoded
+// as a string such as "10s" in JSON and YAML.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("bootstrap: duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("bootstrap: invalid duration %q: %w", s, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadFile loads a [Config] from the file.
+// The file format is determined by the extension.
+// ".yaml" and ".yml" are parsed as YAML and others as JSON.
+func LoadFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(b)
+	default:
+		return ParseJSON(b)
+	}
+}
+
+// ParseJSON parses the JSON encoded [Config].
+// Unknown fields result in an error.
+func ParseJSON(b []byte) (*Config, error) {
+	c := &Config{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("bootstrap: failed to parse config: %w", err)
+	}
+	return c, nil
+}
+
+// ParseYAML parses the YAML encoded [Config].
+// The YAML is converted into JSON and then parsed by [ParseJSON]
+// so the field names are the same as JSON.
+func ParseYAML(b []byte) (*Config, error) {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("bootstrap: failed to parse config: %w", err)
+	}
+	if v == nil {
+		return &Config{}, nil
+	}
+	jb, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: failed to parse config: %w", err)
+	}
+	return ParseJSON(jb)
+}
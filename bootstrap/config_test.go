@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+serviceName: my-service
+baggageKeys: [tenant.id]
+metrics:
+  backend: prometheus
+tracing:
+  backend: otel
+  exporter:
+    type: otlpgrpc
+    endpoint: localhost:4317
+    insecure: true
+    queue:
+      dir: /tmp/spans
+      maxAge: 1h30m
+  sampling:
+    ratio: 0.1
+  tailSampling:
+    decisionWait: 5s
+    errors: true
+    attributes:
+      http.route: /admin
+logging:
+  level: info
+  format: json
+admin:
+  address: ":9090"
+`
+
+func TestParseYAML(t *testing.T) {
+	c, err := ParseYAML([]byte(testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ServiceName != "my-service" || len(c.BaggageKeys) != 1 || c.BaggageKeys[0] != "tenant.id" {
+		t.Errorf("unexpected service config %q %v", c.ServiceName, c.BaggageKeys)
+	}
+	if c.Metrics.Backend != "prometheus" || c.Tracing.Backend != "otel" || c.Admin.Address != ":9090" {
+		t.Errorf("unexpected backends %+v %+v", c.Metrics, c.Tracing)
+	}
+	ec := c.Tracing.Exporter
+	if ec.Type != "otlpgrpc" || ec.Endpoint != "localhost:4317" || !ec.Insecure {
+		t.Errorf("unexpected exporter %+v", ec)
+	}
+	if ec.Queue == nil || ec.Queue.Dir != "/tmp/spans" || time.Duration(ec.Queue.MaxAge) != 90*time.Minute {
+		t.Errorf("unexpected queue %+v", ec.Queue)
+	}
+	if r := c.Tracing.Sampling.Ratio; r == nil || *r != 0.1 {
+		t.Errorf("unexpected ratio %v", r)
+	}
+	ts := c.Tracing.TailSampling
+	if ts == nil || time.Duration(ts.DecisionWait) != 5*time.Second || !ts.Errors || ts.Attributes["http.route"] != "/admin" {
+		t.Errorf("unexpected tail sampling %+v", ts)
+	}
+	if tc := ts.config(); tc == nil || tc.DecisionWait != 5*time.Second || len(tc.Policies) != 2 {
+		t.Errorf("unexpected tail sampling policies %+v", tc)
+	}
+
+	testCases := map[string]struct {
+		yaml string
+		err  string
+	}{
+		"empty":            {"", ""},
+		"unknown field":    {"serviceName: a\nunknown: b\n", `unknown field "unknown"`},
+		"unknown nested":   {"tracing:\n  exporter:\n    typo: otlpgrpc\n", `unknown field "typo"`},
+		"invalid duration": {"metrics:\n  interval: 10\n", "duration must be a string"},
+		"invalid yaml":     {"serviceName: [", "failed to parse config"},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseYAML([]byte(tc.yaml))
+			if tc.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Errorf("want error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	testCases := map[string]struct {
+		path    string
+		service string
+		err     bool
+	}{
+		"yaml":               {write("c.yaml", "serviceName: yaml"), "yaml", false},
+		"yml":                {write("c.yml", "serviceName: yml"), "yml", false},
+		"json":               {write("c.json", `{"serviceName":"json"}`), "json", false},
+		"other as json":      {write("c.conf", `{"serviceName":"conf"}`), "conf", false},
+		"unknown json field": {write("u.json", `{"serviceName":"json","unknown":1}`), "", true},
+		"unknown yaml field": {write("u.yaml", "unknown: 1"), "", true},
+		"yaml as json":       {write("y.json", "serviceName: yaml"), "", true},
+		"not found":          {filepath.Join(dir, "missing.yaml"), "", true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, err := LoadFile(tc.path)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && c.ServiceName != tc.service {
+				t.Errorf("want %q, got %q", tc.service, c.ServiceName)
+			}
+		})
+	}
+}
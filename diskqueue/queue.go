@@ -0,0 +1,401 @@
+package diskqueue
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrFull is returned by [Queue.Push] when the queue
+	// does not have enough space for the data.
+	ErrFull = errors.New("diskqueue: queue is full")
+	// ErrCorrupted is returned when a stored entry is broken.
+	ErrCorrupted = errors.New("diskqueue: corrupted entry")
+)
+
+// FsyncPolicy is the policy of calling fsync
+// when entries are written to the disk.
+type FsyncPolicy int
+
+const (
+	// FsyncAlways calls fsync for every entries and the directory.
+	// Entries survive power failures but writes are slow.
+	FsyncAlways FsyncPolicy = iota
+	// FsyncNever never calls fsync and leaves flushing to the OS.
+	// Entries survive process crashes but may be lost on power failures.
+	FsyncNever
+)
+
+const (
+	fileExt = ".wal"
+	tmpExt  = ".tmp"
+	// maxReadErrors is the number of read errors
+	// of an entry before the entry is discarded.
+	maxReadErrors = 5
+)
+
+// Config is the configuration for the [Queue].
+// Use [Open] to open a queue.
+type Config struct {
+	// Dir is the directory to store entries.
+	// The directory is created if not exist.
+	// Dir must not be shared between queues.
+	// Dir is required.
+	Dir string
+	// MaxBytes is the maximum total bytes of stored entries.
+	// New entries are rejected with [ErrFull] when exceeded.
+	// If zero or negative, 64 MiB is used.
+	MaxBytes int64
+	// MaxAge is the maximum age of entries.
+	// Entries older than MaxAge are discarded without replayed.
+	// If zero or negative, entries are never expired.
+	MaxAge time.Duration
+	// Fsync is the fsync policy.
+	// Default is [FsyncAlways].
+	Fsync FsyncPolicy
+	// MinBackoff is the initial wait time after replay failure.
+	// The wait time doubles on every failure up to MaxBackoff.
+	// If zero or negative, 1 second is used.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum wait time after replay failure.
+	// If zero or negative, 1 minute is used.
+	MaxBackoff time.Duration
+	// ErrorHandler handles errors occurred while replaying entries.
+	// If nil, errors are ignored.
+	ErrorHandler func(err error)
+}
+
+// Queue is a bounded FIFO queue stored on the disk.
+// Each entry is stored in a separate file written with write-then-rename
+// so that partially written entries are never read.
+// Entries are kept across process restarts.
+// Queue is safe for concurrent use.
+type Queue struct {
+	dir        string
+	maxBytes   int64
+	maxAge     time.Duration
+	fsync      FsyncPolicy
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onError    func(error)
+
+	// notify is signaled when an entry is pushed.
+	notify chan struct{}
+
+	mu      sync.Mutex
+	entries []*entry // Sorted from oldest to newest.
+	size    int64    // Total bytes of entries.
+	seq     uint64   // Last used sequence number.
+	dropped int      // Number of discarded entries.
+}
+
+// entry is a stored entry.
+// The file name is "<seq>-<unixnano>.wal".
+type entry struct {
+	seq  uint64
+	time time.Time
+	size int64
+	// readErrors is the number of read errors.
+	readErrors int
+}
+
+func (e *entry) name() string {
+	return fmt.Sprintf("%020d-%020d%s", e.seq, e.time.UnixNano(), fileExt)
+}
+
+// Open opens a queue in the c.Dir.
+// Entries left in the directory are loaded.
+func Open(c *Config) (*Queue, error) {
+	if c.Dir == "" {
+		return nil, errors.New("diskqueue: directory is required")
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		dir:        c.Dir,
+		maxBytes:   cmp.Or(max(c.MaxBytes, 0), 64<<20),
+		maxAge:     max(c.MaxAge, 0),
+		fsync:      c.Fsync,
+		minBackoff: cmp.Or(max(c.MinBackoff, 0), time.Second),
+		maxBackoff: cmp.Or(max(c.MaxBackoff, 0), time.Minute),
+		onError:    c.ErrorHandler,
+		notify:     make(chan struct{}, 1),
+	}
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasSuffix(name, tmpExt) {
+			_ = os.Remove(filepath.Join(c.Dir, name)) // Incomplete write.
+			continue
+		}
+		e, ok := parseName(name)
+		if !ok {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		e.size = info.Size()
+		q.entries = append(q.entries, e)
+		q.size += e.size
+		q.seq = max(q.seq, e.seq)
+	}
+	slices.SortFunc(q.entries, func(a, b *entry) int { return cmp.Compare(a.seq, b.seq) })
+	return q, nil
+}
+
+// parseName parses the file name of an entry.
+func parseName(name string) (*entry, bool) {
+	s, ok := strings.CutSuffix(name, fileExt)
+	if !ok {
+		return nil, false
+	}
+	seqStr, timeStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	nano, err := strconv.ParseInt(timeStr, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &entry{seq: seq, time: time.Unix(0, nano)}, true
+}
+
+// Len returns the number of stored entries.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Size returns the total bytes of stored entries.
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Dropped returns the number of entries discarded
+// because they were expired, corrupted or unreadable.
+func (q *Queue) Dropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Push stores the data at the end of the queue.
+// It returns [ErrFull] when the queue does not have enough space.
+func (q *Queue) Push(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	size := int64(len(data) + 4) // 4 bytes crc32 header.
+	if q.size+size > q.maxBytes {
+		return ErrFull
+	}
+	e := &entry{seq: q.seq + 1, time: time.Now(), size: size}
+	b := make([]byte, 4, size)
+	binary.BigEndian.PutUint32(b, crc32.ChecksumIEEE(data))
+	b = append(b, data...)
+	if err := q.write(e.name(), b); err != nil {
+		return err
+	}
+	q.seq = e.seq
+	q.entries = append(q.entries, e)
+	q.size += size
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// write writes b to the file with the name
+// through a temporary file.
+func (q *Queue) write(name string, b []byte) error {
+	tmp := filepath.Join(q.dir, name+tmpExt)
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil && q.fsync == FsyncAlways {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(q.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if q.fsync == FsyncAlways {
+		return syncDir(q.dir)
+	}
+	return nil
+}
+
+// syncDir calls fsync on the directory to persist renames.
+// Errors are ignored on platforms where directories cannot be synced.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	_ = d.Sync()
+	return nil
+}
+
+// Peek returns the oldest entry without removing it.
+// Expired, corrupted and deleted entries are discarded.
+// Entries failed to be read are discarded after
+// they failed 5 times so that they do not block the queue.
+// It returns false when the queue is empty.
+// Call [Queue.Remove] with the returned seq after
+// the entry was processed.
+func (q *Queue) Peek() (data []byte, seq uint64, ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.entries) > 0 {
+		e := q.entries[0]
+		if q.maxAge > 0 && time.Since(e.time) > q.maxAge {
+			q.dropped++
+			q.removeLocked(e)
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(q.dir, e.name()))
+		if err != nil {
+			e.readErrors++
+			if !errors.Is(err, os.ErrNotExist) && e.readErrors < maxReadErrors {
+				return nil, 0, false, err
+			}
+			q.dropped++
+			q.removeLocked(e)
+			q.handle(fmt.Errorf("diskqueue: entry discarded: %w", err))
+			continue
+		}
+		if len(b) < 4 || binary.BigEndian.Uint32(b) != crc32.ChecksumIEEE(b[4:]) {
+			q.dropped++
+			q.removeLocked(e)
+			q.handle(fmt.Errorf("%w: %s", ErrCorrupted, e.name()))
+			continue
+		}
+		return b[4:], e.seq, true, nil
+	}
+	return nil, 0, false, nil
+}
+
+// Remove removes the entry with the seq.
+func (q *Queue) Remove(seq uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, e := range q.entries {
+		if e.seq == seq {
+			q.removeLocked(e)
+			return
+		}
+	}
+}
+
+func (q *Queue) removeLocked(e *entry) {
+	q.entries = slices.DeleteFunc(q.entries, func(ee *entry) bool { return ee == e })
+	q.size -= e.size
+	if err := os.Remove(filepath.Join(q.dir, e.name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		q.handle(err)
+	}
+}
+
+func (q *Queue) handle(err error) {
+	if q.onError != nil {
+		q.onError(err)
+	}
+}
+
+// replayKey is the context key that marks replays.
+type replayKey struct{}
+
+// IsReplay returns true if the ctx is the one passed to
+// the send function of the [Queue.Replay] or its child.
+// It can be used to tell replays from the first attempts.
+func IsReplay(ctx context.Context) bool {
+	v, _ := ctx.Value(replayKey{}).(bool)
+	return v
+}
+
+// Replay sends the stored entries from the oldest with the send function
+// until the ctx is canceled. Entries are removed when send returns nil.
+// When send returns an error, the entry is retried after
+// a backoff which grows exponentially.
+// The ctx passed to the send function is marked so that
+// [IsReplay] returns true.
+// Replay blocks until the ctx is done.
+func (q *Queue) Replay(ctx context.Context, send func(context.Context, []byte) error) {
+	ctx = context.WithValue(ctx, replayKey{}, true)
+	backoff := q.minBackoff
+	for {
+		data, seq, ok, err := q.Peek()
+		if err != nil {
+			q.handle(err)
+		}
+		if ok {
+			if err = send(ctx, data); err == nil {
+				q.Remove(seq)
+				backoff = q.minBackoff
+				continue
+			}
+			q.handle(err)
+		}
+
+		if err != nil {
+			// Keep waiting for the backoff even if entries are pushed.
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = min(2*backoff, q.maxBackoff)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.notify:
+		}
+	}
+}
+
+// sleep waits for the d or until the ctx is done.
+// It returns false if the ctx is done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
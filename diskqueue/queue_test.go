@@ -0,0 +1,203 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueue_PeekDeleted(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(&Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, q.entries[0].name())); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, ok, err := q.Peek()
+	if err != nil || !ok || string(data) != "b" {
+		t.Errorf("unexpected peek result: data=%q ok=%v err=%v", data, ok, err)
+	}
+	if q.Dropped() != 1 || q.Len() != 1 {
+		t.Errorf("unexpected dropped=%d len=%d", q.Dropped(), q.Len())
+	}
+}
+
+func TestQueue_PeekUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(&Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	// Replace the entry with a directory so that it cannot be read.
+	p := filepath.Join(dir, q.entries[0].name())
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(p, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < maxReadErrors; i++ {
+		if _, _, ok, err := q.Peek(); ok || err == nil {
+			t.Fatalf("peek %d: expected read error, got ok=%v err=%v", i, ok, err)
+		}
+	}
+	data, _, ok, err := q.Peek()
+	if err != nil || !ok || string(data) != "b" {
+		t.Errorf("unexpected peek result: data=%q ok=%v err=%v", data, ok, err)
+	}
+	if q.Dropped() != 1 || q.Len() != 1 {
+		t.Errorf("unexpected dropped=%d len=%d", q.Dropped(), q.Len())
+	}
+}
+
+func TestQueue_reopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(&Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if err := q.Push([]byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Incomplete writes are removed when reopened.
+	if err := os.WriteFile(filepath.Join(dir, "x"+tmpExt), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	q2, err := Open(&Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q2.Len() != 3 || q2.Size() != q.Size() {
+		t.Fatalf("entries must be loaded: len=%d size=%d", q2.Len(), q2.Size())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x"+tmpExt)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file must be removed: %v", err)
+	}
+	if err := q2.Push([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"a", "b", "c", "d"} {
+		data, seq, ok, err := q2.Peek()
+		if err != nil || !ok || string(data) != want {
+			t.Fatalf("want %q, got data=%q ok=%v err=%v", want, data, ok, err)
+		}
+		q2.Remove(seq)
+	}
+}
+
+func TestQueue_MaxAge(t *testing.T) {
+	q, err := Open(&Config{Dir: t.TempDir(), MaxAge: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := q.Push([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	data, _, ok, err := q.Peek()
+	if err != nil || !ok || string(data) != "new" {
+		t.Errorf("expired entry must be discarded: data=%q ok=%v err=%v", data, ok, err)
+	}
+	if q.Dropped() != 1 || q.Len() != 1 {
+		t.Errorf("unexpected dropped=%d len=%d", q.Dropped(), q.Len())
+	}
+}
+
+func TestQueue_ErrFull(t *testing.T) {
+	// Each entry takes 4 bytes of checksum and the data.
+	q, err := Open(&Config{Dir: t.TempDir(), MaxBytes: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("123456")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("123456")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("1")); !errors.Is(err, ErrFull) {
+		t.Fatalf("want ErrFull, got %v", err)
+	}
+	_, seq, _, _ := q.Peek()
+	q.Remove(seq)
+	if err := q.Push([]byte("1")); err != nil {
+		t.Errorf("space must be released after removed: %v", err)
+	}
+}
+
+func TestQueue_Replay(t *testing.T) {
+	var errs atomic.Int32
+	q, err := Open(&Config{
+		Dir:          t.TempDir(),
+		MinBackoff:   time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+		ErrorHandler: func(error) { errs.Add(1) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls atomic.Int32
+	got := make(chan string, 10)
+	go q.Replay(ctx, func(ctx context.Context, data []byte) error {
+		if !IsReplay(ctx) {
+			t.Error("replay context must be marked")
+		}
+		if calls.Add(1) <= 2 {
+			return errors.New("unavailable")
+		}
+		got <- string(data)
+		return nil
+	})
+	for _, v := range []string{"a", "b"} {
+		if err := q.Push([]byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"a", "b"} {
+		select {
+		case v := <-got:
+			if v != want {
+				t.Errorf("want %q, got %q", want, v)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("entries not replayed")
+		}
+	}
+	if n := errs.Load(); n != 2 {
+		t.Errorf("want 2 errors, got %d", n)
+	}
+	for deadline := time.Now().Add(5 * time.Second); q.Len() > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if q.Len() != 0 {
+		t.Errorf("replayed entries must be removed, got %d", q.Len())
+	}
+}
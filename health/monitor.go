@@ -0,0 +1,303 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	_ prometheus.Collector = &Monitor{}
+)
+
+// ErrorHandler handles export errors.
+// It is called synchronously from the exporting goroutine
+// so it should return quickly.
+type ErrorHandler func(err error)
+
+// Status is the health status of an exporter.
+type Status struct {
+	// LastError is the last export error.
+	// It is nil when nothing failed.
+	LastError error
+	// LastErrorTime is the time when the LastError occurred.
+	LastErrorTime time.Time
+	// LastSuccessTime is the time of the last successful export.
+	LastSuccessTime time.Time
+}
+
+// Healthy returns true when the last export succeeded
+// or nothing has been exported yet.
+func (s Status) Healthy() bool {
+	return s.LastError == nil || s.LastSuccessTime.After(s.LastErrorTime)
+}
+
+// Config is the configuration for the [Monitor].
+type Config struct {
+	// Exporter is the name of the monitored exporter
+	// such as "otel", "jaeger" or "zipkin".
+	// It is exposed as the "exporter" label.
+	Exporter string
+	// Signal is the type of exported data
+	// such as "spans" or "points".
+	// It is exposed as the "signal" label.
+	Signal string
+	// ErrorHandler handles export errors.
+	// If nil, errors are only recorded in the [Status].
+	ErrorHandler ErrorHandler
+}
+
+// Monitor records export results of an exporter.
+// Monitor implements [prometheus.Collector] and can also
+// be registered to an OpenTelemetry meter with [Monitor.RegisterMeter].
+// Use [NewMonitor] to create a new instance.
+//
+// Following metrics are exposed with "exporter" and "signal" labels.
+//
+//   - observability_exporter_exported_total: number of exported items.
+//   - observability_exporter_dropped_total: number of items dropped before export.
+//   - observability_exporter_failed_total: number of items failed to export.
+//   - observability_exporter_queue_length: number of items waiting for export.
+//   - observability_exporter_export_duration_seconds: histogram of export latency.
+type Monitor struct {
+	exporter string
+	signal   string
+	handler  ErrorHandler
+
+	exported atomic.Int64
+	dropped  atomic.Int64
+	failed   atomic.Int64
+	queued   atomic.Int64
+
+	// Descriptors of the prometheus metrics.
+	// Labels are given as constant labels so that
+	// multiple monitors can be registered to a registry.
+	exportedDesc *prometheus.Desc
+	droppedDesc  *prometheus.Desc
+	failedDesc   *prometheus.Desc
+	queueDesc    *prometheus.Desc
+	// latency is the export latency histogram
+	// exposed for prometheus.
+	latency prometheus.Histogram
+	// otelLatency is the export latency histogram
+	// registered by RegisterMeter.
+	otelLatency atomic.Pointer[metric.Float64Histogram]
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewMonitor returns a new [Monitor].
+func NewMonitor(c *Config) *Monitor {
+	labels := prometheus.Labels{"exporter": c.Exporter, "signal": c.Signal}
+	return &Monitor{
+		exporter:     c.Exporter,
+		signal:       c.Signal,
+		handler:      c.ErrorHandler,
+		exportedDesc: prometheus.NewDesc("observability_exporter_exported_total", "Number of exported items", nil, labels),
+		droppedDesc:  prometheus.NewDesc("observability_exporter_dropped_total", "Number of items dropped before export", nil, labels),
+		failedDesc:   prometheus.NewDesc("observability_exporter_failed_total", "Number of items failed to export", nil, labels),
+		queueDesc:    prometheus.NewDesc("observability_exporter_queue_length", "Number of items waiting for export", nil, labels),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "observability_exporter_export_duration_seconds",
+			Help:        "Latency of exports",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+	}
+}
+
+// Enqueued records n items were enqueued for export.
+func (m *Monitor) Enqueued(n int) {
+	m.queued.Add(int64(n))
+}
+
+// TryEnqueue records n items were enqueued for export
+// if the queue length does not exceed the limit after enqueued.
+// Otherwise, n items are recorded as dropped and it returns false.
+func (m *Monitor) TryEnqueue(n, limit int) bool {
+	for {
+		cur := m.queued.Load()
+		if cur+int64(n) > int64(limit) {
+			m.dropped.Add(int64(n))
+			return false
+		}
+		if m.queued.CompareAndSwap(cur, cur+int64(n)) {
+			return true
+		}
+	}
+}
+
+// Exported records n items were exported.
+// Exported items are removed from the queue.
+func (m *Monitor) Exported(n int) {
+	m.exported.Add(int64(n))
+	m.queued.Add(-int64(n))
+	m.mu.Lock()
+	m.status.LastSuccessTime = time.Now()
+	m.mu.Unlock()
+}
+
+// Failed records n items were failed to be exported with err.
+// Failed items are removed from the queue.
+// The err is passed to the error handler if configured.
+func (m *Monitor) Failed(n int, err error) {
+	m.failed.Add(int64(n))
+	m.queued.Add(-int64(n))
+	m.Error(err)
+}
+
+// Dropped records n items were dropped before export.
+// Dropped items are removed from the queue.
+func (m *Monitor) Dropped(n int) {
+	m.dropped.Add(int64(n))
+	m.queued.Add(-int64(n))
+}
+
+// Persisted records n items were stored to a persistent queue
+// such as the diskqueue to be exported later.
+// Persisted items are removed from the queue and are
+// recorded by [Monitor.Replayed] when they are replayed.
+func (m *Monitor) Persisted(n int) {
+	m.queued.Add(-int64(n))
+}
+
+// Replayed records n persisted items were replayed.
+// Items are recorded as exported if the err is nil and as
+// failed with the err otherwise. Use [Monitor.Error] for the
+// errors of items which will be replayed again.
+// Replayed items are not removed from the queue
+// because they were removed when persisted.
+func (m *Monitor) Replayed(n int, err error) {
+	if err != nil {
+		m.failed.Add(int64(n))
+		m.Error(err)
+		return
+	}
+	m.exported.Add(int64(n))
+	m.mu.Lock()
+	m.status.LastSuccessTime = time.Now()
+	m.mu.Unlock()
+}
+
+// Error records an export error which is not associated
+// with any items and passes it to the error handler.
+func (m *Monitor) Error(err error) {
+	if err == nil {
+		return
+	}
+	m.mu.Lock()
+	m.status.LastError = err
+	m.status.LastErrorTime = time.Now()
+	m.mu.Unlock()
+	if m.handler != nil {
+		m.handler(err)
+	}
+}
+
+// SetQueueLength overwrites the current queue length.
+// It is used when the queue length is reported by the exporter.
+func (m *Monitor) SetQueueLength(n int) {
+	m.queued.Store(int64(n))
+}
+
+// QueueLength returns the number of items waiting for export.
+func (m *Monitor) QueueLength() int {
+	return int(max(m.queued.Load(), 0))
+}
+
+// Health returns the current health status.
+func (m *Monitor) Health() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Check returns the last export error if the exporter is unhealthy.
+// Check can be used as a health check function.
+func (m *Monitor) Check(_ context.Context) error {
+	s := m.Health()
+	if s.Healthy() {
+		return nil
+	}
+	return fmt.Errorf("%s %s export failed at %s: %w", m.exporter, m.signal, s.LastErrorTime.Format(time.RFC3339), s.LastError)
+}
+
+// ObserveLatency records the latency of an export.
+func (m *Monitor) ObserveLatency(d time.Duration) {
+	m.latency.Observe(d.Seconds())
+	if h := m.otelLatency.Load(); h != nil {
+		(*h).Record(context.Background(), d.Seconds(), metric.WithAttributes(m.attrs()...))
+	}
+}
+
+func (m *Monitor) attrs() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("exporter", m.exporter),
+		attribute.String("signal", m.signal),
+	}
+}
+
+// Describe implements [prometheus.Collector].
+func (m *Monitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.exportedDesc
+	ch <- m.droppedDesc
+	ch <- m.failedDesc
+	ch <- m.queueDesc
+	m.latency.Describe(ch)
+}
+
+// Collect implements [prometheus.Collector].
+func (m *Monitor) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(m.exportedDesc, prometheus.CounterValue, float64(m.exported.Load()))
+	ch <- prometheus.MustNewConstMetric(m.droppedDesc, prometheus.CounterValue, float64(m.dropped.Load()))
+	ch <- prometheus.MustNewConstMetric(m.failedDesc, prometheus.CounterValue, float64(m.failed.Load()))
+	ch <- prometheus.MustNewConstMetric(m.queueDesc, prometheus.GaugeValue, float64(m.QueueLength()))
+	m.latency.Collect(ch)
+}
+
+// RegisterMeter registers the metrics of the monitor to the meter.
+// Call [metric.Registration.Unregister] to stop collecting them.
+func (m *Monitor) RegisterMeter(meter metric.Meter) (metric.Registration, error) {
+	exported, err := meter.Int64ObservableCounter("observability_exporter_exported_total",
+		metric.WithDescription("Number of exported items"))
+	if err != nil {
+		return nil, err
+	}
+	dropped, err := meter.Int64ObservableCounter("observability_exporter_dropped_total",
+		metric.WithDescription("Number of items dropped before export"))
+	if err != nil {
+		return nil, err
+	}
+	failed, err := meter.Int64ObservableCounter("observability_exporter_failed_total",
+		metric.WithDescription("Number of items failed to export"))
+	if err != nil {
+		return nil, err
+	}
+	queue, err := meter.Int64ObservableGauge("observability_exporter_queue_length",
+		metric.WithDescription("Number of items waiting for export"))
+	if err != nil {
+		return nil, err
+	}
+	latency, err := meter.Float64Histogram("observability_exporter_export_duration_seconds",
+		metric.WithDescription("Latency of exports"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	m.otelLatency.Store(&latency)
+
+	opt := metric.WithAttributes(m.attrs()...)
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(exported, m.exported.Load(), opt)
+		o.ObserveInt64(dropped, m.dropped.Load(), opt)
+		o.ObserveInt64(failed, m.failed.Load(), opt)
+		o.ObserveInt64(queue, int64(m.QueueLength()), opt)
+		return nil
+	}, exported, dropped, failed, queue)
+}
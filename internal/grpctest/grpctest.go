@@ -0,0 +1,203 @@
+// Package grpctest provides the in-memory gRPC test service
+// for testing the gRPC interceptors of tracers and metrics.
+package grpctest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	testgrpc "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	// Service is the service name of the test service.
+	Service = "grpc.testing.TestService"
+	// UnaryMethod is the full method name of the unary RPC.
+	UnaryMethod = testgrpc.TestService_UnaryCall_FullMethodName
+	// StreamMethod is the full method name of the bidirectional streaming RPC.
+	StreamMethod = testgrpc.TestService_FullDuplexCall_FullMethodName
+)
+
+// Case is the test case of the interceptors.
+type Case struct {
+	// Name is the name of the case.
+	Name string
+	// Stream, if true, calls the streaming RPC
+	// with 3 messages instead of the unary RPC.
+	Stream bool
+	// Code is the status code replied by the server.
+	Code codes.Code
+	// FullMethod is the full method name of the RPC.
+	FullMethod string
+	// Method is the method name of the RPC.
+	Method string
+	// ClientSent and ClientReceived are the number of
+	// messages sent and received by the client.
+	ClientSent, ClientReceived int64
+	// ServerSent and ServerReceived are the number of
+	// messages sent and received by the server.
+	ServerSent, ServerReceived int64
+}
+
+// Cases is the list of the unary and streaming
+// RPCs succeeded and failed.
+var Cases = []Case{
+	{"unary", false, codes.OK, UnaryMethod, "UnaryCall", 1, 1, 1, 1},
+	{"unary error", false, codes.NotFound, UnaryMethod, "UnaryCall", 1, 0, 0, 1},
+	{"stream", true, codes.OK, StreamMethod, "FullDuplexCall", 3, 3, 3, 3},
+	{"stream error", true, codes.Internal, StreamMethod, "FullDuplexCall", 3, 2, 2, 3},
+}
+
+// Server is the test service which records the incoming metadata.
+type Server struct {
+	testgrpc.UnimplementedTestServiceServer
+	mu sync.Mutex
+	md []metadata.MD
+}
+
+func (s *Server) UnaryCall(ctx context.Context, req *testgrpc.SimpleRequest) (*testgrpc.SimpleResponse, error) {
+	s.record(ctx)
+	if st := req.GetResponseStatus(); st.GetCode() != 0 {
+		return nil, status.Error(codes.Code(st.GetCode()), st.GetMessage())
+	}
+	return &testgrpc.SimpleResponse{}, nil
+}
+
+func (s *Server) FullDuplexCall(stream testgrpc.TestService_FullDuplexCallServer) error {
+	s.record(stream.Context())
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if st := req.GetResponseStatus(); st.GetCode() != 0 {
+			return status.Error(codes.Code(st.GetCode()), st.GetMessage())
+		}
+		if err := stream.Send(&testgrpc.StreamingOutputCallResponse{}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) record(ctx context.Context) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.md = append(s.md, md.Copy())
+}
+
+// MD returns the incoming metadata of the calls in the order received.
+func (s *Server) MD() []metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]metadata.MD(nil), s.md...)
+}
+
+// Client is the client of the test service.
+type Client struct {
+	client testgrpc.TestServiceClient
+}
+
+// Start starts the test service on an in-memory listener with the server
+// options and returns the server and the client dialed with the dial options.
+// They are stopped when the test finished.
+func Start(tb testing.TB, sopts []grpc.ServerOption, dopts []grpc.DialOption) (*Server, *Client) {
+	tb.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := &Server{}
+	gs := grpc.NewServer(sopts...)
+	testgrpc.RegisterTestServiceServer(gs, srv)
+	go gs.Serve(lis)
+	tb.Cleanup(gs.Stop)
+
+	dopts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, dopts...)
+	cc, err := grpc.NewClient("passthrough:///bufconn", dopts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { cc.Close() })
+	return srv, &Client{client: testgrpc.NewTestServiceClient(cc)}
+}
+
+// Call calls the RPC of the test case with the ctx.
+// The test fails if the status code is not the one of the case.
+func (c *Client) Call(tb testing.TB, ctx context.Context, tc Case) {
+	tb.Helper()
+	var err error
+	if tc.Stream {
+		_, err = c.Stream(ctx, 3, tc.Code)
+	} else {
+		err = c.Unary(ctx, tc.Code)
+	}
+	if status.Code(err) != tc.Code {
+		tb.Fatalf("grpctest: want code %s, got %v", tc.Code, err)
+	}
+}
+
+// Unary calls the unary RPC.
+// The server replies with the status code.
+func (c *Client) Unary(ctx context.Context, code codes.Code) error {
+	_, err := c.client.UnaryCall(ctx, &testgrpc.SimpleRequest{
+		ResponseStatus: echoStatus(code),
+	})
+	return err
+}
+
+// Stream sends n messages with the bidirectional streaming RPC
+// and receives the responses until the stream ends.
+// The server replies to the last message with the status code
+// and to other messages with a response.
+// It returns the number of received responses and the status error.
+func (c *Client) Stream(ctx context.Context, n int, code codes.Code) (int, error) {
+	stream, err := c.client.FullDuplexCall(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := range n {
+		req := &testgrpc.StreamingOutputCallRequest{}
+		if i == n-1 {
+			req.ResponseStatus = echoStatus(code)
+		}
+		if err := stream.Send(req); err != nil {
+			break // The status is returned from Recv.
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return 0, err
+	}
+	received := 0
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return received, nil
+			}
+			return received, err
+		}
+		received++
+	}
+}
+
+func echoStatus(code codes.Code) *testgrpc.EchoStatus {
+	if code == codes.OK {
+		return nil
+	}
+	return &testgrpc.EchoStatus{Code: int32(code), Message: code.String()}
+}
@@ -0,0 +1,232 @@
+// Package grpcx provides helpers shared by the gRPC interceptors
+// of tracers and metrics.
+package grpcx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MDCarrier is the metadata carrier for context propagation.
+// It implements the TextMapCarrier of opentelemetry
+// and the TextMapReader and TextMapWriter of opentracing.
+type MDCarrier metadata.MD
+
+// Get returns the first value of the key.
+func (c MDCarrier) Get(key string) string {
+	if vs := metadata.MD(c).Get(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// Set sets the value of the key.
+func (c MDCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns all the keys in the metadata.
+func (c MDCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ForeachKey calls the handler for all key-value pairs.
+func (c MDCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vs := range c {
+		for _, v := range vs {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IncomingMD returns the copy of the incoming metadata of the ctx.
+// An empty metadata is returned if not found.
+func IncomingMD(ctx context.Context) metadata.MD {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return metadata.MD{}
+	}
+	return md.Copy()
+}
+
+// OutgoingMD returns the copy of the outgoing metadata of the ctx.
+// An empty metadata is returned if not found.
+// Use [metadata.NewOutgoingContext] to set the returned metadata after modification.
+func OutgoingMD(ctx context.Context) metadata.MD {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return metadata.MD{}
+	}
+	return md.Copy()
+}
+
+// SplitMethod splits the full method name "/package.service/method"
+// into the service and method name.
+func SplitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// Info is the information of a finished RPC.
+type Info struct {
+	// FullMethod is the full method name.
+	FullMethod string
+	// Service is the service name in the FullMethod.
+	Service string
+	// Method is the method name in the FullMethod.
+	Method string
+	// Code is the status code of the RPC.
+	Code string
+	// Err is the error of the RPC.
+	// It is nil for successful RPCs.
+	Err error
+	// Sent is the number of messages sent.
+	Sent int64
+	// Received is the number of messages received.
+	Received int64
+}
+
+// NewInfo returns a new info of the RPC.
+// The io.EOF returned from streams is not considered as an error.
+func NewInfo(fullMethod string, err error, sent, received int64) *Info {
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	service, method := SplitMethod(fullMethod)
+	return &Info{
+		FullMethod: fullMethod,
+		Service:    service,
+		Method:     method,
+		Code:       status.Code(err).String(),
+		Err:        err,
+		Sent:       sent,
+		Received:   received,
+	}
+}
+
+// NewUnaryInfo returns a new info of the unary RPC.
+// The response message is not counted if the err is not nil.
+func NewUnaryInfo(fullMethod string, err error, server bool) *Info {
+	var resp int64
+	if err == nil {
+		resp = 1
+	}
+	if server {
+		return NewInfo(fullMethod, err, resp, 1)
+	}
+	return NewInfo(fullMethod, err, 1, resp)
+}
+
+// ServerStream wraps the [grpc.ServerStream] to replace the context
+// and count messages.
+type ServerStream struct {
+	grpc.ServerStream
+	Ctx      context.Context
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func (s *ServerStream) Context() context.Context {
+	return s.Ctx
+}
+
+func (s *ServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	}
+	return err
+}
+
+func (s *ServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Add(1)
+	}
+	return err
+}
+
+// Sent returns the number of messages sent.
+func (s *ServerStream) Sent() int64 {
+	return s.sent.Load()
+}
+
+// Received returns the number of messages received.
+func (s *ServerStream) Received() int64 {
+	return s.received.Load()
+}
+
+// ClientStream wraps the [grpc.ClientStream] to count messages
+// and to call the finish function once when the stream ended.
+// A stream ends when receiving a message failed, including io.EOF,
+// or when the response was received for non server streaming RPCs.
+type ClientStream struct {
+	grpc.ClientStream
+	desc     *grpc.StreamDesc
+	finish   func(err error, sent, received int64)
+	once     sync.Once
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// NewClientStream returns a new client stream.
+func NewClientStream(cs grpc.ClientStream, desc *grpc.StreamDesc, finish func(err error, sent, received int64)) *ClientStream {
+	return &ClientStream{ClientStream: cs, desc: desc, finish: finish}
+}
+
+func (s *ClientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	} else if !errors.Is(err, io.EOF) {
+		// io.EOF is returned when the stream was closed by the server.
+		// The status is available from RecvMsg.
+		s.end(err)
+	}
+	return err
+}
+
+func (s *ClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.end(err)
+		return err
+	}
+	s.received.Add(1)
+	if !s.desc.ServerStreams {
+		s.end(nil)
+	}
+	return nil
+}
+
+func (s *ClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func (s *ClientStream) end(err error) {
+	s.once.Do(func() {
+		s.finish(err, s.sent.Load(), s.received.Load())
+	})
+}
@@ -0,0 +1,137 @@
+package grpcx_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpctest"
+	"github.com/aileron-projects/aileron-observability/internal/grpcx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// recorder records the infos of the finished RPCs
+// with the interceptors using the grpcx helpers.
+type recorder struct {
+	mu     sync.Mutex
+	server []*grpcx.Info
+	client []*grpcx.Info
+}
+
+func (r *recorder) add(infos *[]*grpcx.Info, info *grpcx.Info) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*infos = append(*infos, info)
+}
+
+func (r *recorder) last() (server, client *grpcx.Info) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.server[len(r.server)-1], r.client[len(r.client)-1]
+}
+
+func (r *recorder) start(t *testing.T) (*grpctest.Server, *grpctest.Client) {
+	t.Helper()
+	unaryServer := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		r.add(&r.server, grpcx.NewUnaryInfo(info.FullMethod, err, true))
+		return resp, err
+	}
+	streamServer := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ws := &grpcx.ServerStream{ServerStream: ss, Ctx: ss.Context()}
+		err := handler(srv, ws)
+		r.add(&r.server, grpcx.NewInfo(info.FullMethod, err, ws.Sent(), ws.Received()))
+		return err
+	}
+	unaryClient := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md := grpcx.OutgoingMD(ctx)
+		grpcx.MDCarrier(md).Set("x-test", "unary")
+		err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+		r.add(&r.client, grpcx.NewUnaryInfo(method, err, false))
+		return err
+	}
+	streamClient := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md := grpcx.OutgoingMD(ctx)
+		grpcx.MDCarrier(md).Set("x-test", "stream")
+		cs, err := streamer(metadata.NewOutgoingContext(ctx, md), desc, cc, method, opts...)
+		if err != nil {
+			r.add(&r.client, grpcx.NewInfo(method, err, 0, 0))
+			return nil, err
+		}
+		return grpcx.NewClientStream(cs, desc, func(err error, sent, received int64) {
+			r.add(&r.client, grpcx.NewInfo(method, err, sent, received))
+		}), nil
+	}
+	return grpctest.Start(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(unaryServer), grpc.StreamInterceptor(streamServer)},
+		[]grpc.DialOption{grpc.WithUnaryInterceptor(unaryClient), grpc.WithStreamInterceptor(streamClient)},
+	)
+}
+
+func TestInterceptors(t *testing.T) {
+	for _, tc := range grpctest.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := &recorder{}
+			srv, client := r.start(t)
+			client.Call(t, context.Background(), tc)
+			want := "unary"
+			if tc.Stream {
+				want = "stream"
+			}
+			if got := grpcx.MDCarrier(srv.MD()[0]).Get("x-test"); got != want {
+				t.Errorf("metadata not propagated: want %q, got %q", want, got)
+			}
+			si, ci := r.last()
+			checkInfo(t, "server", si, tc, tc.ServerSent, tc.ServerReceived)
+			checkInfo(t, "client", ci, tc, tc.ClientSent, tc.ClientReceived)
+		})
+	}
+}
+
+func checkInfo(t *testing.T, side string, info *grpcx.Info, tc grpctest.Case, sent, received int64) {
+	t.Helper()
+	if info.FullMethod != tc.FullMethod || info.Service != grpctest.Service || info.Method != tc.Method {
+		t.Errorf("%s: unexpected method %s", side, info.FullMethod)
+	}
+	if info.Code != tc.Code.String() || (info.Err != nil) != (tc.Code != codes.OK) {
+		t.Errorf("%s: want code %s, got %s %v", side, tc.Code, info.Code, info.Err)
+	}
+	if info.Sent != sent || info.Received != received {
+		t.Errorf("%s: want %d sent and %d received, got %d and %d", side, sent, received, info.Sent, info.Received)
+	}
+}
+
+func TestNewInfo(t *testing.T) {
+	info := grpcx.NewInfo("/pkg.Service/Method", io.EOF, 1, 2)
+	if info.Err != nil || info.Code != codes.OK.String() {
+		t.Errorf("io.EOF must not be an error: %v %s", info.Err, info.Code)
+	}
+	if info.Service != "pkg.Service" || info.Method != "Method" {
+		t.Errorf("unexpected method %s %s", info.Service, info.Method)
+	}
+	if service, method := grpcx.SplitMethod("Method"); service != "" || method != "Method" {
+		t.Errorf("unexpected method %s %s", service, method)
+	}
+}
+
+func TestMDCarrier(t *testing.T) {
+	c := grpcx.MDCarrier(metadata.MD{})
+	c.Set("Foo", "bar")
+	if got := c.Get("foo"); got != "bar" {
+		t.Errorf("want bar, got %q", got)
+	}
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "foo" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	n := 0
+	_ = c.ForeachKey(func(key, val string) error {
+		n++
+		return nil
+	})
+	if n != 1 {
+		t.Errorf("want 1 pair, got %d", n)
+	}
+}
@@ -0,0 +1,79 @@
+// Package logging provides the [slog.Handler] that adds
+// observability information in the context to log records.
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aileron-projects/aileron-observability/requestid"
+	"github.com/aileron-projects/aileron-observability/tracing"
+)
+
+var (
+	_ slog.Handler = &Handler{}
+)
+
+// Config is the configuration for the [Handler].
+type Config struct {
+	// BaggageKeys is the list of baggage keys added to
+	// log records as attributes.
+	// Keys not found in the baggage are not added.
+	BaggageKeys []string
+	// RequestID, if true, adds the request id assigned by
+	// the [requestid.Middleware] as the "request_id" attribute.
+	RequestID bool
+}
+
+// Handler is the [slog.Handler] that adds observability
+// information in the context to log records.
+// Use the context-aware methods such as [slog.Logger.InfoContext]
+// to pass the context to the handler.
+type Handler struct {
+	next        slog.Handler
+	baggageKeys []string
+	requestID   bool
+}
+
+// NewHandler returns a new [Handler] which
+// passes log records to the next handler.
+func NewHandler(next slog.Handler, c *Config) *Handler {
+	return &Handler{
+		next:        next,
+		baggageKeys: c.BaggageKeys,
+		requestID:   c.RequestID,
+	}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var id string
+	if h.requestID {
+		id = requestid.FromContext(ctx)
+	}
+	bg := tracing.SelectBaggage(ctx, h.baggageKeys)
+	if id == "" && len(bg) == 0 {
+		return h.next.Handle(ctx, r)
+	}
+	r = r.Clone()
+	if id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	for _, k := range h.baggageKeys {
+		if v, ok := bg[k]; ok {
+			r.AddAttrs(slog.String(k, v))
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), baggageKeys: h.baggageKeys, requestID: h.requestID}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), baggageKeys: h.baggageKeys, requestID: h.requestID}
+}
@@ -9,5 +9,5 @@ import (
 type MetricsMiddleware interface {
 	zhttp.ServerMiddleware
 	zhttp.ClientMiddleware
-	Finalize(context.Context)
+	Finalize(context.Context) error
 }
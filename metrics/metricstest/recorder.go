@@ -0,0 +1,183 @@
+// Package metricstest provides an in-memory metrics middleware
+// for testing code that depends on [metrics.MetricsMiddleware].
+package metricstest
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/metrics"
+	"github.com/aileron-projects/go/znet/zhttp"
+)
+
+var (
+	_ zhttp.ServerMiddleware    = &Recorder{}
+	_ zhttp.ClientMiddleware    = &Recorder{}
+	_ metrics.MetricsMiddleware = &Recorder{}
+)
+
+// Metric names recorded by the middleware of the [Recorder].
+// Counter names and labels are the same as the prometheus metrics middleware.
+const (
+	ServerRequests = "http_requests_total"
+	ClientRequests = "http_client_requests_total"
+	ServerDuration = "http_request_duration_seconds"
+	ClientDuration = "http_client_request_duration_seconds"
+)
+
+// Observation is a recorded value.
+type Observation struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// hasLabels returns true if the observation has all the labels.
+func (o *Observation) hasLabels(labels map[string]string) bool {
+	for k, v := range labels {
+		if got, ok := o.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Recorder is the metrics middleware that records
+// counters and histograms in memory.
+// The server-side and client-side middleware count requests
+// with "method", "host", "path" and "code" labels
+// and observe the request duration in seconds.
+// Use [NewRecorder] to create a new instance.
+type Recorder struct {
+	mu         sync.Mutex
+	counters   []Observation
+	histograms []Observation
+	finalized  bool
+}
+
+// NewRecorder returns a new [Recorder].
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) ServerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ww := zhttp.WrapResponseWriter(w)
+		defer func() {
+			labels := map[string]string{
+				"method": req.Method,
+				"host":   req.Host,
+				"path":   req.URL.Path,
+				"code":   strconv.Itoa(ww.StatusCode()),
+			}
+			r.Add(ServerRequests, labels, 1)
+			r.Observe(ServerDuration, labels, time.Since(start).Seconds())
+		}()
+		next.ServeHTTP(ww, req)
+	})
+}
+
+func (r *Recorder) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	return zhttp.RoundTripperFunc(func(req *http.Request) (resp *http.Response, err error) {
+		start := time.Now()
+		defer func() {
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			labels := map[string]string{
+				"method": req.Method,
+				"host":   req.URL.Host,
+				"path":   req.URL.Path,
+				"code":   strconv.Itoa(status),
+			}
+			r.Add(ClientRequests, labels, 1)
+			r.Observe(ClientDuration, labels, time.Since(start).Seconds())
+		}()
+		return next.RoundTrip(req)
+	})
+}
+
+// Finalize marks the recorder finalized.
+// Recorded values are kept.
+func (r *Recorder) Finalize(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finalized = true
+	return nil
+}
+
+// Finalized returns true if the Finalize was called.
+func (r *Recorder) Finalized() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finalized
+}
+
+// Add records the value to the counter.
+func (r *Recorder) Add(name string, labels map[string]string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, Observation{Name: name, Labels: maps.Clone(labels), Value: value})
+}
+
+// Observe records the value to the histogram.
+func (r *Recorder) Observe(name string, labels map[string]string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histograms = append(r.histograms, Observation{Name: name, Labels: maps.Clone(labels), Value: value})
+}
+
+// Counters returns all the recorded counter values in the order recorded.
+func (r *Recorder) Counters() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.counters)
+}
+
+// Histograms returns all the recorded histogram values in the order recorded.
+func (r *Recorder) Histograms() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.histograms)
+}
+
+// CounterValue returns the sum of the counter values
+// which have all of the labels.
+// Empty labels match all values of the counter.
+func (r *Recorder) CounterValue(name string, labels map[string]string) float64 {
+	var sum float64
+	for _, o := range r.Counters() {
+		if o.Name == name && o.hasLabels(labels) {
+			sum += o.Value
+		}
+	}
+	return sum
+}
+
+// HistogramValues returns the observed values of the histogram
+// which have all of the labels in the order observed.
+// Empty labels match all values of the histogram.
+func (r *Recorder) HistogramValues(name string, labels map[string]string) []float64 {
+	var vals []float64
+	for _, o := range r.Histograms() {
+		if o.Name == name && o.hasLabels(labels) {
+			vals = append(vals, o.Value)
+		}
+	}
+	return vals
+}
+
+// Reset removes all recorded values.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = nil
+	r.histograms = nil
+}
@@ -0,0 +1,106 @@
+package metricstest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRecorder_middleware(t *testing.T) {
+	rec := NewRecorder()
+	svr := httptest.NewServer(rec.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})))
+	defer svr.Close()
+	client := &http.Client{Transport: rec.ClientMiddleware(http.DefaultTransport)}
+	u, _ := url.Parse(svr.URL)
+
+	for _, path := range []string{"/users", "/users", "/missing"} {
+		res, err := client.Get(svr.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	testCases := map[string]struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		"all server requests": {ServerRequests, nil, 3},
+		"all client requests": {ClientRequests, map[string]string{}, 3},
+		"server path":         {ServerRequests, map[string]string{"path": "/users"}, 2},
+		"server labels": {ServerRequests, map[string]string{
+			"method": http.MethodGet, "host": u.Host, "path": "/users", "code": "200",
+		}, 2},
+		"client labels": {ClientRequests, map[string]string{
+			"method": http.MethodGet, "host": u.Host, "path": "/missing", "code": "404",
+		}, 1},
+		"unknown label value": {ServerRequests, map[string]string{"code": "500"}, 0},
+		"unknown label key":   {ServerRequests, map[string]string{"unknown": ""}, 0},
+		"unknown name":        {"unknown", nil, 0},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := rec.CounterValue(tc.name, tc.labels); got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+	if n := len(rec.HistogramValues(ServerDuration, map[string]string{"code": "404"})); n != 1 {
+		t.Errorf("want 1 server duration, got %d", n)
+	}
+	if n := len(rec.HistogramValues(ClientDuration, nil)); n != 3 {
+		t.Errorf("want 3 client durations, got %d", n)
+	}
+}
+
+func TestRecorder_clientError(t *testing.T) {
+	rec := NewRecorder()
+	client := &http.Client{Transport: rec.ClientMiddleware(http.DefaultTransport)}
+	if _, err := client.Get("http://127.0.0.1:0/"); err == nil {
+		t.Fatal("want an error")
+	}
+	if got := rec.CounterValue(ClientRequests, map[string]string{"code": "0"}); got != 1 {
+		t.Errorf("failed request must be counted with code 0, got %v", got)
+	}
+}
+
+func TestRecorder_CounterValue(t *testing.T) {
+	rec := NewRecorder()
+	labels := map[string]string{"a": "1"}
+	rec.Add("counter", labels, 1.5)
+	labels["a"] = "2" // Labels are copied.
+	rec.Add("counter", labels, 2)
+	rec.Add("other", labels, 10)
+	rec.Observe("counter", labels, 100)
+
+	if got := rec.CounterValue("counter", nil); got != 3.5 {
+		t.Errorf("want 3.5, got %v", got)
+	}
+	if got := rec.CounterValue("counter", map[string]string{"a": "1"}); got != 1.5 {
+		t.Errorf("want 1.5, got %v", got)
+	}
+	if got := rec.HistogramValues("counter", nil); len(got) != 1 || got[0] != 100 {
+		t.Errorf("want [100], got %v", got)
+	}
+
+	rec.Reset()
+	if got := rec.CounterValue("counter", nil); got != 0 || len(rec.Counters()) != 0 || len(rec.Histograms()) != 0 {
+		t.Error("values must be removed after reset")
+	}
+	if rec.Finalized() {
+		t.Error("must not be finalized")
+	}
+	_ = rec.Finalize(context.Background())
+	if !rec.Finalized() {
+		t.Error("must be finalized")
+	}
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+)
+
+var (
+	_ MetricsMiddleware = Noop{}
+)
+
+// Noop is the [MetricsMiddleware] that does nothing.
+// It can be used in place of real metrics middleware
+// when metrics are disabled.
+type Noop struct{}
+
+func (Noop) ServerMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func (Noop) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	return next
+}
+
+// Finalize does nothing and always returns nil.
+func (Noop) Finalize(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package otel
+
+import (
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// maxCachedSets is the maximum number of
+	// attribute sets cached in a [setCache].
+	maxCachedSets = 4096
+	// maxCachedBaggage is the maximum number of baggage
+	// keys of the attribute sets that can be cached.
+	maxCachedBaggage = 4
+)
+
+// setKey is the key of the cached attribute sets.
+// Attribute values are stored in the fixed size fields
+// so that the key can be used without allocations.
+type setKey struct {
+	method  string
+	host    string
+	path    string
+	code    int
+	baggage [maxCachedBaggage]string
+}
+
+// clone returns the copy of the k whose strings
+// do not share the memory of the request.
+func (k setKey) clone() setKey {
+	k.method = strings.Clone(k.method)
+	k.host = strings.Clone(k.host)
+	k.path = strings.Clone(k.path)
+	for i := range k.baggage {
+		k.baggage[i] = strings.Clone(k.baggage[i])
+	}
+	return k
+}
+
+// setCache caches the measurement options of attribute sets
+// so that the sets are not built and sorted on every measurement.
+// At most size sets are cached and
+// sets are built on every call once the cache is full.
+type setCache struct {
+	mu   sync.RWMutex
+	size int
+	opts map[setKey][]metric.AddOption
+}
+
+// newSetCache returns a new empty [setCache]
+// which caches at most size sets.
+func newSetCache(size int) *setCache {
+	return &setCache{
+		size: size,
+		opts: map[setKey][]metric.AddOption{},
+	}
+}
+
+// options returns the options with the attribute set of the k.
+// The attrs is called to build the set if it is not cached.
+// Returned options must not be modified.
+func (c *setCache) options(k setKey, attrs func() []attribute.KeyValue) []metric.AddOption {
+	c.mu.RLock()
+	opts, ok := c.opts[k]
+	c.mu.RUnlock()
+	if ok {
+		return opts
+	}
+	opts = []metric.AddOption{metric.WithAttributeSet(attribute.NewSet(attrs()...))}
+	c.mu.Lock()
+	if len(c.opts) < c.size {
+		c.opts[k.clone()] = opts
+	}
+	c.mu.Unlock()
+	return opts
+}
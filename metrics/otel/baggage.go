@@ -0,0 +1,31 @@
+package otel
+
+import (
+	"net/http"
+
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// baggageAttributes returns the attributes of the baggage keys.
+// Empty values are used for the keys not found in the baggage.
+// Baggage in the request headers is used if the
+// context of the r has no baggage.
+// Values are redacted by the rd with the baggage keys
+// and dropped values are replaced with empty values.
+func baggageAttributes(keys []string, r *http.Request, extract bool, rd *redact.Redactor) []attribute.KeyValue {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx := r.Context()
+	if extract && len(tracing.BaggageFromContext(ctx)) == 0 {
+		ctx = tracing.ExtractBaggage(ctx, tracing.HeaderCarrier(r.Header))
+	}
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		v, _ := rd.Attribute(k, tracing.GetBaggage(ctx, k))
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs
+}
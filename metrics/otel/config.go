@@ -2,8 +2,12 @@ package otel
 
 import (
 	"cmp"
+	"context"
+	"slices"
 	"time"
 
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/redact"
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
@@ -26,11 +30,46 @@ type Config struct {
 	// MeterOpts is the options used when creating
 	// a meter from provider.
 	MeterOpts []metric.MeterOption
+	// Exporters is the list of metric exporters.
+	// Each exporter is registered with a periodic reader
+	// configured by ReaderOpts and monitored by the [Metrics.Monitor].
+	// Readers registered through ProviderOpts are not monitored.
+	Exporters []sdkmetric.Exporter
+	// ReaderOpts is the options for periodic readers
+	// created for the Exporters.
+	ReaderOpts []sdkmetric.PeriodicReaderOption
+	// ErrorHandler handles errors returned from the Exporters.
+	// Errors are not passed to the global OpenTelemetry
+	// error handler even if ErrorHandler is nil.
+	ErrorHandler health.ErrorHandler
+	// BaggageKeys is the list of baggage keys added to
+	// the attributes of http request counters.
+	BaggageKeys []string
+	// Redactor redacts the "host", "path" and baggage attribute values
+	// of http request counters. Paths are redacted with
+	// [redact.Redactor.Path] and baggage values are redacted
+	// with the baggage keys. If nil, values are not redacted.
+	Redactor *redact.Redactor
+	// RecordPanics, if true, records panics in the server middleware
+	// with the "http_server_panics_total" counter and counts the requests
+	// with the status code 500, and then panics again with the [recovery.Panic].
+	// Use the [recovery.Middleware] to reply 500 or to re-panic.
+	RecordPanics bool
 }
 
 func New(c *Config) (*Metrics, error) {
 	service := sdkmetric.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cmp.Or(c.ServiceName, "aileron"))))
-	provider := sdkmetric.NewMeterProvider(append(c.ProviderOpts, service)...)
+	monitor := health.NewMonitor(&health.Config{
+		Exporter:     "otel",
+		Signal:       "points",
+		ErrorHandler: c.ErrorHandler,
+	})
+	opts := slices.Concat(c.ProviderOpts, []sdkmetric.Option{service})
+	for _, exp := range c.Exporters {
+		exp = &monitoredExporter{Exporter: exp, monitor: monitor}
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, c.ReaderOpts...)))
+	}
+	provider := sdkmetric.NewMeterProvider(opts...)
 	_ = runtime.Start(
 		runtime.WithMeterProvider(provider),
 		runtime.WithMinimumReadMemStatsInterval(time.Second),
@@ -45,10 +84,40 @@ func New(c *Config) (*Metrics, error) {
 		"http_client_requests_total",
 		metric.WithDescription("Total number of sent http requests"),
 	)
+	panicCounter, _ := meter.Int64Counter(
+		"http_server_panics_total",
+		metric.WithDescription("Total number of panics in http handlers"),
+	)
+
+	grpcServer, err := newGRPCMetrics(meter, "server")
+	if err != nil {
+		_ = provider.Shutdown(context.Background())
+		return nil, err
+	}
+	grpcClient, err := newGRPCMetrics(meter, "client")
+	if err != nil {
+		_ = provider.Shutdown(context.Background())
+		return nil, err
+	}
+
+	if _, err := monitor.RegisterMeter(meter); err != nil {
+		_ = provider.Shutdown(context.Background())
+		return nil, err
+	}
 
 	return &Metrics{
 		provider:      provider,
+		monitor:       monitor,
 		serverCounter: serverCounter,
 		clientCounter: clientCounter,
+		panicCounter:  panicCounter,
+		recordPanics:  c.RecordPanics,
+		grpcServer:    grpcServer,
+		grpcClient:    grpcClient,
+		baggageKeys:   c.BaggageKeys,
+		redactor:      c.Redactor,
+		serverSets:    newSetCache(maxCachedSets),
+		clientSets:    newSetCache(maxCachedSets),
+		panicSets:     newSetCache(maxCachedSets),
 	}, nil
 }
@@ -0,0 +1,150 @@
+package otel
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Environment variables read by [FromEnv].
+// See https://opentelemetry.io/docs/specs/otel/configuration/sdk-environment-variables/
+const (
+	EnvServiceName          = "OTEL_SERVICE_NAME"
+	EnvMetricsExporter      = "OTEL_METRICS_EXPORTER"
+	EnvOTLPProtocol         = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	EnvOTLPMetricsProtocol  = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
+	EnvMetricExportInterval = "OTEL_METRIC_EXPORT_INTERVAL"
+	EnvMetricExportTimeout  = "OTEL_METRIC_EXPORT_TIMEOUT"
+)
+
+// FromEnv returns a new [Config] built from the OTEL_* environment variables.
+// Values already set in c take precedence over the environment.
+// c can be nil and is not modified.
+//
+// Following variables are read:
+//
+//   - OTEL_SERVICE_NAME: used when c.ServiceName is empty.
+//   - OTEL_METRICS_EXPORTER: comma separated list of "otlp", "console", "stdout" or "none".
+//   - OTEL_EXPORTER_OTLP_METRICS_PROTOCOL, OTEL_EXPORTER_OTLP_PROTOCOL: "grpc" or "http/protobuf" (default).
+//   - OTEL_METRIC_EXPORT_INTERVAL: export interval in milliseconds.
+//   - OTEL_METRIC_EXPORT_TIMEOUT: export timeout in milliseconds.
+//
+// Endpoints, headers, timeouts and TLS settings of the OTLP exporters
+// are read from the OTEL_EXPORTER_OTLP_* variables by the exporters themselves.
+// Exporters are prepended to c.Exporters and registered with [sdkmetric.PeriodicReader].
+// When OTEL_METRICS_EXPORTER is not set, the "otlp" exporter is used
+// only if both c.Exporters and c.ProviderOpts are empty because
+// readers registered through the provider options cannot be detected.
+// Reader options built from the environment are placed before c.ReaderOpts
+// so the explicitly given options override them.
+// Exporters created by FromEnv are shut down if it returns an error.
+func FromEnv(ctx context.Context, c *Config) (*Config, error) {
+	cc := &Config{}
+	if c != nil {
+		*cc = *c
+	}
+	cc.ServiceName = cmp.Or(cc.ServiceName, os.Getenv(EnvServiceName))
+
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	interval, err := millisFromEnv(EnvMetricExportInterval)
+	if err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(interval))
+	}
+	timeout, err := millisFromEnv(EnvMetricExportTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithTimeout(timeout))
+	}
+
+	// Exporters are created last so that
+	// they are not leaked by the errors above.
+	names := os.Getenv(EnvMetricsExporter)
+	if names == "" && len(cc.Exporters) == 0 && len(cc.ProviderOpts) == 0 {
+		names = "otlp"
+	}
+	exporters, err := exportersFromEnv(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	cc.Exporters = slices.Concat(exporters, cc.Exporters)
+	cc.ReaderOpts = slices.Concat(readerOpts, cc.ReaderOpts)
+	return cc, nil
+}
+
+// exportersFromEnv returns metric exporters of the comma separated
+// names given by the OTEL_METRICS_EXPORTER environment variable.
+// Created exporters are shut down if an error occurred.
+func exportersFromEnv(ctx context.Context, names string) (exporters []sdkmetric.Exporter, err error) {
+	defer func() {
+		if err != nil {
+			for _, exp := range exporters {
+				_ = exp.Shutdown(context.Background())
+			}
+		}
+	}()
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "otlp":
+			exp, err := otlpExporterFromEnv(ctx)
+			if err != nil {
+				return exporters, err
+			}
+			exporters = append(exporters, exp)
+		case "console", "stdout":
+			exp, err := stdoutmetric.New()
+			if err != nil {
+				return exporters, err
+			}
+			exporters = append(exporters, exp)
+		case "none", "":
+		default:
+			return exporters, fmt.Errorf("otel: unsupported %s value %q", EnvMetricsExporter, name)
+		}
+	}
+	return exporters, nil
+}
+
+// otlpExporterFromEnv returns an OTLP metric exporter which uses the
+// protocol defined by the OTEL_EXPORTER_OTLP_METRICS_PROTOCOL or
+// OTEL_EXPORTER_OTLP_PROTOCOL environment variable.
+func otlpExporterFromEnv(ctx context.Context) (sdkmetric.Exporter, error) {
+	protocol := cmp.Or(os.Getenv(EnvOTLPMetricsProtocol), os.Getenv(EnvOTLPProtocol), "http/protobuf")
+	switch strings.ToLower(strings.TrimSpace(protocol)) {
+	case "grpc":
+		return otlpmetricgrpc.New(ctx)
+	case "http/protobuf":
+		return otlpmetrichttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("otel: unsupported otlp protocol %q", protocol)
+	}
+}
+
+// millisFromEnv returns the duration defined by the environment
+// variable of the key in milliseconds.
+// It returns 0 when the variable is not set.
+func millisFromEnv(key string) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("otel: invalid %s value %q", key, v)
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
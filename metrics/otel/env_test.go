@@ -0,0 +1,65 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestFromEnv_exporters(t *testing.T) {
+	explicit, _ := stdoutmetric.New()
+	reader := sdkmetric.NewManualReader()
+	testCases := map[string]struct {
+		env  string
+		c    *Config
+		want int
+	}{
+		"default":            {"", nil, 1},
+		"explicit exporters": {"", &Config{Exporters: []sdkmetric.Exporter{explicit}}, 1},
+		"explicit readers":   {"", &Config{ProviderOpts: []sdkmetric.Option{sdkmetric.WithReader(reader)}}, 0},
+		"env with exporters": {"otlp", &Config{Exporters: []sdkmetric.Exporter{explicit}}, 2},
+		"env none":           {"none", nil, 0},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(EnvMetricsExporter, tc.env)
+			t.Setenv(EnvOTLPMetricsProtocol, "http/protobuf")
+			c, err := FromEnv(context.Background(), tc.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Exporters) != tc.want {
+				t.Fatalf("want %d exporters, got %d", tc.want, len(c.Exporters))
+			}
+			for i, exp := range c.Exporters {
+				if _, ok := exp.(*otlpmetrichttp.Exporter); ok {
+					_ = exp.Shutdown(context.Background())
+				} else if exp != explicit || i != len(c.Exporters)-1 {
+					t.Errorf("explicit exporter must be the last, got %T at %d", exp, i)
+				}
+			}
+		})
+	}
+}
+
+func TestFromEnv_error(t *testing.T) {
+	testCases := map[string]map[string]string{
+		"unsupported exporter": {EnvMetricsExporter: "otlp,unknown"},
+		"unsupported protocol": {EnvMetricsExporter: "otlp", EnvOTLPMetricsProtocol: "unknown"},
+		"invalid interval":     {EnvMetricExportInterval: "1s"},
+		"negative timeout":     {EnvMetricExportTimeout: "-1"},
+	}
+	for name, env := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if c, err := FromEnv(context.Background(), nil); err == nil {
+				t.Errorf("want an error, got %v", c)
+			}
+		})
+	}
+}
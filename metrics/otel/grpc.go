@@ -0,0 +1,112 @@
+package otel
+
+import (
+	"context"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpcx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+// grpcMetrics is the metrics of gRPC interceptors.
+type grpcMetrics struct {
+	handled  metric.Int64Counter
+	handling metric.Float64Histogram
+	sent     metric.Int64Counter
+	received metric.Int64Counter
+}
+
+// newGRPCMetrics returns new gRPC metrics.
+// The side is "server" or "client".
+func newGRPCMetrics(meter metric.Meter, side string) (*grpcMetrics, error) {
+	handled, err := meter.Int64Counter("grpc_"+side+"_handled_total",
+		metric.WithDescription("Total number of completed gRPC calls"))
+	if err != nil {
+		return nil, err
+	}
+	handling, err := meter.Float64Histogram("grpc_"+side+"_handling_seconds",
+		metric.WithDescription("Duration of gRPC calls in seconds"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	sent, err := meter.Int64Counter("grpc_"+side+"_msg_sent_total",
+		metric.WithDescription("Total number of gRPC messages sent"))
+	if err != nil {
+		return nil, err
+	}
+	received, err := meter.Int64Counter("grpc_"+side+"_msg_received_total",
+		metric.WithDescription("Total number of gRPC messages received"))
+	if err != nil {
+		return nil, err
+	}
+	return &grpcMetrics{handled: handled, handling: handling, sent: sent, received: received}, nil
+}
+
+func (m *grpcMetrics) observe(ctx context.Context, info *grpcx.Info, start time.Time) {
+	attrs := metric.WithAttributes(
+		attribute.String("grpc_service", info.Service),
+		attribute.String("grpc_method", info.Method),
+	)
+	m.handled.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("grpc_service", info.Service),
+		attribute.String("grpc_method", info.Method),
+		attribute.String("grpc_code", info.Code),
+	))
+	m.handling.Record(ctx, time.Since(start).Seconds(), attrs)
+	m.sent.Add(ctx, info.Sent, attrs)
+	m.received.Add(ctx, info.Received, attrs)
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that
+// records metrics of unary RPCs.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.grpcServer.observe(ctx, grpcx.NewUnaryInfo(info.FullMethod, err, true), start)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that
+// records metrics of streaming RPCs.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ws := &grpcx.ServerStream{ServerStream: ss, Ctx: ss.Context()}
+		err := handler(srv, ws)
+		m.grpcServer.observe(ss.Context(), grpcx.NewInfo(info.FullMethod, err, ws.Sent(), ws.Received()), start)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that
+// records metrics of unary RPCs.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.grpcClient.observe(ctx, grpcx.NewUnaryInfo(method, err, false), start)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor that
+// records metrics of streaming RPCs.
+// Metrics are recorded when the stream ends.
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.grpcClient.observe(ctx, grpcx.NewInfo(method, err, 0, 0), start)
+			return nil, err
+		}
+		return grpcx.NewClientStream(cs, desc, func(err error, sent, received int64) {
+			m.grpcClient.observe(ctx, grpcx.NewInfo(method, err, sent, received), start)
+		}), nil
+	}
+}
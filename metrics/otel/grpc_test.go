@@ -0,0 +1,80 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpctest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	for _, tc := range grpctest.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			m, reader := newMetrics(t)
+			srv, cli := grpctest.Start(t,
+				[]grpc.ServerOption{
+					grpc.UnaryInterceptor(m.UnaryServerInterceptor()),
+					grpc.StreamInterceptor(m.StreamServerInterceptor()),
+				},
+				[]grpc.DialOption{
+					grpc.WithUnaryInterceptor(m.UnaryClientInterceptor()),
+					grpc.WithStreamInterceptor(m.StreamClientInterceptor()),
+				},
+			)
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-test", "foo")
+			cli.Call(t, ctx, tc)
+
+			if got := srv.MD()[0].Get("x-test"); len(got) != 1 || got[0] != "foo" {
+				t.Errorf("metadata must be propagated: %v", got)
+			}
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatal(err)
+			}
+			checkGRPCMetrics(t, &rm, "server", tc, tc.ServerSent, tc.ServerReceived)
+			checkGRPCMetrics(t, &rm, "client", tc, tc.ClientSent, tc.ClientReceived)
+		})
+	}
+}
+
+func checkGRPCMetrics(t *testing.T, rm *metricdata.ResourceMetrics, side string, tc grpctest.Case, sent, received int64) {
+	t.Helper()
+	method := []attribute.KeyValue{
+		attribute.String("grpc_service", grpctest.Service),
+		attribute.String("grpc_method", tc.Method),
+	}
+	handled := append(method, attribute.String("grpc_code", tc.Code.String()))
+	if v := sumValue(rm, "grpc_"+side+"_handled_total", handled); v != 1 {
+		t.Errorf("%s: want 1 call with code %s, got %d", side, tc.Code, v)
+	}
+	if v := sumValue(rm, "grpc_"+side+"_msg_sent_total", method); v != sent {
+		t.Errorf("%s: want %d sent, got %d", side, sent, v)
+	}
+	if v := sumValue(rm, "grpc_"+side+"_msg_received_total", method); v != received {
+		t.Errorf("%s: want %d received, got %d", side, received, v)
+	}
+}
+
+// sumValue returns the value of the int64 sum with the attributes.
+// It returns -1 if not found.
+func sumValue(rm *metricdata.ResourceMetrics, name string, attrs []attribute.KeyValue) int64 {
+	want := attribute.NewSet(attrs...)
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			sum, ok := md.Data.(metricdata.Sum[int64])
+			if md.Name != name || !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				if dp.Attributes.Equals(&want) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return -1
+}
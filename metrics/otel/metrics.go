@@ -4,6 +4,10 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/metrics"
+	"github.com/aileron-projects/aileron-observability/recovery"
+	"github.com/aileron-projects/aileron-observability/redact"
 	"github.com/aileron-projects/go/znet/zhttp"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -11,18 +15,44 @@ import (
 )
 
 var (
-	_ zhttp.ServerMiddleware = &Metrics{}
-	_ zhttp.ClientMiddleware = &Metrics{}
+	_ zhttp.ServerMiddleware    = &Metrics{}
+	_ zhttp.ClientMiddleware    = &Metrics{}
+	_ metrics.MetricsMiddleware = &Metrics{}
 )
 
 type Metrics struct {
 	provider *sdkmetric.MeterProvider
+	// monitor monitors the exporters given by Config.Exporters.
+	monitor *health.Monitor
 	// serverCounter is the api call counter for
 	// the server-side middleware.
 	serverCounter metric.Int64Counter
 	// clientCounter is the api call counter for
 	// the client-side middleware.
 	clientCounter metric.Int64Counter
+	// panicCounter is the panic counter for
+	// the server-side middleware.
+	panicCounter metric.Int64Counter
+	// recordPanics, if true, records panics
+	// in the server-side middleware.
+	recordPanics bool
+	// grpcServer is the metrics for
+	// the gRPC server interceptors.
+	grpcServer *grpcMetrics
+	// grpcClient is the metrics for
+	// the gRPC client interceptors.
+	grpcClient *grpcMetrics
+	// baggageKeys is the baggage keys
+	// added to the attributes.
+	baggageKeys []string
+	// redactor redacts the attribute values.
+	// It may be nil.
+	redactor *redact.Redactor
+	// serverSets, clientSets and panicSets are the
+	// attribute sets cached for the counters.
+	serverSets *setCache
+	clientSets *setCache
+	panicSets  *setCache
 }
 
 // MeterProvider return the opentelemetry metric provider.
@@ -31,19 +61,45 @@ func (m *Metrics) MeterProvider() *sdkmetric.MeterProvider {
 	return m.provider
 }
 
+// Monitor returns the monitor of the exporters given by [Config.Exporters].
+// Metrics of the monitor are registered to the meter provider.
+func (m *Metrics) Monitor() *health.Monitor {
+	return m.monitor
+}
+
+// Health returns the health status of the exporters given by [Config.Exporters].
+func (m *Metrics) Health() health.Status {
+	return m.monitor.Health()
+}
+
 func (m *Metrics) ServerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := zhttp.WrapResponseWriter(w)
+		panicked := false
 		defer func(ctx context.Context) {
-			m.serverCounter.Add(ctx, 1,
-				metric.WithAttributes(
-					attribute.String("method", r.Method),
-					attribute.String("host", r.Host),
-					attribute.String("path", r.URL.Path),
-					attribute.Int("code", ww.StatusCode()),
-				),
-			)
+			code := ww.StatusCode()
+			if panicked {
+				code = http.StatusInternalServerError
+			}
+			m.serverCounter.Add(ctx, 1, m.httpOptions(m.serverSets, r, code, true)...)
 		}(r.Context())
+		if m.recordPanics {
+			defer func() {
+				if v := recover(); v != nil {
+					recovery.Handle(v, func(_ *recovery.Panic) {
+						panicked = true
+						k := setKey{method: r.Method, host: m.redactHost(r.Host), path: m.redactor.Path(r.URL.Path)}
+						m.panicCounter.Add(r.Context(), 1, m.panicSets.options(k, func() []attribute.KeyValue {
+							return []attribute.KeyValue{
+								attribute.String("method", k.method),
+								attribute.String("host", k.host),
+								attribute.String("path", k.path),
+							}
+						})...)
+					})
+				}
+			}()
+		}
 		next.ServeHTTP(ww, r)
 	})
 }
@@ -55,19 +111,43 @@ func (m *Metrics) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 			if resp != nil {
 				status = resp.StatusCode
 			}
-			m.clientCounter.Add(r.Context(), 1,
-				metric.WithAttributes(
-					attribute.String("method", r.Method),
-					attribute.String("host", r.Host),
-					attribute.String("path", r.URL.Path),
-					attribute.Int("code", status),
-				),
-			)
+			m.clientCounter.Add(r.Context(), 1, m.httpOptions(m.clientSets, r, status, false)...)
 		}()
 		return next.RoundTrip(r)
 	})
 }
 
+// httpOptions returns the measurement options with the "method", "host",
+// "path", "code" and baggage attributes of the request.
+// Attribute sets are cached in the c unless the
+// number of baggage keys exceeds maxCachedBaggage.
+func (m *Metrics) httpOptions(c *setCache, r *http.Request, code int, extract bool) []metric.AddOption {
+	k := setKey{method: r.Method, host: m.redactHost(r.Host), path: m.redactor.Path(r.URL.Path), code: code}
+	bag := baggageAttributes(m.baggageKeys, r, extract, m.redactor)
+	attrs := func() []attribute.KeyValue {
+		return append([]attribute.KeyValue{
+			attribute.String("method", k.method),
+			attribute.String("host", k.host),
+			attribute.String("path", k.path),
+			attribute.Int("code", k.code),
+		}, bag...)
+	}
+	if len(bag) > maxCachedBaggage {
+		return []metric.AddOption{metric.WithAttributes(attrs()...)}
+	}
+	for i, kv := range bag {
+		k.baggage[i] = kv.Value.AsString()
+	}
+	return c.options(k, attrs)
+}
+
+// redactHost returns the redacted host.
+// Dropped hosts are replaced with an empty string.
+func (m *Metrics) redactHost(host string) string {
+	v, _ := m.redactor.String(host)
+	return v
+}
+
 // Finalize closes internal meter provider.
 func (m *Metrics) Finalize(ctx context.Context) error {
 	return m.provider.Shutdown(ctx)
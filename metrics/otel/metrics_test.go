@@ -0,0 +1,113 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// discardWriter is the response writer which discards
+// responses without allocations.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(_ int) {
+}
+
+// newMetrics returns new metrics and the reader of them.
+func newMetrics(tb testing.TB) (*Metrics, *sdkmetric.ManualReader) {
+	tb.Helper()
+	reader := sdkmetric.NewManualReader()
+	m, err := New(&Config{
+		ProviderOpts: []sdkmetric.Option{sdkmetric.WithReader(reader)},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { m.Finalize(context.Background()) })
+	return m, reader
+}
+
+func TestServerMiddleware_allocs(t *testing.T) {
+	m, _ := newMetrics(t)
+	h := m.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/test", nil)
+	w := &discardWriter{header: http.Header{}}
+	h.ServeHTTP(w, r) // Cache the attribute set.
+	// The response writer wrapper is the only allocation.
+	if n := testing.AllocsPerRun(100, func() { h.ServeHTTP(w, r) }); n > 1 {
+		t.Errorf("want at most 1 allocs/op, got %v", n)
+	}
+}
+
+func TestClientMiddleware_allocs(t *testing.T) {
+	res := &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+	m, _ := newMetrics(t)
+	rt := m.ClientMiddleware(zhttp.RoundTripperFunc(func(_ *http.Request) (*http.Response, error) {
+		return res, nil
+	}))
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/test", nil)
+	_, _ = rt.RoundTrip(r) // Cache the attribute set.
+	if n := testing.AllocsPerRun(100, func() { _, _ = rt.RoundTrip(r) }); n != 0 {
+		t.Errorf("want 0 allocs/op, got %v", n)
+	}
+}
+
+// benchmarkCaches runs the f with the cached attribute sets
+// and with the uncached ones as the baseline.
+func benchmarkCaches(b *testing.B, f func(b *testing.B, m *Metrics)) {
+	b.Run("cached", func(b *testing.B) {
+		m, _ := newMetrics(b)
+		f(b, m)
+	})
+	b.Run("uncached", func(b *testing.B) {
+		m, _ := newMetrics(b)
+		m.serverSets, m.clientSets = newSetCache(0), newSetCache(0)
+		f(b, m)
+	})
+}
+
+func BenchmarkServerMiddleware(b *testing.B) {
+	benchmarkCaches(b, func(b *testing.B, m *Metrics) {
+		h := m.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/test", nil)
+		w := &discardWriter{header: http.Header{}}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for range b.N {
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+func BenchmarkClientMiddleware(b *testing.B) {
+	benchmarkCaches(b, func(b *testing.B, m *Metrics) {
+		res := &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+		rt := m.ClientMiddleware(zhttp.RoundTripperFunc(func(_ *http.Request) (*http.Response, error) {
+			return res, nil
+		}))
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/test", nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for range b.N {
+			_, _ = rt.RoundTrip(r)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package otel
+
+import (
+	"context"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/health"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// monitoredExporter records export results to the monitor.
+// Export errors are passed to the monitor and are not returned
+// to the periodic reader so that they are not handled
+// by the global OpenTelemetry error handler.
+type monitoredExporter struct {
+	sdkmetric.Exporter
+	monitor *health.Monitor
+}
+
+func (e *monitoredExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	n := countPoints(rm)
+	e.monitor.Enqueued(n)
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.monitor.ObserveLatency(time.Since(start))
+	if err != nil {
+		e.monitor.Failed(n, err)
+		return nil
+	}
+	e.monitor.Exported(n)
+	return nil
+}
+
+// countPoints returns the number of data points in the rm.
+func countPoints(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch d := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(d.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(d.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Summary:
+				n += len(d.DataPoints)
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,72 @@
+package prom
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/tracing"
+)
+
+// baggageLabel is the label added from the baggage.
+type baggageLabel struct {
+	// key is the baggage key.
+	key string
+	// name is the label name.
+	name string
+}
+
+// newBaggageLabels returns the labels of the baggage keys.
+// Characters not allowed in label names are replaced with "_".
+// It returns an error if a label name conflicts with others.
+func newBaggageLabels(keys []string, reserved []string) ([]baggageLabel, error) {
+	labels := make([]baggageLabel, 0, len(keys))
+	names := slices.Clone(reserved)
+	for _, k := range keys {
+		name := labelName(k)
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("prom: label name %q of baggage key %q conflicts with other labels", name, k)
+		}
+		names = append(names, name)
+		labels = append(labels, baggageLabel{key: k, name: name})
+	}
+	return labels, nil
+}
+
+// labelName converts the key into a valid label name.
+func labelName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		ok := c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (i > 0 && '0' <= c && c <= '9')
+		if !ok {
+			name[i] = '_'
+		}
+	}
+	if strings.HasPrefix(string(name), "__") {
+		return "baggage" + string(name) // "__" prefix is reserved.
+	}
+	return string(name)
+}
+
+// appendBaggageValues appends the values of baggage labels to the lvs.
+// Empty values are appended for the keys not found in the baggage.
+// Baggage in the request headers is used if the
+// context of the r has no baggage.
+// Values are redacted by the rd with the baggage keys
+// and dropped values are replaced with empty values.
+func appendBaggageValues(lvs []string, bls []baggageLabel, r *http.Request, extract bool, rd *redact.Redactor) []string {
+	if len(bls) == 0 {
+		return lvs
+	}
+	ctx := r.Context()
+	if extract && len(tracing.BaggageFromContext(ctx)) == 0 {
+		ctx = tracing.ExtractBaggage(ctx, tracing.HeaderCarrier(r.Header))
+	}
+	for _, bl := range bls {
+		v, _ := rd.Attribute(bl.key, tracing.GetBaggage(ctx, bl.key))
+		lvs = append(lvs, v)
+	}
+	return lvs
+}
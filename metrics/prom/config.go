@@ -1,6 +1,7 @@
 package prom
 
 import (
+	"github.com/aileron-projects/aileron-observability/redact"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -10,10 +11,27 @@ import (
 // Use [New] to create a new instance of the [Metrics].
 type Config struct {
 	// HandlerOpts is the option for prometheus handler.
+	// Set EnableOpenMetrics to expose the "request_id" exemplars
+	// of requests with ids assigned by the requestid middleware.
 	HandlerOpts promhttp.HandlerOpts
 	// Collectors is the list of additional
 	// prometheus collectors.
 	Collectors []prometheus.Collector
+	// BaggageKeys is the list of baggage keys added to
+	// the labels of http request counters.
+	// Characters not allowed in label names are replaced with "_"
+	// such that "tenant.id" becomes "tenant_id".
+	BaggageKeys []string
+	// Redactor redacts the "host", "path" and baggage label values
+	// of http request counters. Paths are redacted with
+	// [redact.Redactor.Path] and baggage values are redacted
+	// with the baggage keys. If nil, values are not redacted.
+	Redactor *redact.Redactor
+	// RecordPanics, if true, records panics in the server middleware
+	// with the "http_server_panics_total" counter and counts the requests
+	// with the status code 500, and then panics again with the [recovery.Panic].
+	// Use the [recovery.Middleware] to reply 500 or to re-panic.
+	RecordPanics bool
 }
 
 // New returns a new instance of the [Metrics] from c.
@@ -29,12 +47,21 @@ func New(c *Config) (*Metrics, error) {
 	}
 	handler := promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(reg, c.HandlerOpts))
 
+	names := []string{"host", "path", "code", "method"}
+	bls, err := newBaggageLabels(c.BaggageKeys, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, bl := range bls {
+		names = append(names, bl.name)
+	}
+
 	serverCounter := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "Total number of received http requests",
 		},
-		[]string{"host", "path", "code", "method"},
+		names,
 	)
 	reg.MustRegister(serverCounter)
 
@@ -43,13 +70,35 @@ func New(c *Config) (*Metrics, error) {
 			Name: "http_client_requests_total",
 			Help: "Total number of sent http requests",
 		},
-		[]string{"host", "path", "code", "method"},
+		names,
 	)
 	reg.MustRegister(clientCounter)
 
+	panicCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_server_panics_total",
+			Help: "Total number of panics in http handlers",
+		},
+		[]string{"host", "path", "method"},
+	)
+	reg.MustRegister(panicCounter)
+
+	grpcServer := newGRPCMetrics("server")
+	grpcServer.register(reg)
+	grpcClient := newGRPCMetrics("client")
+	grpcClient.register(reg)
+
 	return &Metrics{
 		metrics:       handler,
-		serverCounter: serverCounter,
-		clientCounter: clientCounter,
+		reg:           reg,
+		serverCounter: newMethodVec(serverCounter),
+		clientCounter: newMethodVec(clientCounter),
+		panicCounter:  newMethodVec(panicCounter),
+		recordPanics:  c.RecordPanics,
+		grpcServer:    grpcServer,
+		grpcClient:    grpcClient,
+		baggage:       bls,
+		redactor:      c.Redactor,
+		exemplars:     c.HandlerOpts.EnableOpenMetrics,
 	}, nil
 }
@@ -0,0 +1,117 @@
+package prom
+
+import (
+	"context"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpcx"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// grpcMetrics is the metrics of gRPC interceptors.
+type grpcMetrics struct {
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+	sent     *prometheus.CounterVec
+	received *prometheus.CounterVec
+}
+
+// newGRPCMetrics returns new gRPC metrics.
+// The side is "server" or "client".
+func newGRPCMetrics(side string) *grpcMetrics {
+	labels := []string{"grpc_service", "grpc_method"}
+	return &grpcMetrics{
+		handled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_" + side + "_handled_total",
+				Help: "Total number of completed gRPC calls",
+			},
+			append(labels, "grpc_code"),
+		),
+		handling: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "grpc_" + side + "_handling_seconds",
+				Help:    "Duration of gRPC calls in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			labels,
+		),
+		sent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_" + side + "_msg_sent_total",
+				Help: "Total number of gRPC messages sent",
+			},
+			labels,
+		),
+		received: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_" + side + "_msg_received_total",
+				Help: "Total number of gRPC messages received",
+			},
+			labels,
+		),
+	}
+}
+
+func (m *grpcMetrics) register(reg prometheus.Registerer) {
+	reg.MustRegister(m.handled, m.handling, m.sent, m.received)
+}
+
+func (m *grpcMetrics) observe(info *grpcx.Info, start time.Time) {
+	m.handled.WithLabelValues(info.Service, info.Method, info.Code).Inc()
+	m.handling.WithLabelValues(info.Service, info.Method).Observe(time.Since(start).Seconds())
+	m.sent.WithLabelValues(info.Service, info.Method).Add(float64(info.Sent))
+	m.received.WithLabelValues(info.Service, info.Method).Add(float64(info.Received))
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that
+// records metrics of unary RPCs.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.grpcServer.observe(grpcx.NewUnaryInfo(info.FullMethod, err, true), start)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that
+// records metrics of streaming RPCs.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ws := &grpcx.ServerStream{ServerStream: ss, Ctx: ss.Context()}
+		err := handler(srv, ws)
+		m.grpcServer.observe(grpcx.NewInfo(info.FullMethod, err, ws.Sent(), ws.Received()), start)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that
+// records metrics of unary RPCs.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.grpcClient.observe(grpcx.NewUnaryInfo(method, err, false), start)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor that
+// records metrics of streaming RPCs.
+// Metrics are recorded when the stream ends.
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.grpcClient.observe(grpcx.NewInfo(method, err, 0, 0), start)
+			return nil, err
+		}
+		return grpcx.NewClientStream(cs, desc, func(err error, sent, received int64) {
+			m.grpcClient.observe(grpcx.NewInfo(method, err, sent, received), start)
+		}), nil
+	}
+}
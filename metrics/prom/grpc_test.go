@@ -0,0 +1,53 @@
+package prom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpctest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	for _, tc := range grpctest.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			m := newMetrics(t)
+			srv, cli := grpctest.Start(t,
+				[]grpc.ServerOption{
+					grpc.UnaryInterceptor(m.UnaryServerInterceptor()),
+					grpc.StreamInterceptor(m.StreamServerInterceptor()),
+				},
+				[]grpc.DialOption{
+					grpc.WithUnaryInterceptor(m.UnaryClientInterceptor()),
+					grpc.WithStreamInterceptor(m.StreamClientInterceptor()),
+				},
+			)
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-test", "foo")
+			cli.Call(t, ctx, tc)
+
+			if got := srv.MD()[0].Get("x-test"); len(got) != 1 || got[0] != "foo" {
+				t.Errorf("metadata must be propagated: %v", got)
+			}
+			checkGRPCMetrics(t, "server", m.grpcServer, tc, tc.ServerSent, tc.ServerReceived)
+			checkGRPCMetrics(t, "client", m.grpcClient, tc, tc.ClientSent, tc.ClientReceived)
+		})
+	}
+}
+
+func checkGRPCMetrics(t *testing.T, side string, m *grpcMetrics, tc grpctest.Case, sent, received int64) {
+	t.Helper()
+	if v := testutil.ToFloat64(m.handled.WithLabelValues(grpctest.Service, tc.Method, tc.Code.String())); v != 1 {
+		t.Errorf("%s: want 1 call with code %s, got %v", side, tc.Code, v)
+	}
+	if v := testutil.ToFloat64(m.sent.WithLabelValues(grpctest.Service, tc.Method)); v != float64(sent) {
+		t.Errorf("%s: want %d sent, got %v", side, sent, v)
+	}
+	if v := testutil.ToFloat64(m.received.WithLabelValues(grpctest.Service, tc.Method)); v != float64(received) {
+		t.Errorf("%s: want %d received, got %v", side, received, v)
+	}
+	if n := testutil.CollectAndCount(m.handling); n != 1 {
+		t.Errorf("%s: want 1 duration, got %d", side, n)
+	}
+}
@@ -1,17 +1,23 @@
 package prom
 
 import (
+	"context"
 	"net/http"
-	"strconv"
+	"unicode/utf8"
 
+	"github.com/aileron-projects/aileron-observability/metrics"
+	"github.com/aileron-projects/aileron-observability/recovery"
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/requestid"
 	"github.com/aileron-projects/go/znet/zhttp"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	_ http.Handler           = &Metrics{}
-	_ zhttp.ServerMiddleware = &Metrics{}
-	_ zhttp.ClientMiddleware = &Metrics{}
+	_ http.Handler              = &Metrics{}
+	_ zhttp.ServerMiddleware    = &Metrics{}
+	_ zhttp.ClientMiddleware    = &Metrics{}
+	_ metrics.MetricsMiddleware = &Metrics{}
 )
 
 // Metrics collects metrics and export them as prometheus format.
@@ -21,10 +27,31 @@ type Metrics struct {
 	reg     *prometheus.Registry // prometheus registry.
 	// serverCounter is the api call counter for
 	// the server-side middleware.
-	serverCounter *prometheus.CounterVec
+	serverCounter *methodVec
 	// clientCounter is the api call counter for
 	// the client-side middleware.
-	clientCounter *prometheus.CounterVec
+	clientCounter *methodVec
+	// panicCounter is the panic counter for
+	// the server-side middleware.
+	panicCounter *methodVec
+	// recordPanics, if true, records panics
+	// in the server-side middleware.
+	recordPanics bool
+	// grpcServer is the metrics for
+	// the gRPC server interceptors.
+	grpcServer *grpcMetrics
+	// grpcClient is the metrics for
+	// the gRPC client interceptors.
+	grpcClient *grpcMetrics
+	// baggage is the labels added
+	// from the baggage.
+	baggage []baggageLabel
+	// redactor redacts the label values.
+	// It may be nil.
+	redactor *redact.Redactor
+	// exemplars, if true, adds the request ids
+	// to the counters as exemplars.
+	exemplars bool
 }
 
 // Registry return the prometheus registry.
@@ -38,15 +65,31 @@ func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (m *Metrics) ServerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ww := zhttp.WrapResponseWriter(w)
+		ww := getResponseWriter(w)
+		panicked := false
 		defer func() {
-			m.serverCounter.With(prometheus.Labels{
-				"method": r.Method,
-				"host":   r.Host,
-				"path":   r.URL.Path,
-				"code":   strconv.Itoa(ww.StatusCode()),
-			}).Inc()
+			code := ww.status
+			if panicked {
+				code = http.StatusInternalServerError
+			}
+			putResponseWriter(ww)
+			lvs := getLabelValues()
+			*lvs = append(*lvs, m.redactHost(r.Host), m.redactor.Path(r.URL.Path), codeLabel(code))
+			*lvs = appendBaggageValues(*lvs, m.baggage, r, true, m.redactor)
+			m.inc(r.Context(), m.serverCounter.counter(r.Method, *lvs...))
+			putLabelValues(lvs)
 		}()
+		if m.recordPanics {
+			defer func() {
+				if v := recover(); v != nil {
+					recovery.Handle(v, func(_ *recovery.Panic) {
+						panicked = true
+						c := m.panicCounter.counter(r.Method, m.redactHost(r.Host), m.redactor.Path(r.URL.Path))
+						m.inc(r.Context(), c)
+					})
+				}
+			}()
+		}
 		next.ServeHTTP(ww, r)
 	})
 }
@@ -58,13 +101,45 @@ func (m *Metrics) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 			if resp != nil {
 				status = resp.StatusCode
 			}
-			m.clientCounter.With(prometheus.Labels{
-				"method": r.Method,
-				"host":   r.URL.Host,
-				"path":   r.URL.Path,
-				"code":   strconv.Itoa(status),
-			}).Inc()
+			lvs := getLabelValues()
+			*lvs = append(*lvs, m.redactHost(r.URL.Host), m.redactor.Path(r.URL.Path), codeLabel(status))
+			*lvs = appendBaggageValues(*lvs, m.baggage, r, false, m.redactor)
+			m.inc(r.Context(), m.clientCounter.counter(r.Method, *lvs...))
+			putLabelValues(lvs)
 		}()
 		return next.RoundTrip(r)
 	})
 }
+
+// redactHost returns the redacted host.
+// Dropped hosts are replaced with an empty string.
+func (m *Metrics) redactHost(host string) string {
+	v, _ := m.redactor.String(host)
+	return v
+}
+
+// inc increments the counter.
+// The request id in the ctx is added as the "request_id" exemplar
+// if available. Exemplars are exposed only in the OpenMetrics format
+// so they are not added if the OpenMetrics is not enabled.
+func (m *Metrics) inc(ctx context.Context, c prometheus.Counter) {
+	if !m.exemplars {
+		c.Inc()
+		return
+	}
+	id := requestid.FromContext(ctx)
+	ea, ok := c.(prometheus.ExemplarAdder)
+	if !ok || id == "" || !utf8.ValidString(id) ||
+		utf8.RuneCountInString(id)+len("request_id") > prometheus.ExemplarMaxRunes {
+		c.Inc()
+		return
+	}
+	ea.AddWithExemplar(1, prometheus.Labels{"request_id": id})
+}
+
+// Finalize does nothing and always returns nil.
+// Metrics are pulled by the prometheus server
+// so there is no buffered data to be flushed.
+func (m *Metrics) Finalize(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package prom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// discardWriter is the response writer which discards
+// responses without allocations.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(_ int) {
+}
+
+func newMetrics(tb testing.TB) *Metrics {
+	tb.Helper()
+	m, err := New(&Config{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+func newServer(m *Metrics) (http.Handler, *http.Request, http.ResponseWriter) {
+	h := m.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	return h, httptest.NewRequest(http.MethodGet, "http://example.com/test", nil), &discardWriter{header: http.Header{}}
+}
+
+func newClient(m *Metrics) (http.RoundTripper, *http.Request) {
+	res := &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+	rt := m.ClientMiddleware(zhttp.RoundTripperFunc(func(_ *http.Request) (*http.Response, error) {
+		return res, nil
+	}))
+	return rt, httptest.NewRequest(http.MethodGet, "http://example.com/test", nil)
+}
+
+func TestServerMiddleware_allocs(t *testing.T) {
+	h, r, w := newServer(newMetrics(t))
+	h.ServeHTTP(w, r) // Create the counter.
+	if n := testing.AllocsPerRun(100, func() { h.ServeHTTP(w, r) }); n != 0 {
+		t.Errorf("want 0 allocs/op, got %v", n)
+	}
+}
+
+func TestClientMiddleware_allocs(t *testing.T) {
+	rt, r := newClient(newMetrics(t))
+	_, _ = rt.RoundTrip(r) // Create the counter.
+	if n := testing.AllocsPerRun(100, func() { _, _ = rt.RoundTrip(r) }); n != 0 {
+		t.Errorf("want 0 allocs/op, got %v", n)
+	}
+}
+
+func TestServerMiddleware_nonStandardMethod(t *testing.T) {
+	m := newMetrics(t)
+	h, _, w := newServer(m)
+	for _, method := range []string{"PROPFIND", "PROPFIND", "FOO", http.MethodGet} {
+		h.ServeHTTP(w, httptest.NewRequest(method, "http://example.com/test", nil))
+	}
+	// Non-standard methods keep their own series.
+	for method, want := range map[string]float64{"PROPFIND": 2, "FOO": 1, http.MethodGet: 1} {
+		labels := []string{"example.com", "/test", "200", method}
+		if v := testutil.ToFloat64(m.serverCounter.vec.WithLabelValues(labels...)); v != want {
+			t.Errorf("want %v requests of the method %q, got %v", want, method, v)
+		}
+	}
+	if n := testutil.CollectAndCount(m.serverCounter.vec); n != 3 {
+		t.Errorf("want 3 counters, got %d", n)
+	}
+}
+
+func BenchmarkServerMiddleware(b *testing.B) {
+	h, r, w := newServer(newMetrics(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		h.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkClientMiddleware(b *testing.B) {
+	rt, r := newClient(newMetrics(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		_, _ = rt.RoundTrip(r)
+	}
+}
@@ -0,0 +1,101 @@
+package prom
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+var (
+	_ http.ResponseWriter = &responseWriter{}
+	_ http.Flusher        = &responseWriter{}
+	_ http.Hijacker       = &responseWriter{}
+)
+
+// responseWriters is the pool of the [responseWriter].
+var responseWriters = sync.Pool{
+	New: func() any { return &responseWriter{} },
+}
+
+// getResponseWriter returns a pooled [responseWriter] that wraps the w.
+// It must be returned to the pool with [putResponseWriter]
+// after the handler returned.
+func getResponseWriter(w http.ResponseWriter) *responseWriter {
+	ww := responseWriters.Get().(*responseWriter)
+	ww.ResponseWriter = w
+	ww.status = -1
+	return ww
+}
+
+// putResponseWriter returns the ww to the pool.
+func putResponseWriter(ww *responseWriter) {
+	ww.ResponseWriter = nil
+	responseWriters.Put(ww)
+}
+
+// responseWriter records the status code written to the
+// response writer. It is lighter than the zhttp.ResponseWrapper
+// and is pooled so that the middleware does not allocate.
+// Handlers must not use it after they returned as
+// described in the [http.Handler].
+type responseWriter struct {
+	http.ResponseWriter
+	// status is the written status code.
+	// It is -1 if not written yet.
+	status int
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status < 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+func (w *responseWriter) FlushError() error {
+	if w.status < 0 {
+		w.status = http.StatusOK
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the internal response writer
+// for the [http.ResponseController].
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCodes is the interned label values of
+// the status codes from -1 to 599.
+// -1 is used for responses without status codes.
+var statusCodes = func() []string {
+	codes := make([]string, 601)
+	for i := range codes {
+		codes[i] = strconv.Itoa(i - 1)
+	}
+	return codes
+}()
+
+// codeLabel returns the label value of the status code.
+// Interned values are returned for the codes from -1 to 599.
+func codeLabel(code int) string {
+	if code >= -1 && code < len(statusCodes)-1 {
+		return statusCodes[code+1]
+	}
+	return strconv.Itoa(code)
+}
@@ -0,0 +1,71 @@
+package prom
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// methods is the list of methods whose
+// curried counter vectors are cached.
+var methods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// methodVec is the counter vector with the "method" label.
+// Vectors curried with the standard methods are cached so that
+// counters are looked up with [prometheus.CounterVec.WithLabelValues]
+// without building label maps. Non-standard methods such as
+// the PROPFIND of WebDAV are counted with the method as-is
+// by currying the vector on every call.
+type methodVec struct {
+	vec     *prometheus.CounterVec
+	curried map[string]*prometheus.CounterVec
+}
+
+// newMethodVec returns a new [methodVec] of the vec.
+// The vec must have the "method" label.
+func newMethodVec(vec *prometheus.CounterVec) *methodVec {
+	curried := make(map[string]*prometheus.CounterVec, len(methods))
+	for _, method := range methods {
+		curried[method] = vec.MustCurryWith(prometheus.Labels{"method": method})
+	}
+	return &methodVec{vec: vec, curried: curried}
+}
+
+// counter returns the counter of the method and the label values.
+// The lvs must be in the order of the label names excluding the "method".
+func (v *methodVec) counter(method string, lvs ...string) prometheus.Counter {
+	vec, ok := v.curried[method]
+	if !ok {
+		vec = v.vec.MustCurryWith(prometheus.Labels{"method": method})
+	}
+	return vec.WithLabelValues(lvs...)
+}
+
+// labelValues is the pool of label value slices.
+var labelValues = sync.Pool{
+	New: func() any { return new([]string) },
+}
+
+// getLabelValues returns a pooled empty slice of label values.
+// It must be returned to the pool with [putLabelValues].
+func getLabelValues() *[]string {
+	return labelValues.Get().(*[]string)
+}
+
+// putLabelValues clears the lvs and returns it to the pool.
+func putLabelValues(lvs *[]string) {
+	clear(*lvs)
+	*lvs = (*lvs)[:0]
+	labelValues.Put(lvs)
+}
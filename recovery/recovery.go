@@ -0,0 +1,146 @@
+// Package recovery provides the middleware that recovers
+// panics in http handlers and the [Panic] shared by the
+// tracing and metrics middleware that record panics.
+package recovery
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+)
+
+var (
+	_ zhttp.ServerMiddleware = &Middleware{}
+	_ error                  = &Panic{}
+)
+
+// Panic is the recovered panic value with the stack.
+// Middleware recording panics panic again with the Panic
+// so that the stack is captured only once.
+type Panic struct {
+	// Value is the original panic value.
+	Value any
+	// Stack is the stack trace captured
+	// when the panic was recovered first.
+	Stack []byte
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprint("panic: ", p.Value)
+}
+
+// Unwrap returns the Value if it is an error.
+func (p *Panic) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Type returns the type name of the Value such as "*errors.errorString".
+func (p *Panic) Type() string {
+	return fmt.Sprintf("%T", p.Value)
+}
+
+// Message returns the Value formatted with [fmt.Sprint].
+func (p *Panic) Message() string {
+	return fmt.Sprint(p.Value)
+}
+
+// Handle calls the f with the [Panic] of the recovered value v
+// and then panics with it. The stack is captured if the v is
+// not a Panic yet. It does nothing if the v is nil.
+// [http.ErrAbortHandler] is not passed to the f because
+// it is used to abort responses intentionally.
+// It must be called in deferred functions with the value
+// returned by the recover.
+//
+//	defer func() {
+//		if v := recover(); v != nil {
+//			recovery.Handle(v, func(p *recovery.Panic) { ... })
+//		}
+//	}()
+func Handle(v any, f func(p *Panic)) {
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	p := wrap(v)
+	f(p)
+	panic(p)
+}
+
+// wrap returns the [Panic] of the v.
+func wrap(v any) *Panic {
+	if p, ok := v.(*Panic); ok {
+		return p
+	}
+	return &Panic{Value: v, Stack: debug.Stack()}
+}
+
+// Config is the configuration for the [Middleware].
+type Config struct {
+	// RePanic, if true, panics again with the original value
+	// after the panic was logged so that the [http.Server]
+	// aborts the response. If false, 500 Internal Server Error
+	// is replied if the status code was not written yet.
+	RePanic bool
+	// Logger logs recovered panics at the error level
+	// with the stack. If nil, [slog.Default] is used.
+	Logger *slog.Logger
+}
+
+// Middleware recovers panics in the handlers.
+// It should be applied outside of the tracing and metrics
+// middleware configured to record panics so that
+// they can record the panics before recovered.
+// [http.ErrAbortHandler] is not recovered.
+type Middleware struct {
+	rePanic bool
+	logger  *slog.Logger
+}
+
+// New returns a new [Middleware].
+func New(c *Config) *Middleware {
+	m := &Middleware{
+		rePanic: c.RePanic,
+		logger:  c.Logger,
+	}
+	if m.logger == nil {
+		m.logger = slog.Default()
+	}
+	return m
+}
+
+func (m *Middleware) ServerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := zhttp.WrapResponseWriter(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			p := wrap(v)
+			m.logger.ErrorContext(r.Context(), "panic recovered",
+				"panic", p.Message(),
+				"type", p.Type(),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"stack", string(p.Stack),
+			)
+			if m.rePanic {
+				panic(p.Value)
+			}
+			if ww.StatusCode() < 0 {
+				http.Error(ww, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
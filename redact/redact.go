@@ -0,0 +1,376 @@
+// Package redact provides the redaction of personally identifiable
+// information in span names, span attributes and metric labels.
+// A [Redactor] is shared by all tracers and the metrics middleware.
+package redact
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Actions of the [Rule].
+const (
+	// ActionMask replaces the values with the [Config.Mask].
+	ActionMask = "mask"
+	// ActionHash replaces the values with their keyed hashes
+	// such as "sha256:2c26b46b68ffc68f" so that they can still be correlated.
+	ActionHash = "hash"
+	// ActionDrop removes the attributes or query parameters entirely.
+	// Path segments cannot be removed and are masked instead.
+	ActionDrop = "drop"
+)
+
+// Built-in detectors of the [Rule].
+const (
+	// DetectEmail detects email addresses.
+	DetectEmail = "email"
+	// DetectJWT detects JSON web tokens.
+	DetectJWT = "jwt"
+	// DetectCard detects payment card numbers passing the Luhn check.
+	DetectCard = "card"
+	// DetectIP detects IPv4 and IPv6 addresses.
+	DetectIP = "ip"
+)
+
+// DefaultMask is the default replacement of masked values.
+const DefaultMask = "REDACTED"
+
+// Rule is the redaction rule.
+// Exactly one of Keys, Pattern and Detector must be set.
+type Rule struct {
+	// Keys is the list of query parameter names and attribute keys
+	// whose values are redacted entirely. Keys are case-insensitive.
+	// Path segments following a segment equal to one of the keys
+	// are also redacted such as "123" in "/users/123" for the key "users".
+	Keys []string `json:"keys"`
+	// Pattern is the regular expression in the RE2 syntax.
+	// Substrings of values matching the pattern are redacted.
+	Pattern string `json:"pattern"`
+	// Detector is the name of the built-in detector.
+	// Valid values are "email", "jwt", "card" and "ip".
+	// Substrings of values detected are redacted.
+	Detector string `json:"detector"`
+	// Action is the redaction action.
+	// Valid values are "mask", "hash" and "drop".
+	// If empty, "mask" is used.
+	Action string `json:"action"`
+}
+
+// Config is the configuration for the [Redactor].
+// Use [New] to create a new instance of the [Redactor].
+type Config struct {
+	// Rules is the list of redaction rules.
+	// Keyed rules are applied first and other rules
+	// are applied in the order.
+	Rules []Rule `json:"rules"`
+	// Mask is the replacement of masked values.
+	// If empty, [DefaultMask] is used.
+	// It should not contain characters that must be escaped in urls.
+	Mask string `json:"mask"`
+	// HashKey is the key of the HMAC-SHA256 used by the "hash" action.
+	// If empty, hashes are not keyed and may be reversed
+	// by guessing the values.
+	HashKey string `json:"hashKey"`
+}
+
+// Redactor redacts values by the rules.
+// Methods of a nil Redactor return the values as-is.
+type Redactor struct {
+	keys     map[string]string // Lower-cased key to the action.
+	matchers []*matcher
+	mask     string
+	hashKey  []byte
+}
+
+// matcher replaces substrings matching the pattern.
+type matcher struct {
+	re     *regexp.Regexp
+	valid  func(string) bool // Additional validation. It may be nil.
+	action string
+}
+
+// New returns a new [Redactor] from the c.
+func New(c *Config) (*Redactor, error) {
+	r := &Redactor{
+		keys:    map[string]string{},
+		mask:    cmp.Or(c.Mask, DefaultMask),
+		hashKey: []byte(c.HashKey),
+	}
+	for i, rule := range c.Rules {
+		action := strings.ToLower(cmp.Or(rule.Action, ActionMask))
+		if action != ActionMask && action != ActionHash && action != ActionDrop {
+			return nil, fmt.Errorf("redact: invalid action %q of rule %d", rule.Action, i)
+		}
+		n := 0
+		for _, set := range []bool{len(rule.Keys) > 0, rule.Pattern != "", rule.Detector != ""} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("redact: rule %d must have exactly one of keys, pattern or detector", i)
+		}
+		switch {
+		case len(rule.Keys) > 0:
+			for _, k := range rule.Keys {
+				r.keys[strings.ToLower(k)] = action
+			}
+		case rule.Pattern != "":
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("redact: invalid pattern of rule %d: %w", i, err)
+			}
+			r.matchers = append(r.matchers, &matcher{re: re, action: action})
+		default:
+			d, ok := detectors[strings.ToLower(rule.Detector)]
+			if !ok {
+				return nil, fmt.Errorf("redact: unknown detector %q of rule %d", rule.Detector, i)
+			}
+			r.matchers = append(r.matchers, &matcher{re: d.re, valid: d.valid, action: action})
+		}
+	}
+	return r, nil
+}
+
+// errDrop is returned when the value must be dropped.
+var errDrop = errors.New("redact: drop")
+
+// String redacts the substrings of the s matching the
+// pattern and detector rules. It returns false
+// if the s matched a rule of the "drop" action.
+func (r *Redactor) String(s string) (string, bool) {
+	if r == nil || s == "" {
+		return s, true
+	}
+	v, err := r.replace(s)
+	if err != nil {
+		return "", false
+	}
+	return v, true
+}
+
+// replace replaces the substrings of the s by the matchers.
+// It returns errDrop if a matcher of the drop action matched.
+func (r *Redactor) replace(s string) (string, error) {
+	for _, m := range r.matchers {
+		dropped := false
+		s = m.re.ReplaceAllStringFunc(s, func(match string) string {
+			if m.valid != nil && !m.valid(match) {
+				return match
+			}
+			if m.action == ActionDrop {
+				dropped = true
+			}
+			return r.apply(m.action, match)
+		})
+		if dropped {
+			return "", errDrop
+		}
+	}
+	return s, nil
+}
+
+// apply returns the replacement of the value by the action.
+func (r *Redactor) apply(action, value string) string {
+	if action == ActionHash {
+		var sum []byte
+		if len(r.hashKey) > 0 {
+			h := hmac.New(sha256.New, r.hashKey)
+			h.Write([]byte(value))
+			sum = h.Sum(nil)
+		} else {
+			s := sha256.Sum256([]byte(value))
+			sum = s[:]
+		}
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return r.mask
+}
+
+// Attribute redacts the value of the attribute of the key.
+// Values of keyed rules are redacted entirely.
+// Values of the "http.query", "http.path" and "http.referer"
+// and other url attributes are redacted with [Redactor.Query],
+// [Redactor.Path] and [Redactor.URL]. Other values are
+// redacted with [Redactor.String].
+// It returns false if the attribute must be dropped.
+func (r *Redactor) Attribute(key, value string) (string, bool) {
+	if r == nil || value == "" {
+		return value, true
+	}
+	if action, ok := r.keys[strings.ToLower(key)]; ok {
+		if action == ActionDrop {
+			return "", false
+		}
+		return r.apply(action, value), true
+	}
+	switch key {
+	case "http.query", "url.query":
+		return r.Query(value), true
+	case "http.path", "http.route", "url.path":
+		return r.Path(value), true
+	case "http.referer", "http.url", "url.full":
+		return r.URL(value), true
+	}
+	return r.String(value)
+}
+
+// Query redacts the raw query string such as "a=b&c=d".
+// Parameters of keyed rules are redacted entirely.
+// Other parameter values are redacted by the pattern and detector rules.
+// Parameters are removed when the "drop" action applies.
+func (r *Redactor) Query(rawQuery string) string {
+	if r == nil || rawQuery == "" {
+		return rawQuery
+	}
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, p := range params {
+		rawKey, rawValue, hasValue := strings.Cut(p, "=")
+		key := unescape(rawKey, url.QueryUnescape)
+		if action, ok := r.keys[strings.ToLower(key)]; ok {
+			if action == ActionDrop {
+				continue
+			}
+			if hasValue {
+				p = rawKey + "=" + url.QueryEscape(r.apply(action, unescape(rawValue, url.QueryUnescape)))
+			}
+			kept = append(kept, p)
+			continue
+		}
+		v, err := r.replace(unescape(rawValue, url.QueryUnescape))
+		if err != nil {
+			continue
+		}
+		k, err := r.replace(key)
+		if err != nil {
+			continue
+		}
+		if k != key || v != unescape(rawValue, url.QueryUnescape) {
+			p = url.QueryEscape(k)
+			if hasValue {
+				p += "=" + url.QueryEscape(v)
+			}
+		}
+		kept = append(kept, p)
+	}
+	return strings.Join(kept, "&")
+}
+
+// Path redacts the escaped url path such as "/users/123".
+// Segments following a segment of the keyed rules are redacted entirely.
+// Other segments are redacted by the pattern and detector rules.
+// Segments are masked when the "drop" action applies.
+func (r *Redactor) Path(path string) string {
+	if r == nil || path == "" {
+		return path
+	}
+	segs := strings.Split(path, "/")
+	prev := ""
+	for i, seg := range segs {
+		s := unescape(seg, url.PathUnescape)
+		cur := s
+		if action, ok := r.keys[strings.ToLower(prev)]; ok && s != "" {
+			if action == ActionDrop {
+				action = ActionMask
+			}
+			segs[i] = url.PathEscape(r.apply(action, s))
+		} else if v, err := r.replace(s); err != nil {
+			segs[i] = r.mask
+		} else if v != s {
+			segs[i] = url.PathEscape(v)
+		}
+		prev = cur
+	}
+	return strings.Join(segs, "/")
+}
+
+// URL redacts the url string.
+// The path and the query are redacted with [Redactor.Path]
+// and [Redactor.Query]. The password of the user info is
+// removed and other parts are redacted with [Redactor.String].
+// The s is redacted with [Redactor.String] if it is not a valid url.
+func (r *Redactor) URL(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		v, _ := r.String(s)
+		return v
+	}
+	if u.User != nil {
+		name, _ := r.String(u.User.Username())
+		u.User = url.User(name)
+	}
+	u.Host, _ = r.String(u.Host)
+	path := r.Path(u.EscapedPath())
+	u.Path = unescape(path, url.PathUnescape)
+	u.RawPath = path
+	u.RawQuery = r.Query(u.RawQuery)
+	u.Fragment, _ = r.String(u.Fragment)
+	u.RawFragment = ""
+	return u.String()
+}
+
+// unescape returns the unescaped s or the s if failed.
+func unescape(s string, f func(string) (string, error)) string {
+	if v, err := f(s); err == nil {
+		return v
+	}
+	return s
+}
+
+type detector struct {
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+var detectors = map[string]*detector{
+	DetectEmail: {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	DetectJWT: {
+		re: regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+	},
+	DetectCard: {
+		re:    regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		valid: luhn,
+	},
+	DetectIP: {
+		re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`),
+		valid: func(s string) bool {
+			_, err := netip.ParseAddr(s)
+			return err == nil
+		},
+	},
+}
+
+// luhn returns true if the digits in the s pass the Luhn check.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,134 @@
+// Package requestid provides the middleware that
+// assigns an id to each request for correlating
+// spans, metrics and logs of the request.
+package requestid
+
+import (
+	"cmp"
+	"context"
+	"encoding/base32"
+	"net/http"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+	"github.com/aileron-projects/go/zx/zuid"
+)
+
+const (
+	// ContextKey is the key of the request id in the context.
+	// Ids are stored with [zuid.ContextWithID] and
+	// the default span hooks of all tracers read ids with this key.
+	ContextKey = "context"
+	// DefaultHeader is the default header name of the request id.
+	DefaultHeader = "X-Request-ID"
+)
+
+var (
+	_ zhttp.ServerMiddleware = &Middleware{}
+	_ zhttp.ClientMiddleware = &Middleware{}
+)
+
+// Config is the configuration for the [Middleware].
+type Config struct {
+	// Header is the header name of the request id.
+	// If empty, [DefaultHeader] is used.
+	Header string
+	// MaxLength is the maximum length of incoming request ids.
+	// Incoming ids longer than MaxLength or containing
+	// characters other than visible ASCII are replaced with new ones.
+	// If zero or negative, 128 is used.
+	MaxLength int
+	// IgnoreIncoming, if true, always generates new ids
+	// regardless of incoming headers.
+	IgnoreIncoming bool
+	// Generate generates a new request id.
+	// If nil, [NewID] is used.
+	Generate func() string
+}
+
+// Middleware assigns request ids.
+// The server-side middleware reuses the incoming id or
+// generates a new one, stores it in the context and
+// echoes it in the response header.
+// The client-side middleware forwards the id in the context
+// to the outgoing requests.
+// The server-side middleware should be applied before the
+// tracing and metrics middleware so that they can use the id.
+type Middleware struct {
+	header   string
+	maxLen   int
+	incoming bool
+	generate func() string
+}
+
+// New returns a new [Middleware].
+func New(c *Config) *Middleware {
+	m := &Middleware{
+		header:   http.CanonicalHeaderKey(cmp.Or(c.Header, DefaultHeader)),
+		maxLen:   cmp.Or(max(c.MaxLength, 0), 128),
+		incoming: !c.IgnoreIncoming,
+		generate: c.Generate,
+	}
+	if m.generate == nil {
+		m.generate = NewID
+	}
+	return m
+}
+
+func (m *Middleware) ServerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := ""
+		if m.incoming {
+			id = r.Header.Get(m.header)
+		}
+		if !m.valid(id) {
+			id = m.generate()
+		}
+		w.Header().Set(m.header, id)
+		r = r.WithContext(zuid.ContextWithID(r.Context(), ContextKey, id))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Middleware) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	return zhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		id := FromContext(r.Context())
+		if id != "" && r.Header.Get(m.header) == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set(m.header, id)
+		}
+		return next.RoundTrip(r)
+	})
+}
+
+// valid returns true if the id can be used as a request id.
+func (m *Middleware) valid(id string) bool {
+	if id == "" || len(id) > m.maxLen {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// FromContext returns the request id in the ctx.
+// It returns an empty string if not found.
+func FromContext(ctx context.Context) string {
+	return zuid.FromContext(ctx, ContextKey)
+}
+
+// ContextWithID returns a copy of the ctx with the request id.
+// It can be used to propagate ids of requests
+// received without the [Middleware] such as messages of queues.
+func ContextWithID(ctx context.Context, id string) context.Context {
+	return zuid.ContextWithID(ctx, ContextKey, id)
+}
+
+// NewID returns a new request id generated by [zuid.NewTime].
+// Ids are 48 characters encoded in base32 hex
+// and are sortable by the generated time.
+func NewID() string {
+	return base32.HexEncoding.EncodeToString(zuid.NewTime())
+}
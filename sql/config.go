@@ -0,0 +1,106 @@
+// Package sql provides a database/sql driver wrapper
+// that creates spans and records metrics of database operations.
+package sql
+
+import (
+	"cmp"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+)
+
+// Config is the configuration for the driver wrapper.
+type Config struct {
+	// Tracer creates spans of queries, execs, transactions
+	// and connection creation as child spans of the context.
+	// If nil, spans are not created.
+	Tracer tracing.Tracer
+	// Metrics records durations of database operations.
+	// Use [NewMetrics] to create one and register it
+	// to prometheus or opentelemetry.
+	// If nil, metrics are not recorded.
+	Metrics *Metrics
+	// System is the name of the database system such as "postgresql".
+	// It is added to spans as the "db.system" tag.
+	System string
+	// Statement, if true, adds the statement text to spans
+	// as the "db.statement" tag. Literals in statements
+	// are replaced with "?" by [Sanitize].
+	Statement bool
+	// BackslashEscapes, if true, treats backslashes in string literals
+	// as escape characters when sanitizing statements
+	// as [SanitizeBackslash] does. Set this for MySQL
+	// unless NO_BACKSLASH_ESCAPES mode is enabled.
+	BackslashEscapes bool
+	// MaxStatementLength is the maximum length of the statement text.
+	// If zero or negative, 1024 is used.
+	MaxStatementLength int
+	// DriverName is the name to register the wrapped driver
+	// used by [Register]. If empty, the original driver name
+	// with "+observability" suffix is used.
+	DriverName string
+}
+
+// registerMu serializes [Register].
+var registerMu sync.Mutex
+
+// Register registers the wrapped driver of the given driver name
+// to the database/sql and returns the registered name.
+// Use the returned name to open databases with [sql.Open].
+// It returns an error if the driver is not found
+// or the name is already registered.
+//
+// The driver is obtained with [sql.Open] with an empty DSN
+// because database/sql does not provide a way to look up drivers.
+// Drivers implementing [driver.DriverContext] whose OpenConnector
+// rejects the empty DSN can not be registered.
+// Use [WrapConnector] for such drivers.
+func Register(driverName string, c *Config) (string, error) {
+	if !slices.Contains(sql.Drivers(), driverName) {
+		return "", fmt.Errorf("sql: driver %q not found", driverName)
+	}
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return "", fmt.Errorf("sql: failed to open driver %q with empty dsn: %w", driverName, err)
+	}
+	d := db.Driver()
+	_ = db.Close()
+	name := cmp.Or(c.DriverName, driverName+"+observability")
+	if err := register(name, Wrap(d, c)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// register registers the driver with the name.
+// Drivers registered by other packages concurrently
+// are reported as errors rather than panics.
+func register(name string, d driver.Driver) (err error) {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if slices.Contains(sql.Drivers(), name) {
+		return fmt.Errorf("sql: driver %q already registered", name)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sql: failed to register driver %q: %v", name, r)
+		}
+	}()
+	sql.Register(name, d)
+	return nil
+}
+
+// Wrap returns the wrapped driver of d.
+func Wrap(d driver.Driver, c *Config) driver.Driver {
+	return &wrappedDriver{Driver: d, cfg: newConfig(c)}
+}
+
+// WrapConnector returns the wrapped connector of ct.
+// Use [sql.OpenDB] to open a database with the returned connector.
+func WrapConnector(ct driver.Connector, c *Config) driver.Connector {
+	return &wrappedConnector{Connector: ct, driver: &wrappedDriver{Driver: ct.Driver(), cfg: newConfig(c)}}
+}
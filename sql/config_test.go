@@ -0,0 +1,64 @@
+package sql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// dsnDriver is the driver which rejects the empty DSN.
+type dsnDriver struct {
+	fakeDriver
+}
+
+func (d *dsnDriver) OpenConnector(name string) (driver.Connector, error) {
+	if name == "" {
+		return nil, errors.New("empty dsn")
+	}
+	return nil, errFake
+}
+
+func TestRegister(t *testing.T) {
+	sql.Register("fake-register", &fakeDriver{})
+	sql.Register("fake-register-dsn", &dsnDriver{})
+
+	name, err := Register("fake-register", &Config{})
+	if err != nil || name != "fake-register+observability" {
+		t.Fatalf("unexpected result %q %v", name, err)
+	}
+	if _, err := Register("fake-register", &Config{}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("want already registered error, got %v", err)
+	}
+	if _, err := Register("unknown", &Config{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("want not found error, got %v", err)
+	}
+	if _, err := Register("fake-register-dsn", &Config{}); err == nil || !strings.Contains(err.Error(), "empty dsn") {
+		t.Errorf("want empty dsn error, got %v", err)
+	}
+
+	// Only one of the concurrent registrations succeeds.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Register("fake-register", &Config{DriverName: "fake-register-concurrent"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("want 1 registration, got %d", succeeded)
+	}
+}
@@ -0,0 +1,388 @@
+package sql
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+)
+
+var (
+	_ driver.Driver        = &wrappedDriver{}
+	_ driver.DriverContext = &wrappedDriver{}
+	_ driver.Connector     = &wrappedConnector{}
+	_ io.Closer            = &wrappedConnector{}
+
+	_ driver.Conn               = &wrappedConn{}
+	_ driver.ConnPrepareContext = &wrappedConn{}
+	_ driver.ConnBeginTx        = &wrappedConn{}
+	_ driver.ExecerContext      = &wrappedConn{}
+	_ driver.QueryerContext     = &wrappedConn{}
+	_ driver.Pinger             = &wrappedConn{}
+	_ driver.SessionResetter    = &wrappedConn{}
+	_ driver.Validator          = &wrappedConn{}
+	_ driver.NamedValueChecker  = &wrappedConn{}
+
+	_ driver.Stmt              = &wrappedStmt{}
+	_ driver.StmtExecContext   = &wrappedStmt{}
+	_ driver.StmtQueryContext  = &wrappedStmt{}
+	_ driver.NamedValueChecker = &wrappedStmt{}
+	_ driver.ColumnConverter   = &converterStmt{}
+
+	_ driver.Tx = &wrappedTx{}
+)
+
+// config is the internal configuration
+// shared by wrapped types.
+type config struct {
+	tracer    tracing.Tracer
+	metrics   *Metrics
+	system    string
+	statement bool
+	backslash bool
+	maxLen    int
+}
+
+func newConfig(c *Config) *config {
+	return &config{
+		tracer:    c.Tracer,
+		metrics:   c.Metrics,
+		system:    c.System,
+		statement: c.Statement,
+		backslash: c.BackslashEscapes,
+		maxLen:    cmp.Or(max(c.MaxStatementLength, 0), 1024),
+	}
+}
+
+// trace starts a span of the operation and returns
+// the function that must be called when the operation finished.
+func (c *config) trace(ctx context.Context, op, query string) (context.Context, func(error)) {
+	start := time.Now()
+	finish := func() {}
+	if c.tracer != nil {
+		tags := map[string]string{"db.operation": op}
+		if c.system != "" {
+			tags["db.system"] = c.system
+		}
+		if c.statement && query != "" {
+			tags["db.statement"] = sanitize(query, c.maxLen, c.backslash)
+		}
+		ctx, finish = c.tracer.Trace(ctx, "sql."+op, tags)
+	}
+	return ctx, func(err error) {
+		finish()
+		if c.metrics == nil || errors.Is(err, driver.ErrSkip) {
+			return
+		}
+		if op == "connect" {
+			c.metrics.observeCreate(ctx, time.Since(start), err)
+		} else {
+			c.metrics.observeOperation(ctx, op, time.Since(start), err)
+		}
+	}
+}
+
+type wrappedDriver struct {
+	driver.Driver
+	cfg *config
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	ct, err := d.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return ct.Connect(context.Background())
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		ct, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &wrappedConnector{Connector: ct, driver: d}, nil
+	}
+	return &wrappedConnector{Connector: &dsnConnector{dsn: name, driver: d.Driver}, driver: d}, nil
+}
+
+// dsnConnector is the connector for drivers
+// that do not implement [driver.DriverContext].
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type wrappedConnector struct {
+	driver.Connector
+	driver *wrappedDriver
+}
+
+// Connect creates a new connection.
+// It is called when the pool has no idle connections
+// and a span is created as a child of the operation
+// which acquires the connection.
+func (c *wrappedConnector) Connect(ctx context.Context) (conn driver.Conn, err error) {
+	_, finish := c.driver.cfg.trace(ctx, "connect", "")
+	defer func() { finish(err) }()
+	conn, err = c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, cfg: c.driver.cfg}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close closes the underlying connector if it implements [io.Closer].
+func (c *wrappedConnector) Close() error {
+	if cl, ok := c.Connector.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+type wrappedConn struct {
+	driver.Conn
+	cfg *config
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	ctx, finish := c.cfg.trace(ctx, "prepare", query)
+	defer func() { finish(err) }()
+	if cp, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = cp.PrepareContext(ctx, query)
+	} else {
+		stmt, err = prepare(ctx, c.Conn, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws := &wrappedStmt{Stmt: stmt, conn: c.Conn, query: query, cfg: c.cfg}
+	if cc, ok := stmt.(driver.ColumnConverter); ok {
+		return &converterStmt{wrappedStmt: ws, cc: cc}, nil
+	}
+	return ws, nil
+}
+
+// prepare prepares the statement with the conn which does not
+// implement [driver.ConnPrepareContext] in the same way as the database/sql.
+// The statement is closed if the ctx is done while preparing.
+func prepare(ctx context.Context, conn driver.Conn, query string) (driver.Stmt, error) {
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		_ = stmt.Close()
+		return nil, ctx.Err()
+	default:
+		return stmt, nil
+	}
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	spanCtx, finish := c.cfg.trace(ctx, "begin", "")
+	defer func() { finish(err) }()
+	if cb, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = cb.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = begin(spanCtx, c.Conn, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedTx{Tx: tx, ctx: ctx, cfg: c.cfg}, nil
+}
+
+// begin begins the transaction with the conn which does not
+// implement [driver.ConnBeginTx] in the same way as the database/sql.
+// Non-default options are rejected and the transaction
+// is rolled back if the ctx is done while beginning.
+func begin(ctx context.Context, conn driver.Conn, opts driver.TxOptions) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		_ = tx.Rollback()
+		return nil, ctx.Err()
+	default:
+		return tx, nil
+	}
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, finish := c.cfg.trace(ctx, "exec", query)
+	defer func() { finish(err) }()
+	return ec.ExecContext(ctx, query, args)
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, finish := c.cfg.trace(ctx, "query", query)
+	defer func() { finish(err) }()
+	return qc.QueryContext(ctx, query, args)
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type wrappedStmt struct {
+	driver.Stmt
+	// conn is the connection which prepared the statement.
+	conn  driver.Conn
+	query string
+	cfg   *config
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
+	ctx, finish := s.cfg.trace(ctx, "exec", s.query)
+	defer func() { finish(err) }()
+	if se, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return se.ExecContext(ctx, args)
+	}
+	vals, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(vals)
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	ctx, finish := s.cfg.trace(ctx, "query", s.query)
+	defer func() { finish(err) }()
+	if sq, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return sq.QueryContext(ctx, args)
+	}
+	vals, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(vals)
+}
+
+// CheckNamedValue checks the value with the statement
+// or the connection as the database/sql does.
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	if nc, ok := s.conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// converterStmt is the wrapped statement
+// which implements [driver.ColumnConverter].
+type converterStmt struct {
+	*wrappedStmt
+	cc driver.ColumnConverter
+}
+
+func (s *converterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.cc.ColumnConverter(idx)
+}
+
+type wrappedTx struct {
+	driver.Tx
+	// ctx is the context given when the transaction began.
+	ctx context.Context
+	cfg *config
+}
+
+func (t *wrappedTx) Commit() (err error) {
+	_, finish := t.cfg.trace(t.ctx, "commit", "")
+	defer func() { finish(err) }()
+	return t.Tx.Commit()
+}
+
+func (t *wrappedTx) Rollback() (err error) {
+	_, finish := t.cfg.trace(t.ctx, "rollback", "")
+	defer func() { finish(err) }()
+	return t.Tx.Rollback()
+}
+
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of named parameters")
+		}
+		vals[i] = arg.Value
+	}
+	return vals, nil
+}
@@ -0,0 +1,412 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing/tracingtest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var errFake = errors.New("fake error")
+
+// fakeDriver is the in-memory driver.
+// Statements "error" fail and others succeed.
+// Connections implement only the non-context interfaces if legacy.
+type fakeDriver struct {
+	legacy bool
+
+	mu sync.Mutex
+	// spans is the names of the current spans
+	// given to the context-aware methods.
+	spans []string
+	// args is the arguments of the last statement.
+	args []driver.Value
+	// closed is the number of closed statements.
+	closed int
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if name == "error" {
+		return nil, errFake
+	}
+	c := &fakeConn{d: d}
+	if d.legacy {
+		return c, nil
+	}
+	return &fakeCtxConn{fakeConn: c}, nil
+}
+
+func (d *fakeDriver) record(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s := tracingtest.SpanFromContext(ctx); s != nil {
+		d.spans = append(d.spans, s.Snapshot().Name)
+	}
+}
+
+func (d *fakeDriver) lastArgs() []driver.Value {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.args
+}
+
+// point is the argument type converted by the fakeConn.
+type point struct{ X, Y int }
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if query == "error" {
+		return nil, errFake
+	}
+	return &fakeStmt{d: c.d}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if p, ok := nv.Value.(point); ok {
+		nv.Value = fmt.Sprintf("%d,%d", p.X, p.Y)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+type fakeCtxConn struct {
+	*fakeConn
+}
+
+func (c *fakeCtxConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.d.record(ctx)
+	return c.Prepare(query)
+}
+
+func (c *fakeCtxConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	c.d.record(ctx)
+	return c.Begin()
+}
+
+func (c *fakeCtxConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.record(ctx)
+	if query == "error" {
+		return nil, errFake
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeCtxConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(ctx)
+	if query == "error" {
+		return nil, errFake
+	}
+	return &fakeRows{}, nil
+}
+
+// fakeStmt converts string arguments to upper case
+// with the [driver.ColumnConverter].
+type fakeStmt struct {
+	d *fakeDriver
+}
+
+func (s *fakeStmt) Close() error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.closed++
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.args = args
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.args = args
+	return &fakeRows{}, nil
+}
+
+func (s *fakeStmt) ColumnConverter(_ int) driver.ValueConverter {
+	return upperConverter{}
+}
+
+type upperConverter struct{}
+
+func (upperConverter) ConvertValue(v any) (driver.Value, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+// fakeRows returns a row.
+type fakeRows struct {
+	n int
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n > 0 {
+		return io.EOF
+	}
+	r.n++
+	dest[0] = int64(1)
+	return nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+// newDB opens the database of the wrapped fakeDriver
+// with the recorder and the metrics registered to the returned reader.
+func newDB(t *testing.T, d *fakeDriver, dsn string) (*sql.DB, *tracingtest.Recorder, *sdkmetric.ManualReader) {
+	t.Helper()
+	rec := tracingtest.NewRecorder()
+	m := NewMetrics("fake")
+	reader := sdkmetric.NewManualReader()
+	if _, err := m.RegisterMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")); err != nil {
+		t.Fatal(err)
+	}
+	wd := Wrap(d, &Config{Tracer: rec, Metrics: m, System: "fake", Statement: true})
+	ct, err := wd.(driver.DriverContext).OpenConnector(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(ct)
+	t.Cleanup(func() { db.Close() })
+	return db, rec, reader
+}
+
+// count returns the number of the observations of the histogram with the attributes.
+func count(t *testing.T, reader *sdkmetric.ManualReader, name string, attrs ...attribute.KeyValue) uint64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	want := attribute.NewSet(append(attrs, attribute.String("db_system", "fake"))...)
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			h, ok := md.Data.(metricdata.Histogram[float64])
+			if md.Name != name || !ok {
+				continue
+			}
+			for _, dp := range h.DataPoints {
+				if dp.Attributes.Equals(&want) {
+					return dp.Count
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func operation(op, status string) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("operation", op), attribute.String("status", status)}
+}
+
+func TestDriver(t *testing.T) {
+	testCases := map[string]struct {
+		legacy bool
+		// errOp is the failed operation of the "error" statement.
+		errOp string
+		// prepared is the number of prepare spans
+		// including the failed one.
+		prepared int
+	}{
+		"context driver": {false, "exec", 0},
+		"legacy driver":  {true, "prepare", 4},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d := &fakeDriver{legacy: tc.legacy}
+			db, rec, reader := newDB(t, d, "")
+			ctx, finish := rec.Trace(context.Background(), "parent", nil)
+
+			rows, err := db.QueryContext(ctx, "SELECT n FROM users WHERE name = 'alice'")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+			}
+			if err := rows.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "bob"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.ExecContext(ctx, "error"); !errors.Is(err, errFake) {
+				t.Errorf("want %v, got %v", errFake, err)
+			}
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM users"); err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			tx, err = db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+			finish()
+
+			parent := rec.AssertSpan(t, "parent", nil)
+			for _, s := range rec.Spans() {
+				if s.Name != "parent" && s.ParentID != parent.SpanID {
+					t.Errorf("%s must be the child of the parent span", s.Name)
+				}
+			}
+			if n := len(rec.FindSpans("sql.connect")); n != 1 {
+				t.Errorf("want 1 connect span, got %d", n)
+			}
+			rec.AssertSpan(t, "sql.query", map[string]string{
+				"db.operation": "query",
+				"db.system":    "fake",
+				"db.statement": "SELECT n FROM users WHERE name = ?",
+			})
+			rec.AssertSpan(t, "sql.exec", map[string]string{"db.statement": "UPDATE users SET name = ?"})
+			rec.AssertSpan(t, "sql.exec", map[string]string{"db.statement": "DELETE FROM users"})
+			rec.AssertSpan(t, "sql."+tc.errOp, map[string]string{"db.statement": "error"})
+			rec.AssertSpan(t, "sql.begin", map[string]string{"db.operation": "begin"})
+			rec.AssertSpan(t, "sql.commit", map[string]string{"db.operation": "commit"})
+			rec.AssertSpan(t, "sql.rollback", map[string]string{"db.operation": "rollback"})
+			if n := len(rec.FindSpans("sql.prepare")); n != tc.prepared {
+				t.Errorf("want %d prepare spans, got %d", tc.prepared, n)
+			}
+			if !tc.legacy {
+				// The driver is called with the context of the operation span.
+				for _, name := range []string{"sql.query", "sql.exec", "sql.begin"} {
+					if !slices.Contains(d.spans, name) {
+						t.Errorf("driver not called in the span %s: %v", name, d.spans)
+					}
+				}
+			}
+
+			want := map[string]uint64{
+				"query ok":    1,
+				"exec ok":     2,
+				"begin ok":    2,
+				"commit ok":   1,
+				"rollback ok": 1,
+			}
+			if tc.prepared > 0 {
+				want["prepare ok"] = uint64(tc.prepared) - 1
+			}
+			want[tc.errOp+" error"] = 1
+			for k, v := range want {
+				op, status, _ := strings.Cut(k, " ")
+				if got := count(t, reader, "db_client_operation_duration_seconds", operation(op, status)...); got != v {
+					t.Errorf("%s: want %d observations, got %d", k, v, got)
+				}
+			}
+			if got := count(t, reader, "db_client_connection_create_duration_seconds", attribute.String("status", "ok")); got != 1 {
+				t.Errorf("want 1 connection created, got %d", got)
+			}
+		})
+	}
+}
+
+func TestDriver_connectError(t *testing.T) {
+	db, rec, reader := newDB(t, &fakeDriver{}, "error")
+	if err := db.PingContext(context.Background()); !errors.Is(err, errFake) {
+		t.Errorf("want %v, got %v", errFake, err)
+	}
+	rec.AssertSpan(t, "sql.connect", map[string]string{"db.operation": "connect"})
+	if got := count(t, reader, "db_client_connection_create_duration_seconds", attribute.String("status", "error")); got == 0 {
+		t.Error("failed connection must be recorded")
+	}
+}
+
+func TestDriver_columnConverter(t *testing.T) {
+	d := &fakeDriver{legacy: true}
+	db, _, _ := newDB(t, d, "")
+	if _, err := db.Exec("INSERT INTO users VALUES (?, ?)", "alice", point{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	// Strings are converted by the statement and points by the connection.
+	want := []driver.Value{"ALICE", "1,2"}
+	if got := d.lastArgs(); !slices.Equal(got, want) {
+		t.Errorf("want args %v, got %v", want, got)
+	}
+}
+
+func TestDriver_fallbackContext(t *testing.T) {
+	d := &fakeDriver{legacy: true}
+	c := &wrappedConn{Conn: &fakeConn{d: d}, cfg: newConfig(&Config{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.PrepareContext(ctx, "SELECT 1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("prepare: want %v, got %v", context.Canceled, err)
+	}
+	if d.closed != 1 {
+		t.Errorf("statement prepared after canceled must be closed")
+	}
+	if _, err := c.BeginTx(ctx, driver.TxOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("begin: want %v, got %v", context.Canceled, err)
+	}
+	if _, err := c.BeginTx(context.Background(), driver.TxOptions{ReadOnly: true}); err == nil {
+		t.Error("read-only transaction must be rejected")
+	}
+	if _, err := c.BeginTx(context.Background(), driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)}); err == nil {
+		t.Error("non-default isolation level must be rejected")
+	}
+
+	stmt, err := c.PrepareContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.(driver.StmtExecContext).ExecContext(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("exec: want %v, got %v", context.Canceled, err)
+	}
+	if _, err := stmt.(driver.StmtQueryContext).QueryContext(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("query: want %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,223 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+var (
+	_ prometheus.Collector = &Metrics{}
+)
+
+// Metrics records the metrics of database operations.
+// Metrics implements [prometheus.Collector] and can also
+// be registered to an OpenTelemetry meter with [Metrics.RegisterMeter].
+// Following histograms are recorded with the "db_system" label.
+//
+//   - db_client_operation_duration_seconds{operation,status}: duration of queries, execs and transactions.
+//   - db_client_connection_create_duration_seconds{status}: duration of creating new connections.
+//   - db_client_connections_in_use: number of connections in use sampled by [Metrics.ObservePool].
+//   - db_client_connections_idle: number of idle connections sampled by [Metrics.ObservePool].
+//   - db_client_connection_wait_duration_seconds: average time waited for connections sampled by [Metrics.ObservePool].
+type Metrics struct {
+	system string
+
+	operation *prometheus.HistogramVec
+	create    *prometheus.HistogramVec
+	inUse     prometheus.Histogram
+	idle      prometheus.Histogram
+	wait      prometheus.Histogram
+
+	// otel is the histograms registered by RegisterMeter.
+	otel atomic.Pointer[otelHistograms]
+}
+
+type otelHistograms struct {
+	operation metric.Float64Histogram
+	create    metric.Float64Histogram
+	inUse     metric.Int64Histogram
+	idle      metric.Int64Histogram
+	wait      metric.Float64Histogram
+}
+
+// NewMetrics returns a new [Metrics].
+// The system is the name of the database system
+// such as "postgresql" exposed as the "db_system" label.
+func NewMetrics(system string) *Metrics {
+	labels := prometheus.Labels{"db_system": system}
+	return &Metrics{
+		system: system,
+		operation: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "db_client_operation_duration_seconds",
+			Help:        "Duration of database operations",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"operation", "status"}),
+		create: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "db_client_connection_create_duration_seconds",
+			Help:        "Duration of creating database connections",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"status"}),
+		inUse: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "db_client_connections_in_use",
+			Help:        "Number of database connections in use",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		idle: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "db_client_connections_idle",
+			Help:        "Number of idle database connections",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		wait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "db_client_connection_wait_duration_seconds",
+			Help:        "Average time waited for database connections",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}),
+	}
+}
+
+// observeOperation records the duration of an operation.
+func (m *Metrics) observeOperation(ctx context.Context, op string, d time.Duration, err error) {
+	status := statusOf(err)
+	m.operation.WithLabelValues(op, status).Observe(d.Seconds())
+	if h := m.otel.Load(); h != nil {
+		h.operation.Record(ctx, d.Seconds(), metric.WithAttributes(
+			attribute.String("db_system", m.system),
+			attribute.String("operation", op),
+			attribute.String("status", status),
+		))
+	}
+}
+
+// observeCreate records the duration of creating a connection.
+func (m *Metrics) observeCreate(ctx context.Context, d time.Duration, err error) {
+	status := statusOf(err)
+	m.create.WithLabelValues(status).Observe(d.Seconds())
+	if h := m.otel.Load(); h != nil {
+		h.create.Record(ctx, d.Seconds(), metric.WithAttributes(
+			attribute.String("db_system", m.system),
+			attribute.String("status", status),
+		))
+	}
+}
+
+// ObservePool samples the connection pool statistics of the db
+// every interval until the ctx is canceled.
+// If the interval is zero or negative, 10 seconds is used.
+// ObservePool blocks and should be called in a new goroutine.
+func (m *Metrics) ObservePool(ctx context.Context, db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	prev := db.Stats()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stats := db.Stats()
+		m.observePool(ctx, prev, stats)
+		prev = stats
+	}
+}
+
+func (m *Metrics) observePool(ctx context.Context, prev, cur sql.DBStats) {
+	m.inUse.Observe(float64(cur.InUse))
+	m.idle.Observe(float64(cur.Idle))
+	var wait float64
+	if n := cur.WaitCount - prev.WaitCount; n > 0 {
+		wait = (cur.WaitDuration - prev.WaitDuration).Seconds() / float64(n)
+		m.wait.Observe(wait)
+	}
+	if h := m.otel.Load(); h != nil {
+		opt := metric.WithAttributes(attribute.String("db_system", m.system))
+		h.inUse.Record(ctx, int64(cur.InUse), opt)
+		h.idle.Record(ctx, int64(cur.Idle), opt)
+		if cur.WaitCount > prev.WaitCount {
+			h.wait.Record(ctx, wait, opt)
+		}
+	}
+}
+
+// Describe implements [prometheus.Collector].
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.operation.Describe(ch)
+	m.create.Describe(ch)
+	m.inUse.Describe(ch)
+	m.idle.Describe(ch)
+	m.wait.Describe(ch)
+}
+
+// Collect implements [prometheus.Collector].
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.operation.Collect(ch)
+	m.create.Collect(ch)
+	m.inUse.Collect(ch)
+	m.idle.Collect(ch)
+	m.wait.Collect(ch)
+}
+
+// RegisterMeter registers the histograms to the meter.
+// Values are recorded to both prometheus and the meter after registered.
+// Call [metric.Registration.Unregister] to stop recording to the meter.
+func (m *Metrics) RegisterMeter(meter metric.Meter) (metric.Registration, error) {
+	operation, err := meter.Float64Histogram("db_client_operation_duration_seconds",
+		metric.WithDescription("Duration of database operations"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	create, err := meter.Float64Histogram("db_client_connection_create_duration_seconds",
+		metric.WithDescription("Duration of creating database connections"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := meter.Int64Histogram("db_client_connections_in_use",
+		metric.WithDescription("Number of database connections in use"))
+	if err != nil {
+		return nil, err
+	}
+	idle, err := meter.Int64Histogram("db_client_connections_idle",
+		metric.WithDescription("Number of idle database connections"))
+	if err != nil {
+		return nil, err
+	}
+	wait, err := meter.Float64Histogram("db_client_connection_wait_duration_seconds",
+		metric.WithDescription("Average time waited for database connections"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	m.otel.Store(&otelHistograms{operation: operation, create: create, inUse: inUse, idle: idle, wait: wait})
+	return &registration{m: m}, nil
+}
+
+// registration stops recording to the meter when unregistered.
+type registration struct {
+	embedded.Registration
+	m *Metrics
+}
+
+func (r *registration) Unregister() error {
+	r.m.otel.Store(nil)
+	return nil
+}
+
+func statusOf(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,176 @@
+package sql
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Sanitize replaces literals in the SQL statement with "?"
+// so that statements do not contain sensitive values.
+// String literals quoted by single quotes, numeric literals
+// and dollar-quoted strings are replaced.
+// Identifiers quoted by double quotes or back quotes are kept.
+// Comments are removed and consecutive white spaces are collapsed.
+// The result is truncated to maxLen bytes at a rune boundary
+// if maxLen is positive.
+// Quotes in string literals are escaped by doubling them
+// as in the standard SQL. Use [SanitizeBackslash] for dialects
+// such as MySQL which also escape quotes by backslashes.
+// The rest of the statement is replaced when a quote is not closed
+// so that literals are never leaked.
+func Sanitize(query string, maxLen int) string {
+	return sanitize(query, maxLen, false)
+}
+
+// SanitizeBackslash is the same as [Sanitize] except that
+// backslashes escape the next character in string literals.
+func SanitizeBackslash(query string, maxLen int) string {
+	return sanitize(query, maxLen, true)
+}
+
+func sanitize(query string, maxLen int, backslash bool) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	write := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// String literal. Quotes are escaped by doubling them.
+			i++
+			for i < len(query) {
+				if backslash && query[i] == '\\' {
+					i += 2
+					continue
+				}
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+			write("?")
+		case c == '"' || c == '`':
+			// Quoted identifier.
+			j := strings.IndexByte(query[i+1:], c)
+			if j < 0 {
+				// Unbalanced quote may be a part of a literal.
+				write("?")
+				i = len(query)
+				continue
+			}
+			write(query[i : i+j+2])
+			i += j + 2
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			// Placeholder such as "$1".
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			write(query[i:j])
+			i = j
+		case c == '$' && i+1 < len(query) && (query[i+1] == '$' || isIdentStart(query[i+1])):
+			// Dollar-quoted string such as "$$text$$" or "$tag$text$tag$".
+			j := strings.IndexByte(query[i+1:], '$')
+			if j < 0 || !isTag(query[i+1:i+1+j]) {
+				// Not a dollar quote.
+				write("$")
+				i++
+				continue
+			}
+			tag := query[i : i+j+2]
+			if end := strings.Index(query[i+len(tag):], tag); end < 0 {
+				i = len(query)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+			write("?")
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			// Line comment.
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i
+			}
+			i += j
+			space = true
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			// Block comment.
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				i = len(query)
+			} else {
+				i += j + 4
+			}
+			space = true
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			// Numeric literal.
+			j := i
+			for j < len(query) && (isHex(query[j]) || query[j] == '.' || query[j] == 'x' || query[j] == 'X' || query[j] == '_' ||
+				((query[j] == '+' || query[j] == '-') && (query[j-1] == 'e' || query[j-1] == 'E'))) {
+				j++
+			}
+			write("?")
+			i = j
+		case isIdentStart(c):
+			// Identifiers and keywords may contain digits.
+			j := i
+			for j < len(query) && (isIdentStart(query[j]) || isDigit(query[j]) || query[j] == '$') {
+				j++
+			}
+			write(query[i:j])
+			i = j
+		case unicode.IsSpace(rune(c)):
+			space = true
+			i++
+		default:
+			write(query[i : i+1])
+			i++
+		}
+		if maxLen > 0 && b.Len() >= maxLen {
+			break
+		}
+	}
+	s := b.String()
+	if maxLen > 0 && len(s) > maxLen {
+		n := maxLen
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// isTag reports whether the s is a valid tag of dollar quotes.
+func isTag(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isIdentStart(s[i]) && (i == 0 || !isDigit(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
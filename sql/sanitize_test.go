@@ -0,0 +1,46 @@
+package sql
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitize(t *testing.T) {
+	testCases := map[string]struct {
+		query     string
+		maxLen    int
+		backslash bool
+		want      string
+	}{
+		"string":             {query: "SELECT * FROM t WHERE a = 'x'", want: "SELECT * FROM t WHERE a = ?"},
+		"doubled quotes":     {query: "SELECT 'it''s', 'b'", want: "SELECT ?, ?"},
+		"backslash standard": {query: `UPDATE users SET dir = 'C:\', password = 'hunter2' WHERE id = 1`, want: "UPDATE users SET dir = ?, password = ? WHERE id = ?"},
+		"backslash escape":   {query: `SELECT 'it\'s', 'b'`, backslash: true, want: "SELECT ?, ?"},
+		"dollar quotes":      {query: "SELECT $$a'b$$, $tag$x$y$tag$", want: "SELECT ?, ?"},
+		"dollar unclosed":    {query: "SELECT $tag$secret", want: "SELECT ?"},
+		"dollar not tag":     {query: "SELECT $x-1, 'secret'", want: "SELECT $x-?, ?"},
+		"line comment":       {query: "SELECT a -- 'secret'\nFROM t", want: "SELECT a FROM t"},
+		"block comment":      {query: "SELECT /* secret */ a FROM t", want: "SELECT a FROM t"},
+		"block unclosed":     {query: "SELECT a /* secret", want: "SELECT a"},
+		"numerics":           {query: "SELECT 1, -2.5, .5, 1e+10, 0xFF, 1_000", want: "SELECT ?, -?, ?, ?, ?, ?"},
+		"identifiers":        {query: "SELECT t1.c2, \"a'b\", `c` FROM t_3", want: "SELECT t1.c2, \"a'b\", `c` FROM t_3"},
+		"placeholders":       {query: "SELECT * FROM t WHERE a = $1 AND b = ? AND c = :name", want: "SELECT * FROM t WHERE a = $1 AND b = ? AND c = :name"},
+		"unterminated":       {query: "SELECT 'secret", want: "SELECT ?"},
+		"unterminated ident": {query: "SELECT \"a, 'secret'", want: "SELECT ?"},
+		"spaces":             {query: "  SELECT\n\ta\t FROM  t ", want: "SELECT a FROM t"},
+		"truncate":           {query: "SELECT a FROM t", maxLen: 6, want: "SELECT"},
+		"truncate utf8":      {query: "SELECT \"名前\" FROM t", maxLen: 10, want: "SELECT \""},
+		"truncate rune":      {query: "SELECT \"名前\" FROM t", maxLen: 11, want: "SELECT \"名"},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := sanitize(tc.query, tc.maxLen, tc.backslash)
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("invalid UTF-8 %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// SetBaggage returns a copy of the ctx with the baggage entry.
+// Baggage is stored in the context in the same way as opentelemetry
+// and is propagated to remote services by all tracers.
+// The key must be a valid W3C baggage key.
+// Existing entry of the key is replaced.
+func SetBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	m, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx, fmt.Errorf("tracing: invalid baggage entry %q: %w", key, err)
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx, fmt.Errorf("tracing: invalid baggage entry %q: %w", key, err)
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
+
+// GetBaggage returns the baggage value of the key in the ctx.
+// It returns an empty string if not found.
+func GetBaggage(ctx context.Context, key string) string {
+	return baggage.FromContext(ctx).Member(key).Value()
+}
+
+// DeleteBaggage returns a copy of the ctx without the baggage entry of the key.
+func DeleteBaggage(ctx context.Context, key string) context.Context {
+	return baggage.ContextWithBaggage(ctx, baggage.FromContext(ctx).DeleteMember(key))
+}
+
+// BaggageFromContext returns all baggage entries in the ctx.
+// It returns an empty map if the ctx has no baggage.
+func BaggageFromContext(ctx context.Context) map[string]string {
+	members := baggage.FromContext(ctx).Members()
+	m := make(map[string]string, len(members))
+	for _, mem := range members {
+		m[mem.Key()] = mem.Value()
+	}
+	return m
+}
+
+// SelectBaggage returns the baggage entries of the keys in the ctx.
+// Keys not found in the baggage are omitted.
+// It returns nil if no entries found.
+func SelectBaggage(ctx context.Context, keys []string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		return nil
+	}
+	var m map[string]string
+	for _, k := range keys {
+		mem := b.Member(k)
+		if mem.Key() == "" {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]string, len(keys))
+		}
+		m[k] = mem.Value()
+	}
+	return m
+}
+
+// InjectBaggage injects the baggage in the ctx
+// into the carrier in the W3C baggage format.
+func InjectBaggage(ctx context.Context, carrier Carrier) {
+	propagation.Baggage{}.Inject(ctx, carrier)
+}
+
+// ExtractBaggage extracts the baggage in the W3C baggage format
+// from the carrier. Extracted entries are merged into the
+// baggage in the ctx replacing the entries of the same keys.
+func ExtractBaggage(ctx context.Context, carrier Carrier) context.Context {
+	remote := baggage.FromContext(propagation.Baggage{}.Extract(context.Background(), carrier))
+	if remote.Len() == 0 {
+		return ctx
+	}
+	return mergeBaggage(ctx, remote.Members()...)
+}
+
+// mergeBaggage merges the members into the baggage in the ctx
+// replacing the entries of the same keys.
+func mergeBaggage(ctx context.Context, members ...baggage.Member) context.Context {
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		if nb, err := baggage.New(members...); err == nil {
+			return baggage.ContextWithBaggage(ctx, nb)
+		}
+	}
+	for _, m := range members {
+		if nb, err := b.SetMember(m); err == nil {
+			b = nb
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxCallerDepth is the maximum number of frames inspected.
+const maxCallerDepth = 32
+
+// maxCallerCache is the maximum number of cached callers.
+const maxCallerCache = 4096
+
+// DefaultCallerSkipPrefixes is the default function name prefixes
+// of the frames skipped when capturing callers.
+// Frames of the runtime and net/http packages, the zhttp middleware
+// chain and this module are skipped so that the callers point at the user code.
+var DefaultCallerSkipPrefixes = []string{
+	"runtime.",
+	"net/http.",
+	"github.com/aileron-projects/go/znet/zhttp.",
+	"github.com/aileron-projects/aileron-observability/",
+}
+
+// CallerConfig is the configuration of the [CallerCapture].
+type CallerConfig struct {
+	// Skip is the number of frames skipped above the
+	// middleware before the SkipPrefixes are applied.
+	// Negative values are treated as zero.
+	Skip int `json:"skip"`
+	// SkipPrefixes is the list of function name prefixes such as
+	// "github.com/example/framework." of the frames to be skipped.
+	// Function names are fully qualified with the package path.
+	// If nil, [DefaultCallerSkipPrefixes] is used.
+	// Set an empty slice to disable the filtering.
+	// If all frames are skipped, no caller is captured.
+	SkipPrefixes []string `json:"skipPrefixes"`
+	// StackFrames is the number of frames from the caller
+	// recorded as the "caller.stack" span event.
+	// If zero or negative, the stack is not recorded.
+	// At most 32 frames are inspected including skipped ones.
+	StackFrames int `json:"stackFrames"`
+}
+
+// Caller is the caller information.
+type Caller struct {
+	// File is the directory and the file name such as "app/main.go".
+	File string
+	// Func is the fully qualified function name.
+	Func string
+	// Line is the line number.
+	Line int
+	// Stack is the frames from the caller such as
+	// "main.main (app/main.go:10)" joined by "\n".
+	// It is empty if the [CallerConfig.StackFrames] is zero.
+	Stack string
+}
+
+// CallerCapture captures the callers of the middleware.
+// Resolved callers are cached by the program counters
+// so that symbols are resolved only once for each call path.
+// Use [NewCallerCapture] to create a new instance.
+type CallerCapture struct {
+	skip     int
+	prefixes []string
+	frames   int
+
+	cache sync.Map // [maxCallerDepth]uintptr to *Caller
+	size  atomic.Int64
+}
+
+// NewCallerCapture returns a new [CallerCapture].
+// If the c is nil, a zero value config is used.
+func NewCallerCapture(c *CallerConfig) *CallerCapture {
+	if c == nil {
+		c = &CallerConfig{}
+	}
+	cc := &CallerCapture{
+		skip:     max(c.Skip, 0),
+		prefixes: c.SkipPrefixes,
+		frames:   max(c.StackFrames, 0),
+	}
+	if cc.prefixes == nil {
+		cc.prefixes = DefaultCallerSkipPrefixes
+	}
+	return cc
+}
+
+// Capture returns the caller of the function that called Capture.
+// It must be called directly by the middleware functions.
+// It returns false if the caller is not available
+// or all frames are skipped by the [CallerConfig.SkipPrefixes].
+// For example, server middleware called directly by
+// the net/http server do not have callers.
+func (cc *CallerCapture) Capture() (*Caller, bool) {
+	var pcs [maxCallerDepth]uintptr
+	// Skip runtime.Callers, Capture and the middleware function.
+	n := runtime.Callers(3+cc.skip, pcs[:])
+	if n == 0 {
+		return nil, false
+	}
+	if v, ok := cc.cache.Load(pcs); ok {
+		c := v.(*Caller)
+		return c, c != nil
+	}
+	c := cc.resolve(pcs[:n])
+	if cc.size.Load() < maxCallerCache {
+		if _, loaded := cc.cache.LoadOrStore(pcs, c); !loaded {
+			cc.size.Add(1)
+		}
+	}
+	return c, c != nil
+}
+
+// resolve returns the caller of the program counters.
+// It returns nil if all frames are skipped.
+func (cc *CallerCapture) resolve(pcs []uintptr) *Caller {
+	var frames []runtime.Frame
+	it := runtime.CallersFrames(pcs)
+	for {
+		f, more := it.Next()
+		frames = append(frames, f)
+		if !more {
+			break
+		}
+	}
+	start := -1
+	for i, f := range frames {
+		if !cc.skipped(f.Function) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	f := frames[start]
+	c := &Caller{File: shortFile(f.File), Func: f.Function, Line: f.Line}
+	if cc.frames > 0 {
+		var b strings.Builder
+		for i, f := range frames[start:min(start+cc.frames, len(frames))] {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(f.Function + " (" + shortFile(f.File) + ":" + strconv.Itoa(f.Line) + ")")
+		}
+		c.Stack = b.String()
+	}
+	return c
+}
+
+// skipped returns true if the function should be skipped.
+func (cc *CallerCapture) skipped(fn string) bool {
+	for _, p := range cc.prefixes {
+		if strings.HasPrefix(fn, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// shortFile returns the directory and the file name of the file.
+func shortFile(file string) string {
+	return path.Base(path.Dir(file)) + "/" + path.Base(file)
+}
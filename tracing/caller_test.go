@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// captureMiddleware captures the caller as the middleware does.
+func captureMiddleware(cc *CallerCapture) (*Caller, bool) {
+	return cc.Capture()
+}
+
+func TestCallerCapture(t *testing.T) {
+	cc := NewCallerCapture(&CallerConfig{SkipPrefixes: []string{"runtime.", "testing."}, StackFrames: 2})
+	_, _, line, _ := runtime.Caller(0)
+	c, ok := captureMiddleware(cc) // Must be the next line of the runtime.Caller.
+	if !ok {
+		t.Fatal("caller must be captured")
+	}
+	if c.Func != "github.com/aileron-projects/aileron-observability/tracing.TestCallerCapture" ||
+		c.File != "tracing/caller_test.go" || c.Line != line+1 {
+		t.Errorf("unexpected caller %+v", c)
+	}
+	if frames := strings.Split(c.Stack, "\n"); len(frames) != 2 || !strings.HasPrefix(frames[0], c.Func+" (") {
+		t.Errorf("stack must start from the caller: %q", c.Stack)
+	}
+
+	// Cached callers are returned for the same call path.
+	for range 2 {
+		if got, ok := captureMiddleware(cc); !ok || got.Func != c.Func {
+			t.Errorf("unexpected cached caller %+v", got)
+		}
+	}
+}
+
+func TestCallerCapture_serverMiddleware(t *testing.T) {
+	testCases := map[string]*CallerConfig{
+		"default prefixes": nil,
+		"empty prefixes":   {SkipPrefixes: []string{}},
+	}
+	for name, c := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cc := NewCallerCapture(c)
+			var got *Caller
+			var ok bool
+			// The handler is the middleware called by the net/http server.
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok = cc.Capture()
+			}))
+			defer svr.Close()
+			res, err := http.Get(svr.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if c == nil {
+				// Only net/http and runtime frames are above the middleware.
+				if ok {
+					t.Errorf("caller must not be captured, got %+v", got)
+				}
+				return
+			}
+			if !ok || !strings.HasPrefix(got.Func, "net/http.") {
+				t.Errorf("want the net/http caller, got %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+var (
+	_ Carrier = MapCarrier{}
+	_ Carrier = HeaderCarrier{}
+	_ Carrier = &ByteHeaders{}
+	_ Carrier = &SliceCarrier[ByteHeader]{}
+)
+
+// Carrier is the carrier of trace context.
+// Keys are case-insensitive on Get.
+type Carrier interface {
+	// Get returns the value of the key.
+	// It returns an empty string if not found.
+	Get(key string) string
+	// Set sets the value of the key.
+	Set(key, value string)
+	// Keys returns all the keys in the carrier.
+	Keys() []string
+}
+
+// Propagator injects and extracts trace context to and from carriers.
+type Propagator interface {
+	// Inject injects the trace context of the current span in the ctx
+	// into the carrier.
+	Inject(ctx context.Context, carrier Carrier)
+	// Extract extracts the trace context from the carrier and
+	// returns a new context which has the extracted trace context
+	// as the remote parent. The ctx is returned as-is if not found.
+	Extract(ctx context.Context, carrier Carrier) context.Context
+}
+
+// MessageTracer is the tracer that traces asynchronous messaging
+// such as Kafka or NATS.
+type MessageTracer interface {
+	Propagator
+	// StartProducer starts a producer span as a child of the current span
+	// in the ctx and injects its trace context into the carrier
+	// which is the headers of the message to be sent.
+	// Callers must update their context with the returned one.
+	StartProducer(ctx context.Context, name string, carrier Carrier, tags map[string]string) (spanCtx context.Context, finish func())
+	// StartConsumer starts a consumer span for the message
+	// which has the carrier as its headers.
+	// The span becomes a child of the producer span if the ctx has no span.
+	// Otherwise, the span becomes a child of the current span in the ctx
+	// and refers to the producer span.
+	// Callers must update their context with the returned one.
+	StartConsumer(ctx context.Context, name string, carrier Carrier, tags map[string]string) (spanCtx context.Context, finish func())
+}
+
+// MapCarrier is the [Carrier] of map[string]string.
+type MapCarrier map[string]string
+
+func (c MapCarrier) Get(key string) string {
+	if v, ok := c[key]; ok {
+		return v
+	}
+	for k, v := range c {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c MapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// HeaderCarrier is the [Carrier] of [http.Header].
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ByteHeader is a message header which has []byte value
+// such as the record header of Kafka.
+type ByteHeader struct {
+	Key   string
+	Value []byte
+}
+
+// ByteHeaders is the [Carrier] of []ByteHeader.
+type ByteHeaders []ByteHeader
+
+func (c *ByteHeaders) Get(key string) string {
+	for _, h := range *c {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c *ByteHeaders) Set(key, value string) {
+	for i, h := range *c {
+		if strings.EqualFold(h.Key, key) {
+			(*c)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c = append(*c, ByteHeader{Key: key, Value: []byte(value)})
+}
+
+func (c *ByteHeaders) Keys() []string {
+	keys := make([]string, 0, len(*c))
+	for _, h := range *c {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// SliceCarrier is the [Carrier] of a slice of any header type
+// which has a string key and a []byte value.
+// It can be used with header types of message queue clients
+// without converting them.
+//
+// For example, with a Kafka client:
+//
+//	carrier := &tracing.SliceCarrier[kafka.Header]{
+//		Headers: &msg.Headers,
+//		Key:     func(h kafka.Header) string { return h.Key },
+//		Value:   func(h kafka.Header) []byte { return h.Value },
+//		New:     func(k string, v []byte) kafka.Header { return kafka.Header{Key: k, Value: v} },
+//	}
+type SliceCarrier[H any] struct {
+	// Headers is the pointer to the header slice.
+	Headers *[]H
+	// Key returns the key of the header.
+	Key func(H) string
+	// Value returns the value of the header.
+	Value func(H) []byte
+	// New returns a new header.
+	New func(key string, value []byte) H
+}
+
+func (c *SliceCarrier[H]) Get(key string) string {
+	for _, h := range *c.Headers {
+		if strings.EqualFold(c.Key(h), key) {
+			return string(c.Value(h))
+		}
+	}
+	return ""
+}
+
+func (c *SliceCarrier[H]) Set(key, value string) {
+	for i, h := range *c.Headers {
+		if strings.EqualFold(c.Key(h), key) {
+			(*c.Headers)[i] = c.New(key, []byte(value))
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, c.New(key, []byte(value)))
+}
+
+func (c *SliceCarrier[H]) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, c.Key(h))
+	}
+	return keys
+}
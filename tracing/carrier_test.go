@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// msgHeader is the header type of a message queue client.
+type msgHeader struct {
+	key   string
+	value []byte
+}
+
+func TestCarrier(t *testing.T) {
+	var headers []msgHeader
+	testCases := map[string]struct {
+		carrier Carrier
+		keys    []string
+	}{
+		"map":    {MapCarrier{}, []string{"Traceparent", "tracestate"}},
+		"header": {HeaderCarrier(http.Header{}), []string{"Traceparent", "Tracestate"}},
+		"bytes":  {&ByteHeaders{}, []string{"Traceparent", "tracestate"}},
+		"slice": {&SliceCarrier[msgHeader]{
+			Headers: &headers,
+			Key:     func(h msgHeader) string { return h.key },
+			Value:   func(h msgHeader) []byte { return h.value },
+			New:     func(k string, v []byte) msgHeader { return msgHeader{key: k, value: v} },
+		}, []string{"Traceparent", "tracestate"}},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := tc.carrier
+			c.Set("Traceparent", "00-old")
+			c.Set("tracestate", "foo=bar")
+			c.Set("Traceparent", "00-new")
+			if v := c.Get("traceparent"); v != "00-new" {
+				t.Errorf("keys must be case-insensitive on Get, got %q", v)
+			}
+			if v := c.Get("Tracestate"); v != "foo=bar" {
+				t.Errorf("want foo=bar, got %q", v)
+			}
+			if v := c.Get("baggage"); v != "" {
+				t.Errorf("want empty for missing keys, got %q", v)
+			}
+			keys := c.Keys()
+			slices.Sort(keys)
+			if !slices.Equal(keys, tc.keys) {
+				t.Errorf("want keys %v, got %v", tc.keys, keys)
+			}
+		})
+	}
+	if len(headers) != 2 || string(headers[0].value) != "00-new" {
+		t.Errorf("slice carrier must update the headers in place: %v", headers)
+	}
+}
@@ -0,0 +1,34 @@
+package jaeger
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/opentracing/opentracing-go"
+)
+
+// extractBaggage merges the jaeger baggage items of the sc
+// and the W3C baggage in the carrier into the baggage in the ctx.
+// The sc and the carrier can be nil.
+func extractBaggage(ctx context.Context, sc opentracing.SpanContext, carrier tracing.Carrier) context.Context {
+	if sc != nil {
+		sc.ForeachBaggageItem(func(k, v string) bool {
+			if tracing.GetBaggage(ctx, k) == "" {
+				ctx, _ = tracing.SetBaggage(ctx, k, v)
+			}
+			return true
+		})
+	}
+	if carrier != nil {
+		ctx = tracing.ExtractBaggage(ctx, carrier)
+	}
+	return ctx
+}
+
+// tagBaggage copies the baggage entries of the
+// configured keys in the ctx to the span tags.
+func (t *Tracer) tagBaggage(ctx context.Context, span opentracing.Span) {
+	for k, v := range tracing.SelectBaggage(ctx, t.baggageKeys) {
+		span.SetTag(k, v)
+	}
+}
@@ -4,7 +4,14 @@ import (
 	"cmp"
 	"net/http"
 
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
 	"github.com/opentracing/opentracing-go"
+	jaegerclient "github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
 
@@ -17,6 +24,43 @@ type Config struct {
 	// AddCaller, if true, add caller info
 	// to the root span tag.
 	AddCaller bool
+	// Caller configures the caller capture of the AddCaller.
+	// If nil, frames of the [tracing.DefaultCallerSkipPrefixes]
+	// are skipped and the stack is not recorded.
+	Caller *tracing.CallerConfig
+	// BaggageKeys is the list of baggage keys copied to
+	// the tags of spans when the spans are started.
+	// Baggage is injected in the W3C baggage format and
+	// is extracted from both the W3C baggage and jaeger baggage items.
+	BaggageKeys []string
+	// ResponseHeaders writes the trace id of the root server spans
+	// in the response headers. If nil, nothing is written.
+	ResponseHeaders *tracing.ResponseHeaderConfig
+	// SpanMetrics records the request, error and duration metrics
+	// of the finished spans with the [SpanMetricsReporter].
+	// The reporter is created from the JaegerConfig.Reporter and wrapped.
+	// If nil, metrics are not recorded.
+	SpanMetrics *spanmetrics.Metrics
+	// ServiceGraph records the service graph built from the
+	// pairs of client and server spans with the [SpanMetricsReporter].
+	// The reporter is created in the same way as the SpanMetrics.
+	// If nil, the graph is not recorded.
+	ServiceGraph *servicegraph.Graph
+	// Redactor redacts the operation names, tags and logs of
+	// spans started by the [Tracer] when they are set.
+	// Spans started by the global opentracing tracer are not redacted.
+	// If nil, spans are not redacted.
+	Redactor *redact.Redactor
+	// SpanNameFunc returns the operation names of http spans.
+	// If nil, [tracing.LegacySpanName] is used for the compatibility
+	// with existing dashboards. Use [tracing.RouteSpanName] or
+	// [tracing.TemplateSpanName] for low cardinality names.
+	SpanNameFunc tracing.SpanNameFunc
+	// RecordPanics, if true, records panics in the server middleware
+	// as "error" logs of the spans with the status code 500
+	// and then panics again with the [recovery.Panic].
+	// Use the [recovery.Middleware] to reply 500 or to re-panic.
+	RecordPanics bool
 	// ServerSpanHook intercept spans in the server-side midleware before finishing it.
 	// If not set, default hook function is used and adds default span tags.
 	// Users can use this function to add custom tags.
@@ -25,6 +69,14 @@ type Config struct {
 	// If not set, default hook function is used and adds default span tags.
 	// Users can use this function to add custom tags.
 	ClientSpanHook func(span opentracing.Span, w *http.Response, r *http.Request)
+	// ErrorHandler handles errors logged by the jaeger reporter.
+	// Errors are recorded in the [Tracer.Monitor] regardless of ErrorHandler.
+	ErrorHandler health.ErrorHandler
+	// Sampling is the rule based sampling configuration.
+	// If set, sampling decisions of server spans are made by the rules
+	// using the "sampling.priority" tag.
+	// The JaegerConfig.Sampler is still used for other spans.
+	Sampling *sampling.Config
 }
 
 // New creates a new tracer from the [Config].
@@ -32,16 +84,72 @@ func New(c *Config) (*Tracer, error) {
 	jc := c.JaegerConfig
 	jc.Sampler = cmp.Or(jc.Sampler, &config.SamplerConfig{Type: "const", Param: 1})
 	jc.ServiceName = cmp.Or(jc.ServiceName, "aileron")
-	tracer, closer, err := jc.NewTracer()
+	monitor := health.NewMonitor(&health.Config{
+		Exporter:     "jaeger",
+		Signal:       "spans",
+		ErrorHandler: c.ErrorHandler,
+	})
+	opts := []config.Option{
+		config.Metrics(&metricsFactory{monitor: monitor}),
+		config.Logger(&errorLogger{monitor: monitor}),
+	}
+	var sampler *sampling.Sampler
+	if c.Sampling != nil {
+		s, err := sampling.New(c.Sampling)
+		if err != nil {
+			return nil, err
+		}
+		sampler = s
+		// Sampled spans by rules should not be marked as debug.
+		opts = append(opts, config.NoDebugFlagOnForcedSampling(true))
+	}
+	var recorders []spanmetrics.Recorder
+	if c.SpanMetrics != nil {
+		recorders = append(recorders, c.SpanMetrics)
+	}
+	if c.ServiceGraph != nil {
+		recorders = append(recorders, c.ServiceGraph)
+	}
+	var reporter *SpanMetricsReporter
+	if len(recorders) > 0 && !jc.Disabled {
+		rc := cmp.Or(jc.Reporter, &config.ReporterConfig{})
+		rep, err := rc.NewReporter(jc.ServiceName, jaegerclient.NewMetrics(&metricsFactory{monitor: monitor}, nil), &errorLogger{monitor: monitor})
+		if err != nil {
+			return nil, err
+		}
+		reporter = NewSpanMetricsReporter(rep, jc.ServiceName, recorders...)
+		opts = append(opts, config.Reporter(reporter))
+	}
+	tracer, closer, err := jc.NewTracer(opts...)
 	if err != nil {
+		// The reporter is not closed by the tracer if not created.
+		if reporter != nil {
+			reporter.Close()
+		}
 		return nil, err
 	}
+	if c.Redactor != nil {
+		tracer = &redactingTracer{Tracer: tracer, redactor: c.Redactor}
+	}
 	t := &Tracer{
-		tracer:         tracer,
-		closer:         closer,
-		addCaller:      c.AddCaller,
-		serverSpanHook: c.ServerSpanHook,
-		clientSpanHook: c.ClientSpanHook,
+		tracer:          tracer,
+		closer:          closer,
+		monitor:         monitor,
+		sampler:         sampler,
+		spanMetrics:     c.SpanMetrics,
+		serviceGraph:    c.ServiceGraph,
+		spanName:        c.SpanNameFunc,
+		recordPanics:    c.RecordPanics,
+		responseHeaders: c.ResponseHeaders,
+		baggageKeys:     c.BaggageKeys,
+		serverSpanHook:  c.ServerSpanHook,
+		clientSpanHook:  c.ClientSpanHook,
+	}
+	if c.AddCaller {
+		t.caller = tracing.NewCallerCapture(c.Caller)
+	}
+	if t.spanName == nil {
+		t.spanName = tracing.LegacySpanName
 	}
 	if t.serverSpanHook == nil {
 		t.serverSpanHook = serverSpanHook
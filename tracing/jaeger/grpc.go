@@ -0,0 +1,111 @@
+package jaeger
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpcx"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that traces unary RPCs.
+// Trace context is extracted from the incoming metadata.
+func (t *Tracer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		span, ctx := t.grpcServerSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		finishGRPCSpan(span, grpcx.NewUnaryInfo(info.FullMethod, err, true))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that traces streaming RPCs.
+// Trace context is extracted from the incoming metadata.
+func (t *Tracer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span, ctx := t.grpcServerSpan(ss.Context(), info.FullMethod)
+		ws := &grpcx.ServerStream{ServerStream: ss, Ctx: ctx}
+		err := handler(srv, ws)
+		finishGRPCSpan(span, grpcx.NewInfo(info.FullMethod, err, ws.Sent(), ws.Received()))
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that traces unary RPCs.
+// Trace context is injected into the outgoing metadata.
+func (t *Tracer) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := t.grpcClientSpan(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishGRPCSpan(span, grpcx.NewUnaryInfo(method, err, false))
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor that traces streaming RPCs.
+// Trace context is injected into the outgoing metadata.
+// Spans are finished when the stream ends.
+func (t *Tracer) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := t.grpcClientSpan(ctx, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishGRPCSpan(span, grpcx.NewInfo(method, err, 0, 0))
+			return nil, err
+		}
+		return grpcx.NewClientStream(cs, desc, func(err error, sent, received int64) {
+			finishGRPCSpan(span, grpcx.NewInfo(method, err, sent, received))
+		}), nil
+	}
+}
+
+func (t *Tracer) grpcServerSpan(ctx context.Context, fullMethod string) (opentracing.Span, context.Context) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCServer}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	} else {
+		carrier := grpcx.MDCarrier(grpcx.IncomingMD(ctx))
+		sc, err := t.tracer.Extract(opentracing.TextMap, carrier)
+		ctx = extractBaggage(ctx, sc, carrier)
+		if err == nil {
+			opts = append(opts, ext.RPCServerOption(sc))
+		} else if !errors.Is(err, opentracing.ErrSpanContextNotFound) {
+			opts = append(opts, opentracing.ChildOf(sc))
+		}
+	}
+	span := t.tracer.StartSpan("server+"+fullMethod, opts...)
+	t.tagBaggage(ctx, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+func (t *Tracer) grpcClientSpan(ctx context.Context, fullMethod string) (opentracing.Span, context.Context) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCClient}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	span := t.tracer.StartSpan("client+"+fullMethod, opts...)
+	t.tagBaggage(ctx, span)
+	md := grpcx.OutgoingMD(ctx)
+	_ = t.tracer.Inject(span.Context(), opentracing.TextMap, grpcx.MDCarrier(md))
+	tracing.InjectBaggage(ctx, grpcx.MDCarrier(md))
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return span, metadata.NewOutgoingContext(ctx, md)
+}
+
+func finishGRPCSpan(span opentracing.Span, info *grpcx.Info) {
+	span.SetTag("rpc.system", "grpc")
+	span.SetTag("rpc.service", info.Service)
+	span.SetTag("rpc.method", info.Method)
+	span.SetTag("rpc.grpc.status_code", info.Code)
+	span.SetTag("rpc.messages_sent", info.Sent)
+	span.SetTag("rpc.messages_received", info.Received)
+	if info.Err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", info.Err.Error())
+	}
+	span.Finish()
+}
@@ -0,0 +1,78 @@
+package jaeger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpctest"
+	jaegerclient "github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	for _, tc := range grpctest.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tr, rep := newInMemoryTracer(t, &Config{})
+			srv, cli := grpctest.Start(t,
+				[]grpc.ServerOption{
+					grpc.UnaryInterceptor(tr.UnaryServerInterceptor()),
+					grpc.StreamInterceptor(tr.StreamServerInterceptor()),
+				},
+				[]grpc.DialOption{
+					grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor()),
+					grpc.WithStreamInterceptor(tr.StreamClientInterceptor()),
+				},
+			)
+			cli.Call(t, context.Background(), tc)
+
+			if len(srv.MD()[0].Get(jaegerclient.TraceContextHeaderName)) == 0 {
+				t.Error("trace context must be propagated in the metadata")
+			}
+			server, ok := findSpan(rep, "server+"+tc.FullMethod)
+			if !ok {
+				t.Fatal("server span not found")
+			}
+			client, ok := findSpan(rep, "client+"+tc.FullMethod)
+			if !ok {
+				t.Fatal("client span not found")
+			}
+			if server.SpanContext().ParentID() != client.SpanContext().SpanID() ||
+				server.SpanContext().TraceID() != client.SpanContext().TraceID() {
+				t.Error("server span must be the child of the client span")
+			}
+			checkGRPCSpan(t, server, tc, tc.ServerSent, tc.ServerReceived)
+			checkGRPCSpan(t, client, tc, tc.ClientSent, tc.ClientReceived)
+		})
+	}
+}
+
+func findSpan(rep *jaegerclient.InMemoryReporter, name string) (*jaegerclient.Span, bool) {
+	for _, s := range rep.GetSpans() {
+		if s := s.(*jaegerclient.Span); s.OperationName() == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func checkGRPCSpan(t *testing.T, s *jaegerclient.Span, tc grpctest.Case, sent, received int64) {
+	t.Helper()
+	want := map[string]any{
+		"rpc.system":            "grpc",
+		"rpc.service":           grpctest.Service,
+		"rpc.method":            tc.Method,
+		"rpc.grpc.status_code":  tc.Code.String(),
+		"rpc.messages_sent":     sent,
+		"rpc.messages_received": received,
+	}
+	tags := s.Tags()
+	for k, v := range want {
+		if tags[k] != v {
+			t.Errorf("%s: want %s=%v, got %v", s.OperationName(), k, v, tags[k])
+		}
+	}
+	if (tags["error"] == true) != (tc.Code != codes.OK) {
+		t.Errorf("%s: unexpected error tag %v", s.OperationName(), tags["error"])
+	}
+}
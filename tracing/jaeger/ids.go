@@ -0,0 +1,43 @@
+package jaeger
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/opentracing/opentracing-go"
+	jaegerclient "github.com/uber/jaeger-client-go"
+)
+
+// joinSpan starts a span with the ids created by another tracer.
+// The ids in the returned context are consumed.
+func (t *Tracer) joinSpan(ctx context.Context, name string, ids tracing.SpanIDs) (opentracing.Span, context.Context) {
+	tid := jaegerclient.TraceID{
+		High: binary.BigEndian.Uint64(ids.TraceID[:8]),
+		Low:  binary.BigEndian.Uint64(ids.TraceID[8:]),
+	}
+	sid := jaegerclient.SpanID(binary.BigEndian.Uint64(ids.SpanID[:]))
+	pid := jaegerclient.SpanID(binary.BigEndian.Uint64(ids.ParentID[:]))
+	sc := jaegerclient.NewSpanContext(tid, sid, pid, ids.Sampled, nil)
+	span := t.tracer.StartSpan(name, jaegerclient.SelfRef(sc))
+	ctx = tracing.ContextWithSpanIDs(ctx, tracing.SpanIDs{})
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// SpanIDs returns the ids of the current span in the ctx.
+func (t *Tracer) SpanIDs(ctx context.Context) (tracing.SpanIDs, bool) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return tracing.SpanIDs{}, false
+	}
+	sc, ok := span.Context().(jaegerclient.SpanContext)
+	if !ok || !sc.IsValid() {
+		return tracing.SpanIDs{}, false
+	}
+	ids := tracing.SpanIDs{Sampled: sc.IsSampled()}
+	binary.BigEndian.PutUint64(ids.TraceID[:8], sc.TraceID().High)
+	binary.BigEndian.PutUint64(ids.TraceID[8:], sc.TraceID().Low)
+	binary.BigEndian.PutUint64(ids.SpanID[:], uint64(sc.SpanID()))
+	binary.BigEndian.PutUint64(ids.ParentID[:], uint64(sc.ParentID()))
+	return ids, true
+}
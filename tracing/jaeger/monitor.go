@@ -0,0 +1,87 @@
+package jaeger
+
+import (
+	"errors"
+
+	"github.com/aileron-projects/aileron-observability/health"
+	jaegerclient "github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-lib/metrics"
+)
+
+var (
+	_ metrics.Factory     = &metricsFactory{}
+	_ jaegerclient.Logger = &errorLogger{}
+)
+
+// errReport is the error recorded to the monitor when
+// the reporter failed to send spans. The jaeger reporter
+// reports the failures only with the counter.
+var errReport = errors.New("jaeger: reporter failed to send spans")
+
+// metricsFactory is the jaeger metrics factory that
+// records the reporter metrics to the monitor.
+// Other metrics reported by the jaeger tracer are discarded.
+//
+// Following metrics are used.
+//
+//   - reporter_spans{result=ok}: spans successfully reported.
+//   - reporter_spans{result=err}: spans not reported due to a sender failure.
+//   - reporter_spans{result=dropped}: spans dropped due to queue overflow.
+//   - reporter_queue_length: current number of spans in the reporter queue.
+type metricsFactory struct {
+	monitor *health.Monitor
+}
+
+func (f *metricsFactory) Counter(o metrics.Options) metrics.Counter {
+	if o.Name != "reporter_spans" {
+		return metrics.NullCounter
+	}
+	switch o.Tags["result"] {
+	case "ok":
+		return counterFunc(func(n int64) { f.monitor.Exported(int(n)) })
+	case "err":
+		return counterFunc(func(n int64) { f.monitor.Failed(int(n), errReport) })
+	case "dropped":
+		return counterFunc(func(n int64) { f.monitor.Dropped(int(n)) })
+	}
+	return metrics.NullCounter
+}
+
+func (f *metricsFactory) Gauge(o metrics.Options) metrics.Gauge {
+	if o.Name != "reporter_queue_length" {
+		return metrics.NullGauge
+	}
+	return gaugeFunc(func(n int64) { f.monitor.SetQueueLength(int(n)) })
+}
+
+func (f *metricsFactory) Timer(_ metrics.TimerOptions) metrics.Timer {
+	return metrics.NullTimer
+}
+
+func (f *metricsFactory) Histogram(_ metrics.HistogramOptions) metrics.Histogram {
+	return metrics.NullHistogram
+}
+
+func (f *metricsFactory) Namespace(_ metrics.NSOptions) metrics.Factory {
+	return f
+}
+
+type counterFunc func(int64)
+
+func (f counterFunc) Inc(n int64) { f(n) }
+
+type gaugeFunc func(int64)
+
+func (f gaugeFunc) Update(n int64) { f(n) }
+
+// errorLogger is the jaeger logger that records
+// errors logged by the jaeger reporter to the monitor.
+type errorLogger struct {
+	monitor *health.Monitor
+}
+
+func (l *errorLogger) Error(msg string) {
+	l.monitor.Error(errors.New(msg))
+}
+
+func (l *errorLogger) Infof(_ string, _ ...any) {}
@@ -0,0 +1,34 @@
+package jaeger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/uber/jaeger-lib/metrics"
+)
+
+func TestMetricsFactory(t *testing.T) {
+	m := health.NewMonitor(&health.Config{Exporter: "jaeger", Signal: "spans"})
+	f := &metricsFactory{monitor: m}
+	counter := func(result string) metrics.Counter {
+		return f.Counter(metrics.Options{Name: "reporter_spans", Tags: map[string]string{"result": result}})
+	}
+
+	f.Gauge(metrics.Options{Name: "reporter_queue_length"}).Update(10)
+	counter("err").Inc(3)
+	if s := m.Health(); s.Healthy() || !errors.Is(s.LastError, errReport) {
+		t.Errorf("failed spans must make the monitor unhealthy: %v", s.LastError)
+	}
+	counter("dropped").Inc(2)
+	counter("ok").Inc(4)
+	if s := m.Health(); !s.Healthy() {
+		t.Errorf("exported spans must make the monitor healthy: %v", s.LastError)
+	}
+	if n := m.QueueLength(); n != 1 {
+		t.Errorf("want queue length 1, got %d", n)
+	}
+	if c := f.Counter(metrics.Options{Name: "other"}); c != metrics.NullCounter {
+		t.Error("other counters must be discarded")
+	}
+}
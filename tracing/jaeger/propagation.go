@@ -0,0 +1,103 @@
+package jaeger
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+var (
+	_ tracing.Propagator    = &Tracer{}
+	_ tracing.MessageTracer = &Tracer{}
+)
+
+// textMapCarrier adapts the [tracing.Carrier] to
+// the TextMapReader and TextMapWriter of opentracing.
+type textMapCarrier struct {
+	tracing.Carrier
+}
+
+func (c textMapCarrier) ForeachKey(handler func(key, val string) error) error {
+	for _, k := range c.Keys() {
+		if err := handler(k, c.Get(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type remoteKey struct{}
+
+// remoteFromContext returns the remote span context saved by [Tracer.Extract].
+func remoteFromContext(ctx context.Context) opentracing.SpanContext {
+	sc, _ := ctx.Value(remoteKey{}).(opentracing.SpanContext)
+	return sc
+}
+
+// Inject injects the trace context of the current span in the ctx
+// into the carrier in the jaeger format.
+func (t *Tracer) Inject(ctx context.Context, carrier tracing.Carrier) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		_ = t.tracer.Inject(span.Context(), opentracing.TextMap, textMapCarrier{carrier})
+	}
+	tracing.InjectBaggage(ctx, carrier)
+}
+
+// Extract extracts the trace context in the jaeger format from the carrier.
+// Spans started by the tracer with the returned context
+// become children of the extracted span context.
+func (t *Tracer) Extract(ctx context.Context, carrier tracing.Carrier) context.Context {
+	sc, err := t.tracer.Extract(opentracing.TextMap, textMapCarrier{carrier})
+	ctx = extractBaggage(ctx, sc, carrier)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// StartProducer starts a producer span and injects
+// its trace context into the carrier.
+func (t *Tracer) StartProducer(ctx context.Context, name string, carrier tracing.Carrier, tags map[string]string) (spanCtx context.Context, finish func()) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindProducer}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	} else if sc := remoteFromContext(ctx); sc != nil {
+		opts = append(opts, opentracing.ChildOf(sc))
+	}
+	span := t.tracer.StartSpan(name, opts...)
+	t.tagBaggage(ctx, span)
+	for k, v := range tags {
+		span.SetTag(k, v)
+	}
+	_ = t.tracer.Inject(span.Context(), opentracing.TextMap, textMapCarrier{carrier})
+	tracing.InjectBaggage(ctx, carrier)
+	return opentracing.ContextWithSpan(ctx, span), span.Finish
+}
+
+// StartConsumer starts a consumer span for the message
+// which has the carrier as its headers.
+// The producer span is referred with the FollowsFrom reference
+// when the span has another parent.
+func (t *Tracer) StartConsumer(ctx context.Context, name string, carrier tracing.Carrier, tags map[string]string) (spanCtx context.Context, finish func()) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindConsumer}
+	remote, err := t.tracer.Extract(opentracing.TextMap, textMapCarrier{carrier})
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+		if err == nil {
+			opts = append(opts, opentracing.FollowsFrom(remote))
+		}
+	} else {
+		ctx = extractBaggage(ctx, remote, carrier)
+		if err == nil {
+			opts = append(opts, opentracing.ChildOf(remote))
+		}
+	}
+	span := t.tracer.StartSpan(name, opts...)
+	t.tagBaggage(ctx, span)
+	for k, v := range tags {
+		span.SetTag(k, v)
+	}
+	return opentracing.ContextWithSpan(ctx, span), span.Finish
+}
@@ -0,0 +1,103 @@
+package jaeger
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/opentracing/opentracing-go"
+	jaegerclient "github.com/uber/jaeger-client-go"
+)
+
+func TestTracer_InjectExtract(t *testing.T) {
+	testCases := map[string]tracing.Carrier{
+		"map":   tracing.MapCarrier{},
+		"bytes": &tracing.ByteHeaders{},
+	}
+	tr, _ := newInMemoryTracer(t, &Config{})
+	span := tr.tracer.StartSpan("parent")
+	defer span.Finish()
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	for name, carrier := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tr.Inject(ctx, carrier)
+			sc, ok := remoteFromContext(tr.Extract(context.Background(), carrier)).(jaegerclient.SpanContext)
+			want := span.Context().(jaegerclient.SpanContext)
+			if !ok || sc.TraceID() != want.TraceID() || sc.SpanID() != want.SpanID() {
+				t.Errorf("want %v, got %v", want, sc)
+			}
+		})
+	}
+}
+
+func TestTracer_StartConsumer(t *testing.T) {
+	testCases := map[string]struct {
+		produce bool
+		parent  bool
+	}{
+		"child of producer":  {produce: true},
+		"follows producer":   {produce: true, parent: true},
+		"no producer":        {},
+		"parent no producer": {parent: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tr, rep := newInMemoryTracer(t, &Config{})
+			carrier := &tracing.ByteHeaders{}
+			var producer jaegerclient.SpanContext
+			if tc.produce {
+				ctx, finish := tr.StartProducer(context.Background(), "send", carrier, map[string]string{"topic": "orders"})
+				producer = opentracing.SpanFromContext(ctx).Context().(jaegerclient.SpanContext)
+				finish()
+			}
+			ctx := context.Background()
+			var parent jaegerclient.SpanContext
+			if tc.parent {
+				span := tr.tracer.StartSpan("poll")
+				defer span.Finish()
+				ctx = opentracing.ContextWithSpan(ctx, span)
+				parent = span.Context().(jaegerclient.SpanContext)
+			}
+			_, finish := tr.StartConsumer(ctx, "receive", carrier, map[string]string{"topic": "orders"})
+			finish()
+
+			spans := rep.GetSpans()
+			s := spans[len(spans)-1].(*jaegerclient.Span)
+			if tags := s.Tags(); fmt.Sprint(tags["span.kind"]) != "consumer" || tags["topic"] != "orders" {
+				t.Errorf("unexpected consumer tags %v", tags)
+			}
+			if tc.produce && (fmt.Sprint(spans[0].(*jaegerclient.Span).Tags()["span.kind"]) != "producer" || carrier.Get("uber-trace-id") == "") {
+				t.Errorf("producer must inject its trace context: %v", carrier)
+			}
+			sc := s.SpanContext()
+			switch {
+			case tc.parent:
+				if sc.ParentID() != parent.SpanID() || sc.TraceID() != parent.TraceID() {
+					t.Errorf("want the local parent %v, got %v", parent, sc)
+				}
+			case tc.produce:
+				if sc.ParentID() != producer.SpanID() || sc.TraceID() != producer.TraceID() {
+					t.Errorf("want the producer parent %v, got %v", producer, sc)
+				}
+			default:
+				if sc.ParentID() != 0 {
+					t.Errorf("want a root span, got %v", sc)
+				}
+			}
+			var follows []opentracing.SpanReference
+			for _, ref := range s.References() {
+				if ref.Type == opentracing.FollowsFromRef {
+					follows = append(follows, ref)
+				}
+			}
+			if tc.produce && tc.parent {
+				if len(follows) != 1 || follows[0].ReferencedContext.(jaegerclient.SpanContext).SpanID() != producer.SpanID() {
+					t.Errorf("want FollowsFrom the producer, got %v", follows)
+				}
+			} else if len(follows) != 0 {
+				t.Errorf("want no FollowsFrom, got %v", follows)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package jaeger
+
+import (
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+var (
+	_ opentracing.Tracer = &redactingTracer{}
+	_ opentracing.Span   = &redactingSpan{}
+)
+
+// redactingTracer starts spans that redact the operation names,
+// tags and logs with the redactor when they are set.
+// Jaeger spans cannot be modified after they are finished
+// so values are redacted before they are recorded.
+type redactingTracer struct {
+	opentracing.Tracer
+	redactor *redact.Redactor
+}
+
+func (t *redactingTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	sso := opentracing.StartSpanOptions{}
+	for _, o := range opts {
+		o.Apply(&sso)
+	}
+	for k, v := range sso.Tags {
+		v, ok := redactTag(t.redactor, k, v)
+		if !ok {
+			delete(sso.Tags, k)
+			continue
+		}
+		sso.Tags[k] = v
+	}
+	span := t.Tracer.StartSpan(t.redactor.Path(operationName), startSpanOptions(sso))
+	return &redactingSpan{Span: span, tracer: t}
+}
+
+// startSpanOptions applies the options as-is.
+type startSpanOptions opentracing.StartSpanOptions
+
+func (o startSpanOptions) Apply(opts *opentracing.StartSpanOptions) {
+	*opts = opentracing.StartSpanOptions(o)
+}
+
+// redactingSpan redacts the operation name, tags and logs.
+// Baggage items are not redacted.
+type redactingSpan struct {
+	opentracing.Span
+	tracer *redactingTracer
+}
+
+func (s *redactingSpan) FinishWithOptions(opts opentracing.FinishOptions) {
+	for i := range opts.LogRecords {
+		opts.LogRecords[i].Fields = redactFields(s.tracer.redactor, opts.LogRecords[i].Fields)
+	}
+	s.Span.FinishWithOptions(opts)
+}
+
+func (s *redactingSpan) SetOperationName(operationName string) opentracing.Span {
+	s.Span.SetOperationName(s.tracer.redactor.Path(operationName))
+	return s
+}
+
+func (s *redactingSpan) SetTag(key string, value any) opentracing.Span {
+	if v, ok := redactTag(s.tracer.redactor, key, value); ok {
+		s.Span.SetTag(key, v)
+	}
+	return s
+}
+
+func (s *redactingSpan) LogFields(fields ...log.Field) {
+	s.Span.LogFields(redactFields(s.tracer.redactor, fields)...)
+}
+
+func (s *redactingSpan) LogKV(alternatingKeyValues ...any) {
+	fields, err := log.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		s.Span.LogFields(log.Error(err), log.String("function", "LogKV"))
+		return
+	}
+	s.LogFields(fields...)
+}
+
+func (s *redactingSpan) SetBaggageItem(restrictedKey, value string) opentracing.Span {
+	s.Span.SetBaggageItem(restrictedKey, value)
+	return s
+}
+
+func (s *redactingSpan) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *redactingSpan) LogEvent(event string) {
+	s.LogFields(log.String("event", event))
+}
+
+func (s *redactingSpan) LogEventWithPayload(event string, payload any) {
+	s.LogFields(log.String("event", event), log.Object("payload", payload))
+}
+
+func (s *redactingSpan) Log(data opentracing.LogData) {
+	s.LogFields(data.ToLogRecord().Fields...)
+}
+
+// redactTag returns the redacted tag value.
+// Values other than strings are returned as-is.
+func redactTag(r *redact.Redactor, key string, value any) (any, bool) {
+	v, ok := value.(string)
+	if !ok {
+		return value, true
+	}
+	return r.Attribute(key, v)
+}
+
+// redactFields returns the redacted copy of the fields.
+// String and error values are redacted as strings.
+func redactFields(r *redact.Redactor, fields []log.Field) []log.Field {
+	out := make([]log.Field, 0, len(fields))
+	for _, f := range fields {
+		switch v := f.Value().(type) {
+		case string:
+			v, ok := r.Attribute(f.Key(), v)
+			if !ok {
+				continue
+			}
+			f = log.String(f.Key(), v)
+		case error:
+			msg, ok := r.Attribute(f.Key(), v.Error())
+			if !ok {
+				continue
+			}
+			f = log.String(f.Key(), msg)
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package jaeger
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
+	"github.com/opentracing/opentracing-go/ext"
+	jaegerclient "github.com/uber/jaeger-client-go"
+)
+
+var (
+	_ jaegerclient.Reporter = &SpanMetricsReporter{}
+)
+
+// SpanMetricsReporter records the spans reported to the reporter to the
+// recorders such as the [spanmetrics.Metrics] and the [servicegraph.Graph]
+// and then reports them to the next reporter.
+// Spans having the "error" tag of true are recorded as errors.
+// Spans having the "http.status_code" tag of an error status
+// are also recorded as errors. See [spanmetrics.HTTPStatus].
+// Only the sampled spans are reported to reporters
+// so spans not sampled are not counted.
+// Use [NewSpanMetricsReporter] to create a new instance.
+type SpanMetricsReporter struct {
+	next      jaegerclient.Reporter
+	service   string
+	recorders []spanmetrics.Recorder
+}
+
+// NewSpanMetricsReporter returns a new reporter that records
+// the spans of the service to the recorders and reports them to the next.
+func NewSpanMetricsReporter(next jaegerclient.Reporter, service string, rs ...spanmetrics.Recorder) *SpanMetricsReporter {
+	return &SpanMetricsReporter{next: next, service: service, recorders: rs}
+}
+
+func (r *SpanMetricsReporter) Report(s *jaegerclient.Span) {
+	tags := s.Tags()
+	kind := spanmetrics.KindInternal
+	if v, ok := tags[string(ext.SpanKind)]; ok {
+		kind = spanKinds[spanKindString(v)]
+	}
+	status := spanmetrics.StatusUnset
+	if v, ok := tags[string(ext.Error)].(bool); ok && v {
+		status = spanmetrics.StatusError
+	} else if v, ok := tags[string(ext.HTTPStatusCode)].(int); ok {
+		status = spanmetrics.HTTPStatus(kind, v)
+	}
+	peer := ""
+	for _, k := range spanmetrics.PeerKeys {
+		if v, ok := tags[k].(string); ok && v != "" {
+			peer = v
+			break
+		}
+	}
+	sc := s.SpanContext()
+	span := &spanmetrics.Span{
+		IDs:      tracing.SpanIDs{Sampled: sc.IsSampled()},
+		Service:  r.service,
+		Name:     s.OperationName(),
+		Kind:     kind,
+		Status:   status,
+		Duration: s.Duration(),
+		Peer:     peer,
+	}
+	binary.BigEndian.PutUint64(span.IDs.TraceID[:8], sc.TraceID().High)
+	binary.BigEndian.PutUint64(span.IDs.TraceID[8:], sc.TraceID().Low)
+	binary.BigEndian.PutUint64(span.IDs.SpanID[:], uint64(sc.SpanID()))
+	binary.BigEndian.PutUint64(span.IDs.ParentID[:], uint64(sc.ParentID()))
+	for _, rec := range r.recorders {
+		rec.Record(context.Background(), span)
+	}
+	r.next.Report(s)
+}
+
+func (r *SpanMetricsReporter) Close() {
+	r.next.Close()
+}
+
+// spanKindString returns the span kind tag value as a string.
+// Tag values may be either a string or an [ext.SpanKindEnum].
+func spanKindString(v any) string {
+	switch k := v.(type) {
+	case string:
+		return k
+	case ext.SpanKindEnum:
+		return string(k)
+	}
+	return ""
+}
+
+var spanKinds = map[string]string{
+	string(ext.SpanKindRPCServerEnum): spanmetrics.KindServer,
+	string(ext.SpanKindRPCClientEnum): spanmetrics.KindClient,
+	string(ext.SpanKindProducerEnum):  spanmetrics.KindProducer,
+	string(ext.SpanKindConsumerEnum):  spanmetrics.KindConsumer,
+}
@@ -4,20 +4,29 @@ import (
 	"context"
 	"errors"
 	"io"
+	"math/rand/v2"
 	"net/http"
-	"path"
-	"runtime"
 
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/recovery"
 	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
 	"github.com/aileron-projects/go/znet/zhttp"
 	"github.com/aileron-projects/go/zx/zuid"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	jaegerclient "github.com/uber/jaeger-client-go"
 )
 
 var (
-	_ zhttp.ServerMiddleware = &Tracer{}
-	_ zhttp.ClientMiddleware = &Tracer{}
-	_ tracing.Tracer         = &Tracer{}
+	_ zhttp.ServerMiddleware  = &Tracer{}
+	_ zhttp.ClientMiddleware  = &Tracer{}
+	_ tracing.Tracer          = &Tracer{}
+	_ tracing.TraceMiddleware = &Tracer{}
+	_ tracing.IDTracer        = &Tracer{}
 )
 
 // Middleware is a
@@ -29,10 +38,34 @@ type Tracer struct {
 	// the application to flush all tracing data
 	// in the internal buffer.
 	closer io.Closer
+	// monitor monitors the jaeger reporter.
+	monitor *health.Monitor
+	// sampler is the rule based sampler
+	// created from Config.Sampling.
+	sampler *sampling.Sampler
+	// spanMetrics records the metrics of finished spans
+	// given by Config.SpanMetrics. It may be nil.
+	spanMetrics *spanmetrics.Metrics
+	// serviceGraph records the service graph
+	// given by Config.ServiceGraph. It may be nil.
+	serviceGraph *servicegraph.Graph
 
-	// addCaller, if true, add caller info
-	// to the root span tag.
-	addCaller bool
+	// caller captures the callers of root spans.
+	// It is nil if the caller is not added.
+	caller *tracing.CallerCapture
+	// baggageKeys is the baggage keys
+	// copied to the span tags.
+	baggageKeys []string
+	// spanName returns the operation
+	// names of http spans.
+	spanName tracing.SpanNameFunc
+	// recordPanics, if true, records panics
+	// in the server middleware.
+	recordPanics bool
+
+	// responseHeaders writes the trace id
+	// in the response headers if not nil.
+	responseHeaders *tracing.ResponseHeaderConfig
 
 	serverSpanHook func(span opentracing.Span, w http.ResponseWriter, r *http.Request)
 	clientSpanHook func(span opentracing.Span, w *http.Response, r *http.Request)
@@ -46,16 +79,26 @@ func (t *Tracer) ServerMiddleware(next http.Handler) http.Handler {
 		}
 		r = r.WithContext(context.WithValue(r.Context(), tracing.ServerCtxKey, c))
 
-		span, ctx := t.spanContext(r, "server+"+r.URL.Path)
+		var sampler *sampling.Sampler
+		if c == 1 {
+			sampler = t.sampler
+		}
+		name := t.spanName("server", r)
+		span, ctx := t.spanContext(r, name, sampler)
+		ext.SpanKindRPCServer.Set(span)
 		defer span.Finish()
 		r = r.WithContext(ctx)
+		if t.recordPanics {
+			defer func() {
+				if v := recover(); v != nil {
+					recovery.Handle(v, func(p *recovery.Panic) { recordPanic(span, p) })
+				}
+			}()
+		}
 
-		if t.addCaller {
-			if ptr, file, _, ok := runtime.Caller(2); ok {
-				f, _ := runtime.CallersFrames([]uintptr{ptr}).Next()
-				span.SetTag("caller.file", path.Base(path.Dir(file))+"/"+path.Base(file))
-				span.SetTag("caller.func", f.Function)
-				span.SetTag("caller.line", f.Line)
+		if t.caller != nil && c == 1 { // Only for root span.
+			if cl, ok := t.caller.Capture(); ok {
+				setCaller(span, cl)
 			}
 		}
 
@@ -63,8 +106,17 @@ func (t *Tracer) ServerMiddleware(next http.Handler) http.Handler {
 			ww := zhttp.WrapResponseWriter(w)
 			w = ww
 			defer t.serverSpanHook(span, ww, r)
+			if t.responseHeaders != nil {
+				ids, _ := t.SpanIDs(ctx)
+				var done func()
+				r, done = t.responseHeaders.Hook(ww, r, ids)
+				defer done()
+			}
 		}
 		next.ServeHTTP(w, r)
+		if n := t.spanName("server", r); n != name {
+			span.SetOperationName(n) // Route may be matched by the handler.
+		}
 	})
 }
 
@@ -76,16 +128,17 @@ func (t *Tracer) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 		}
 		r = r.WithContext(context.WithValue(r.Context(), tracing.ClientCtxKey, c))
 
-		span, ctx := t.spanContext(r, "client+"+r.URL.Path)
+		span, ctx := t.spanContext(r, t.spanName("client", r), nil)
+		ext.SpanKindRPCClient.Set(span)
 		defer span.Finish()
 		r = r.WithContext(ctx)
+		r.Header = cloneHeader(r.Header)
+		_ = t.tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+		tracing.InjectBaggage(ctx, tracing.HeaderCarrier(r.Header))
 
-		if t.addCaller {
-			if ptr, file, _, ok := runtime.Caller(2); ok {
-				f, _ := runtime.CallersFrames([]uintptr{ptr}).Next()
-				span.SetTag("caller.file", path.Base(path.Dir(file))+"/"+path.Base(file))
-				span.SetTag("caller.func", f.Function)
-				span.SetTag("caller.line", f.Line)
+		if t.caller != nil && c == 1 { // Only for root span.
+			if cl, ok := t.caller.Capture(); ok {
+				setCaller(span, cl)
 			}
 		}
 
@@ -98,20 +151,45 @@ func (t *Tracer) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 }
 
 // spanContext returns a new span.
-func (t *Tracer) spanContext(r *http.Request, name string) (opentracing.Span, context.Context) {
+// If the sampler is not nil, the sampling decision of
+// a root span or a span with remote parent is made by the sampler.
+func (t *Tracer) spanContext(r *http.Request, name string, sampler *sampling.Sampler) (opentracing.Span, context.Context) {
+	ctx := r.Context()
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		return t.joinSpan(ctx, name, ids)
+	}
 	var span opentracing.Span
-	if parentSpan := opentracing.SpanFromContext(r.Context()); parentSpan != nil {
+	if parentSpan := opentracing.SpanFromContext(ctx); parentSpan != nil {
 		span = t.tracer.StartSpan(name, opentracing.ChildOf(parentSpan.Context()))
+	} else if sc := remoteFromContext(ctx); sc != nil {
+		span = t.tracer.StartSpan(name, opentracing.ChildOf(sc))
 	} else {
 		carrier := opentracing.HTTPHeadersCarrier(r.Header)
 		sc, err := t.tracer.Extract(opentracing.HTTPHeaders, carrier)
-		if errors.Is(err, opentracing.ErrSpanContextNotFound) {
-			span = t.tracer.StartSpan(name)
-		} else {
-			span = t.tracer.StartSpan(name, opentracing.ChildOf(sc))
+		ctx = extractBaggage(ctx, sc, tracing.HeaderCarrier(r.Header))
+		var opts []opentracing.StartSpanOption
+		if !errors.Is(err, opentracing.ErrSpanContextNotFound) {
+			opts = append(opts, opentracing.ChildOf(sc))
+		}
+		if sampler != nil {
+			jsc, ok := sc.(jaegerclient.SpanContext)
+			valid := ok && jsc.IsValid()
+			if !valid || !sampler.ParentBased() {
+				id := rand.Uint64()
+				if valid {
+					id = jsc.TraceID().Low
+				}
+				priority := uint16(0)
+				if sampler.Sample(r, id) {
+					priority = 1
+				}
+				opts = append(opts, opentracing.Tag{Key: string(ext.SamplingPriority), Value: priority})
+			}
 		}
+		span = t.tracer.StartSpan(name, opts...)
 	}
-	return span, opentracing.ContextWithSpan(r.Context(), span)
+	t.tagBaggage(ctx, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
 }
 
 // Trace is the method that can be called from any types of resources.
@@ -119,15 +197,60 @@ func (t *Tracer) spanContext(r *http.Request, name string) (opentracing.Span, co
 // The returned function with finishes spans must be called when finishing spans.
 func (t *Tracer) Trace(ctx context.Context, name string, tags map[string]string) (spanCtx context.Context, finish func()) {
 	var span opentracing.Span
-	if parentSpan := opentracing.SpanFromContext(ctx); parentSpan != nil {
-		span = t.tracer.StartSpan(name, opentracing.ChildOf(parentSpan.Context()))
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		span, spanCtx = t.joinSpan(ctx, name, ids)
 	} else {
-		span = t.tracer.StartSpan(name)
+		if parentSpan := opentracing.SpanFromContext(ctx); parentSpan != nil {
+			span = t.tracer.StartSpan(name, opentracing.ChildOf(parentSpan.Context()))
+		} else if sc := remoteFromContext(ctx); sc != nil {
+			span = t.tracer.StartSpan(name, opentracing.ChildOf(sc))
+		} else {
+			span = t.tracer.StartSpan(name)
+		}
+		spanCtx = opentracing.ContextWithSpan(ctx, span)
 	}
+	t.tagBaggage(ctx, span)
 	for k, v := range tags {
 		span.SetTag(k, v)
 	}
-	return opentracing.ContextWithSpan(ctx, span), span.Finish
+	return spanCtx, span.Finish
+}
+
+// Monitor returns the monitor of the jaeger reporter.
+// The monitor can be registered to a metrics backend to
+// publish the internal metrics of the reporter.
+// Export latency is not available for jaeger.
+func (t *Tracer) Monitor() *health.Monitor {
+	return t.monitor
+}
+
+// Sampler returns the rule based sampler configured by the Config.Sampling.
+// It returns nil if not configured.
+// The sampler can be registered to a metrics backend
+// to publish the sampling metrics.
+func (t *Tracer) Sampler() *sampling.Sampler {
+	return t.sampler
+}
+
+// SpanMetrics returns the span metrics given by the Config.SpanMetrics.
+// It returns nil if not configured.
+// The metrics can be registered to a metrics backend
+// to publish the request, error and duration metrics of spans.
+func (t *Tracer) SpanMetrics() *spanmetrics.Metrics {
+	return t.spanMetrics
+}
+
+// ServiceGraph returns the service graph given by the Config.ServiceGraph.
+// It returns nil if not configured.
+// The graph can be registered to a metrics backend
+// to publish the service graph metrics.
+func (t *Tracer) ServiceGraph() *servicegraph.Graph {
+	return t.serviceGraph
+}
+
+// Health returns the health status of the jaeger reporter.
+func (t *Tracer) Health() health.Status {
+	return t.monitor.Health()
 }
 
 // Finalize closes internal tracer flushing remained trace data.
@@ -135,6 +258,15 @@ func (t *Tracer) Finalize(_ context.Context) error {
 	return t.closer.Close()
 }
 
+// cloneHeader returns a copy of the h so that
+// client middleware do not modify the original request.
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return http.Header{}
+	}
+	return h.Clone()
+}
+
 func serverSpanHook(span opentracing.Span, w http.ResponseWriter, r *http.Request) {
 	if id := zuid.FromContext(r.Context(), "context"); id != "" {
 		span.SetTag("context", id)
@@ -157,6 +289,30 @@ func serverSpanHook(span opentracing.Span, w http.ResponseWriter, r *http.Reques
 	span.SetTag("http.response_content_length", ww.Written())
 }
 
+// setCaller sets the caller tags and
+// the "caller.stack" log if the stack is available.
+func setCaller(span opentracing.Span, c *tracing.Caller) {
+	span.SetTag("caller.file", c.File)
+	span.SetTag("caller.func", c.Func)
+	span.SetTag("caller.line", c.Line)
+	if c.Stack != "" {
+		span.LogFields(log.String("event", "caller.stack"), log.String("stack", c.Stack))
+	}
+}
+
+// recordPanic records the panic as the "error" log
+// and marks the span as an error with the status code 500.
+func recordPanic(span opentracing.Span, p *recovery.Panic) {
+	span.LogFields(
+		log.String("event", "error"),
+		log.String("error.kind", p.Type()),
+		log.String("message", p.Message()),
+		log.String("stack", string(p.Stack)),
+	)
+	span.SetTag("http.status_code", http.StatusInternalServerError)
+	ext.Error.Set(span, true)
+}
+
 func clientSpanHook(span opentracing.Span, w *http.Response, r *http.Request) {
 	if id := zuid.FromContext(r.Context(), "context"); id != "" {
 		span.SetTag("context", id)
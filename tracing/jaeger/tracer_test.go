@@ -0,0 +1,31 @@
+package jaeger
+
+import (
+	"context"
+	"testing"
+
+	jaegerclient "github.com/uber/jaeger-client-go"
+)
+
+// newInMemoryTracer returns a new tracer created from the c
+// whose spans are reported to the returned in-memory reporter.
+// The jaeger tracer under the redacting tracer is replaced
+// so that the tracer is configured as created by [New].
+func newInMemoryTracer(t *testing.T, c *Config) (*Tracer, *jaegerclient.InMemoryReporter) {
+	t.Helper()
+	tr, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.closer.Close()
+	rep := jaegerclient.NewInMemoryReporter()
+	jt, closer := jaegerclient.NewTracer("test", jaegerclient.NewConstSampler(true), rep)
+	if rt, ok := tr.tracer.(*redactingTracer); ok {
+		rt.Tracer = jt
+	} else {
+		tr.tracer = jt
+	}
+	tr.closer = closer
+	t.Cleanup(func() { tr.Finalize(context.Background()) })
+	return tr, rep
+}
@@ -0,0 +1,181 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+)
+
+var (
+	_ TraceMiddleware = &Multi{}
+	_ IDTracer        = &Multi{}
+)
+
+// SpanIDs is the identifiers of a span
+// shared between tracing backends.
+type SpanIDs struct {
+	// TraceID is the 128 bits trace id.
+	// Upper 64 bits are zero for 64 bits trace ids.
+	TraceID [16]byte
+	// SpanID is the span id.
+	SpanID [8]byte
+	// ParentID is the span id of the parent.
+	// It is zero for root spans.
+	ParentID [8]byte
+	// Sampled is the sampling decision.
+	Sampled bool
+}
+
+// IsValid returns true if both trace id and span id are not zero.
+func (s SpanIDs) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+type spanIDsKey struct{}
+
+// ContextWithSpanIDs returns a new context with the ids.
+// Tracers that implement [IDTracer] start the next span
+// with exactly the same ids when found in the context.
+// The ids are consumed by the first span started.
+func ContextWithSpanIDs(ctx context.Context, ids SpanIDs) context.Context {
+	return context.WithValue(ctx, spanIDsKey{}, ids)
+}
+
+// SpanIDsFromContext returns the ids saved in the ctx.
+// It returns false if not found or the ids are invalid.
+func SpanIDsFromContext(ctx context.Context) (SpanIDs, bool) {
+	ids, ok := ctx.Value(spanIDsKey{}).(SpanIDs)
+	return ids, ok && ids.IsValid()
+}
+
+// IDTracer is the [TraceMiddleware] that can share
+// span ids with other tracers.
+type IDTracer interface {
+	TraceMiddleware
+	// SpanIDs returns the ids of the current span in the ctx.
+	// It returns false if no span found.
+	SpanIDs(ctx context.Context) (SpanIDs, bool)
+}
+
+type multiCtxKey struct{ key contextKey }
+
+type multiReqKey struct{}
+
+// Multi is the [TraceMiddleware] that records the same spans
+// to multiple tracing backends. Spans are created by the primary
+// tracer first and other tracers create spans with the same ids.
+// Trace context is extracted and propagated only in the format
+// of the primary tracer.
+// Use [NewMulti] to create a new instance.
+type Multi struct {
+	primary IDTracer
+	others  []IDTracer
+}
+
+// NewMulti returns a new [Multi] tracer.
+// The primary is used to extract and propagate trace context.
+// All tracers must implement [IDTracer].
+func NewMulti(primary TraceMiddleware, others ...TraceMiddleware) (*Multi, error) {
+	p, ok := primary.(IDTracer)
+	if !ok {
+		return nil, fmt.Errorf("tracing: primary tracer %T does not implement IDTracer", primary)
+	}
+	m := &Multi{primary: p}
+	for _, t := range others {
+		it, ok := t.(IDTracer)
+		if !ok {
+			return nil, fmt.Errorf("tracing: tracer %T does not implement IDTracer", t)
+		}
+		m.others = append(m.others, it)
+	}
+	return m, nil
+}
+
+// join returns a new context for the next tracer.
+// The middleware counter of the key is restored to the value
+// before the primary tracer so that all tracers recognize
+// the root span in the same way.
+func (m *Multi) join(ctx context.Context, key contextKey) context.Context {
+	c, _ := ctx.Value(multiCtxKey{key}).(int)
+	ctx = context.WithValue(ctx, key, c)
+	if ids, ok := m.primary.SpanIDs(ctx); ok {
+		ctx = ContextWithSpanIDs(ctx, ids)
+	}
+	return ctx
+}
+
+func (m *Multi) ServerMiddleware(next http.Handler) http.Handler {
+	for i := len(m.others) - 1; i >= 0; i-- {
+		h := m.others[i].ServerMiddleware(next)
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(m.join(r.Context(), ServerCtxKey)))
+		})
+	}
+	h := m.primary.ServerMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := r.Context().Value(ServerCtxKey).(int)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), multiCtxKey{ServerCtxKey}, c)))
+	})
+}
+
+func (m *Multi) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	// Other tracers handle a clone of the request and
+	// the request handled by the primary tracer is sent
+	// so that only the primary tracer propagates.
+	var rt http.RoundTripper = zhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		orig := r.Context().Value(multiReqKey{}).(*http.Request)
+		return next.RoundTrip(orig.WithContext(r.Context()))
+	})
+	for i := len(m.others) - 1; i >= 0; i-- {
+		t := m.others[i].ClientMiddleware(rt)
+		rt = zhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return t.RoundTrip(r.WithContext(m.join(r.Context(), ClientCtxKey)))
+		})
+	}
+	others := rt
+	t := m.primary.ClientMiddleware(zhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return others.RoundTrip(r.Clone(context.WithValue(r.Context(), multiReqKey{}, r)))
+	}))
+	return zhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		c, _ := r.Context().Value(ClientCtxKey).(int)
+		return t.RoundTrip(r.WithContext(context.WithValue(r.Context(), multiCtxKey{ClientCtxKey}, c)))
+	})
+}
+
+// Trace starts spans in all tracers.
+// Callers must update their context with the returned one.
+// The returned function finishes spans of all tracers.
+func (m *Multi) Trace(ctx context.Context, name string, tags map[string]string) (spanCtx context.Context, finish func()) {
+	ctx, f := m.primary.Trace(ctx, name, tags)
+	finishes := []func(){f}
+	ids, ok := m.primary.SpanIDs(ctx)
+	for _, t := range m.others {
+		if ok {
+			ctx = ContextWithSpanIDs(ctx, ids)
+		}
+		ctx, f = t.Trace(ctx, name, tags)
+		finishes = append(finishes, f)
+	}
+	return ctx, func() {
+		for i := len(finishes) - 1; i >= 0; i-- {
+			finishes[i]()
+		}
+	}
+}
+
+// SpanIDs returns the ids of the current span of the primary tracer.
+func (m *Multi) SpanIDs(ctx context.Context) (SpanIDs, bool) {
+	return m.primary.SpanIDs(ctx)
+}
+
+// Finalize finalizes all tracers.
+func (m *Multi) Finalize(ctx context.Context) error {
+	errs := []error{m.primary.Finalize(ctx)}
+	for _, t := range m.others {
+		errs = append(errs, t.Finalize(ctx))
+	}
+	return errors.Join(errs...)
+}
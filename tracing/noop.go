@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+var (
+	_ TraceMiddleware = Noop{}
+	_ IDTracer        = Noop{}
+)
+
+// Noop is the [TraceMiddleware] that does nothing.
+// It can be used in place of real tracers
+// when tracing is disabled.
+type Noop struct{}
+
+func (Noop) ServerMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func (Noop) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	return next
+}
+
+// Trace returns the ctx as-is and a function that does nothing.
+func (Noop) Trace(ctx context.Context, _ string, _ map[string]string) (context.Context, func()) {
+	return ctx, func() {}
+}
+
+// SpanIDs always returns false.
+func (Noop) SpanIDs(_ context.Context) (SpanIDs, bool) {
+	return SpanIDs{}, false
+}
+
+// Finalize does nothing and always returns nil.
+func (Noop) Finalize(_ context.Context) error {
+	return nil
+}
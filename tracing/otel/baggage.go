@@ -0,0 +1,31 @@
+package otel
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	_ sdktrace.SpanProcessor = &baggageProcessor{}
+)
+
+// baggageProcessor copies the baggage entries of the keys
+// to the attributes of spans when the spans are started.
+type baggageProcessor struct {
+	keys []string
+}
+
+func (p *baggageProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for k, v := range tracing.SelectBaggage(parent, p.keys) {
+		s.SetAttributes(attribute.String(k, v))
+	}
+}
+
+func (p *baggageProcessor) OnEnd(_ sdktrace.ReadOnlySpan) {}
+
+func (p *baggageProcessor) Shutdown(_ context.Context) error { return nil }
+
+func (p *baggageProcessor) ForceFlush(_ context.Context) error { return nil }
@@ -2,8 +2,18 @@ package otel
 
 import (
 	"cmp"
+	"context"
+	"errors"
 	"net/http"
+	"slices"
 
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
 	"go.opentelemetry.io/contrib/propagators/autoprop"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
@@ -23,34 +33,215 @@ type Config struct {
 	// If empty, default "aileron" is used.
 	ServiceName string
 
-	Props        []propagation.TextMapPropagator
+	// Props is the list of propagators.
+	// If empty, propagators defined by the OTEL_PROPAGATORS
+	// environment variable or TraceContext and Baggage are used.
+	Props []propagation.TextMapPropagator
+	// ProviderOpts is the options for the tracer provider.
+	// A sampler given by [sdktrace.WithSampler] should be wrapped
+	// with the [JoinSampler] when the tracer is used by [tracing.Multi]
+	// so that the spans follow the decisions of the primary tracer.
+	// Span processors and exporters must not be registered through
+	// ProviderOpts when the Redactor is set. Use Processors instead.
 	ProviderOpts []sdktrace.TracerProviderOption
 	TracerOpts   []trace.TracerOption
 	Attributes   []attribute.KeyValue
 
+	// Exporters is the list of span exporters.
+	// Each exporter is registered with a batch span processor
+	// configured by BatchOpts and monitored by the [Tracer.Monitor].
+	// Exporters registered through ProviderOpts or Processors are not monitored.
+	// Exporters are shut down by [Tracer.Finalize], or by [New]
+	// if it fails after the batch span processors are created.
+	Exporters []sdktrace.SpanExporter
+	// BatchOpts is the options for batch span processors
+	// created for the Exporters.
+	BatchOpts []sdktrace.BatchSpanProcessorOption
+	// Processors is the list of span processors registered
+	// in addition to the ones of the Exporters.
+	// Unlike the processors registered through ProviderOpts,
+	// they receive spans redacted by the Redactor.
+	// They are not tail sampled and are shut down by [Tracer.Finalize].
+	Processors []sdktrace.SpanProcessor
+	// Persistence enables the disk queue for the exporter.
+	// Batches failed to be exported are persisted and replayed
+	// by the [PersistentExporter] while export failures are
+	// recorded by the [Tracer.Monitor].
+	// Only one exporter can be given with the Persistence
+	// because the directory cannot be shared.
+	Persistence *diskqueue.Config
+	// ErrorHandler handles errors returned from the Exporters.
+	// Errors are not passed to the global OpenTelemetry
+	// error handler even if ErrorHandler is nil.
+	ErrorHandler health.ErrorHandler
+	// TailSampling enables tail based sampling for the Exporters.
+	// Spans are passed to the batch span processors of the Exporters
+	// only when the trace is sampled by the [TailSamplingProcessor].
+	// Head sampler configured by ProviderOpts should sample
+	// all spans that may be kept by the tail sampler.
+	TailSampling *TailSamplingConfig
+	// Sampling is the rule based sampling configuration.
+	// If set, the [RuleSampler] is used as the sampler
+	// of the tracer provider overriding the one in ProviderOpts.
+	Sampling *sampling.Config
+
+	// BaggageKeys is the list of baggage keys copied to
+	// the attributes of spans when the spans are started.
+	// Baggage is propagated only when the Props contain
+	// the baggage propagator which is included by default.
+	BaggageKeys []string
+
+	// ResponseHeaders writes the trace id of the root server spans
+	// in the response headers. If nil, nothing is written.
+	ResponseHeaders *tracing.ResponseHeaderConfig
+
+	// SpanMetrics records the request, error and duration metrics
+	// of the finished spans with the [SpanMetricsProcessor].
+	// Spans are recorded before the tail sampling.
+	// If nil, metrics are not recorded.
+	SpanMetrics *spanmetrics.Metrics
+	// ServiceGraph records the service graph built from the
+	// pairs of client and server spans with the [SpanMetricsProcessor].
+	// Spans are recorded before the tail sampling.
+	// If nil, the graph is not recorded.
+	ServiceGraph *servicegraph.Graph
+	// Redactor redacts the span names and attributes of the finished
+	// spans with the [RedactingProcessor] before they are passed to
+	// the span processors of the Exporters, the tail sampling,
+	// the Processors, the SpanMetrics and the ServiceGraph.
+	// Span processors and exporters registered through ProviderOpts
+	// receive spans that are not redacted.
+	// If nil, spans are not redacted.
+	Redactor *redact.Redactor
+
+	// AddCaller, if true, add caller info
+	// to the root span tag.
 	AddCaller bool
+	// Caller configures the caller capture of the AddCaller.
+	// If nil, frames of the [tracing.DefaultCallerSkipPrefixes]
+	// are skipped and the stack is not recorded.
+	Caller *tracing.CallerConfig
+	// SpanNameFunc returns the names of http spans.
+	// If nil, [tracing.LegacySpanName] is used for the compatibility
+	// with existing dashboards. Use [tracing.RouteSpanName] or
+	// [tracing.TemplateSpanName] for low cardinality names.
+	SpanNameFunc tracing.SpanNameFunc
+	// RecordPanics, if true, records panics in the server middleware
+	// as exception events of the spans with the status code 500
+	// and then panics again with the [recovery.Panic].
+	// Use the [recovery.Middleware] to reply 500 or to re-panic.
+	RecordPanics bool
 
 	ServerSpanFunc func(span trace.Span, w http.ResponseWriter, r *http.Request)
 	ClientSpanFunc func(span trace.Span, w *http.Response, r *http.Request)
 }
 
 func New(c *Config) (*Tracer, error) {
-	c.Attributes = append(c.Attributes, semconv.ServiceName(cmp.Or(c.ServiceName, "aileron")))
-	c.ProviderOpts = append(c.ProviderOpts, sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, c.Attributes...)))
-	tracerProvider := sdktrace.NewTracerProvider(c.ProviderOpts...)
-	props := c.Props
-	if len(props) == 0 {
-		props = append(props, propagation.TraceContext{}, propagation.Baggage{})
+	attrs := slices.Concat(c.Attributes, []attribute.KeyValue{semconv.ServiceName(cmp.Or(c.ServiceName, "aileron"))})
+	// Prepend the id generator and the sampler so that they can be overwritten.
+	// Spans cannot share ids with other tracers if overwritten.
+	opts := slices.Concat(
+		[]sdktrace.TracerProviderOption{
+			sdktrace.WithIDGenerator(&idGenerator{}),
+			sdktrace.WithSampler(JoinSampler(sdktrace.ParentBased(sdktrace.AlwaysSample()))),
+		},
+		c.ProviderOpts,
+		[]sdktrace.TracerProviderOption{sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...))},
+	)
+	monitor := health.NewMonitor(&health.Config{
+		Exporter:     "otel",
+		Signal:       "spans",
+		ErrorHandler: c.ErrorHandler,
+	})
+	if c.Persistence != nil && len(c.Exporters) > 1 {
+		return nil, errors.New("otel: persistence cannot be used with multiple exporters")
+	}
+	// The sampler is created before the processors
+	// which start goroutines and open disk queues.
+	var sampler *sampling.Sampler
+	if c.Sampling != nil {
+		s, err := sampling.New(c.Sampling)
+		if err != nil {
+			return nil, err
+		}
+		sampler = s
+	}
+	procs := make([]sdktrace.SpanProcessor, 0, len(c.Exporters))
+	for _, exp := range c.Exporters {
+		var e sdktrace.SpanExporter = &monitoredExporter{next: exp, monitor: monitor}
+		if c.Persistence != nil {
+			pe, err := newPersistentExporter(exp, c.Persistence, monitor)
+			if err != nil {
+				shutdownProcessors(procs)
+				return nil, err
+			}
+			e = pe
+		}
+		procs = append(procs, newMonitoredProcessor(e, monitor, c.BatchOpts...))
+	}
+	var tailSampler *TailSamplingProcessor
+	if c.TailSampling != nil {
+		tsp, err := NewTailSamplingProcessor(c.TailSampling, procs...)
+		if err != nil {
+			shutdownProcessors(procs)
+			return nil, err
+		}
+		tailSampler, procs = tsp, []sdktrace.SpanProcessor{tsp}
+	}
+	if len(c.BaggageKeys) > 0 {
+		// Registered first so that the attributes are
+		// visible to the following processors.
+		procs = append([]sdktrace.SpanProcessor{&baggageProcessor{keys: c.BaggageKeys}}, procs...)
+	}
+	procs = append(procs, c.Processors...)
+	var recorders []spanmetrics.Recorder
+	if c.SpanMetrics != nil {
+		recorders = append(recorders, c.SpanMetrics)
+	}
+	if c.ServiceGraph != nil {
+		recorders = append(recorders, c.ServiceGraph)
+	}
+	if len(recorders) > 0 {
+		procs = append(procs, NewSpanMetricsProcessor(recorders...))
+	}
+	if c.Redactor != nil && len(procs) > 0 {
+		procs = []sdktrace.SpanProcessor{NewRedactingProcessor(c.Redactor, procs...)}
+	}
+	for _, p := range procs {
+		opts = append(opts, sdktrace.WithSpanProcessor(p))
+	}
+	if sampler != nil {
+		opts = append(opts, sdktrace.WithSampler(JoinSampler(RuleSampler(sampler))))
+	}
+	tracerProvider := sdktrace.NewTracerProvider(opts...)
+	// autoprop prefers OTEL_PROPAGATORS over given propagators.
+	// Explicitly configured propagators take precedence here.
+	pg := autoprop.NewTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	if len(c.Props) > 0 {
+		pg = propagation.NewCompositeTextMapPropagator(c.Props...)
 	}
 
 	tracer := tracerProvider.Tracer(ScopeName, c.TracerOpts...)
 	t := &Tracer{
-		tracer:         tracer,
-		tp:             tracerProvider,
-		pg:             autoprop.NewTextMapPropagator(props...),
-		addCaller:      c.AddCaller,
-		serverSpanHook: c.ServerSpanFunc,
-		clientSpanHook: c.ClientSpanFunc,
+		tracer:          tracer,
+		tp:              tracerProvider,
+		pg:              pg,
+		monitor:         monitor,
+		tailSampler:     tailSampler,
+		sampler:         sampler,
+		spanMetrics:     c.SpanMetrics,
+		serviceGraph:    c.ServiceGraph,
+		spanName:        c.SpanNameFunc,
+		recordPanics:    c.RecordPanics,
+		responseHeaders: c.ResponseHeaders,
+		serverSpanHook:  c.ServerSpanFunc,
+		clientSpanHook:  c.ClientSpanFunc,
+	}
+	if c.AddCaller {
+		t.caller = tracing.NewCallerCapture(c.Caller)
+	}
+	if t.spanName == nil {
+		t.spanName = tracing.LegacySpanName
 	}
 	if t.serverSpanHook == nil {
 		t.serverSpanHook = serverSpanHook
@@ -60,3 +251,11 @@ func New(c *Config) (*Tracer, error) {
 	}
 	return t, nil
 }
+
+// shutdownProcessors shuts down the processors and their exporters
+// created by [New] when a later step fails.
+func shutdownProcessors(procs []sdktrace.SpanProcessor) {
+	for _, p := range procs {
+		_ = p.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,64 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNew_config(t *testing.T) {
+	attrs := make([]attribute.KeyValue, 1, 4)
+	attrs[0] = attribute.String("foo", "bar")
+	opts := make([]sdktrace.TracerProviderOption, 1, 4)
+	opts[0] = sdktrace.WithSampler(sdktrace.AlwaysSample())
+	c := &Config{
+		Attributes:   attrs,
+		ProviderOpts: opts,
+		Exporters:    []sdktrace.SpanExporter{&flakyExporter{}},
+	}
+	tr, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Finalize(context.Background())
+	if len(c.Attributes) != 1 || attrs[:4][1] != (attribute.KeyValue{}) {
+		t.Error("attributes of the config must not be modified")
+	}
+	if len(c.ProviderOpts) != 1 || opts[:4][1] != nil {
+		t.Error("provider options of the config must not be modified")
+	}
+}
+
+func TestNew_cleanup(t *testing.T) {
+	invalidRatio := 2.0
+	testCases := map[string]struct {
+		c        *Config
+		shutdown bool
+	}{
+		"tail sampling error": {&Config{
+			Persistence:  &diskqueue.Config{Dir: t.TempDir()},
+			TailSampling: &TailSamplingConfig{},
+		}, true},
+		"sampling error": {&Config{
+			Persistence: &diskqueue.Config{Dir: t.TempDir()},
+			Sampling:    &sampling.Config{DefaultRatio: &invalidRatio},
+		}, false},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			exp := &flakyExporter{}
+			tc.c.Exporters = []sdktrace.SpanExporter{exp}
+			if _, err := New(tc.c); err == nil {
+				t.Fatal("want an error")
+			}
+			// Exporters are shut down only when the processors have been created.
+			if exp.shutdown.Load() != tc.shutdown {
+				t.Errorf("want shutdown %v, got %v", tc.shutdown, exp.shutdown.Load())
+			}
+		})
+	}
+}
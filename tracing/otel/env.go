@@ -0,0 +1,203 @@
+package otel
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Environment variables read by [FromEnv].
+// See https://opentelemetry.io/docs/specs/otel/configuration/sdk-environment-variables/
+const (
+	EnvServiceName        = "OTEL_SERVICE_NAME"
+	EnvTracesExporter     = "OTEL_TRACES_EXPORTER"
+	EnvOTLPProtocol       = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	EnvOTLPTracesProtocol = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+	EnvTracesSampler      = "OTEL_TRACES_SAMPLER"
+	EnvTracesSamplerArg   = "OTEL_TRACES_SAMPLER_ARG"
+	EnvPropagators        = "OTEL_PROPAGATORS"
+)
+
+// FromEnv returns a new [Config] built from the OTEL_* environment variables.
+// Values already set in c take precedence over the environment.
+// c can be nil and is not modified.
+//
+// Following variables are read:
+//
+//   - OTEL_SERVICE_NAME: used when c.ServiceName is empty.
+//   - OTEL_TRACES_EXPORTER: comma separated list of "otlp", "console", "stdout" or "none".
+//   - OTEL_EXPORTER_OTLP_TRACES_PROTOCOL, OTEL_EXPORTER_OTLP_PROTOCOL: "grpc" or "http/protobuf" (default).
+//   - OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG: sampler and its argument.
+//   - OTEL_PROPAGATORS: used when c.Props is empty.
+//
+// Endpoints, headers, timeouts and TLS settings of the OTLP exporters
+// are read from the OTEL_EXPORTER_OTLP_* variables by the exporters themselves.
+// Exporters are prepended to c.Exporters and registered with
+// batch span processors which read OTEL_BSP_* variables.
+// When OTEL_TRACES_EXPORTER is not set, the "otlp" exporter is used
+// only if both c.Exporters and c.Processors are empty.
+// Exporters registered through c.ProviderOpts are not taken into account.
+// Provider options built from the environment are placed before c.ProviderOpts
+// so the explicitly given options, for example [sdktrace.WithSampler], override them.
+// Exporters created by FromEnv are shut down if it returns an error.
+func FromEnv(ctx context.Context, c *Config) (*Config, error) {
+	cc := &Config{}
+	if c != nil {
+		*cc = *c
+	}
+	cc.ServiceName = cmp.Or(cc.ServiceName, os.Getenv(EnvServiceName))
+
+	var opts []sdktrace.TracerProviderOption
+	sampler, err := samplerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if sampler != nil {
+		opts = append(opts, sdktrace.WithSampler(JoinSampler(sampler)))
+	}
+
+	props := cc.Props
+	if len(props) == 0 {
+		props, err = propagatorsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Exporters are created last so that
+	// they are not leaked by the errors above.
+	names := os.Getenv(EnvTracesExporter)
+	if names == "" && len(cc.Exporters) == 0 && len(cc.Processors) == 0 {
+		names = "otlp"
+	}
+	exporters, err := exportersFromEnv(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	cc.Exporters = slices.Concat(exporters, cc.Exporters)
+	cc.ProviderOpts = slices.Concat(opts, cc.ProviderOpts)
+	cc.Props = props
+	return cc, nil
+}
+
+// exportersFromEnv returns span exporters of the comma separated
+// names given by the OTEL_TRACES_EXPORTER environment variable.
+// Created exporters are shut down if an error occurred.
+func exportersFromEnv(ctx context.Context, names string) (exporters []sdktrace.SpanExporter, err error) {
+	defer func() {
+		if err != nil {
+			for _, exp := range exporters {
+				_ = exp.Shutdown(context.Background())
+			}
+		}
+	}()
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "otlp":
+			exp, err := otlpExporterFromEnv(ctx)
+			if err != nil {
+				return exporters, err
+			}
+			exporters = append(exporters, exp)
+		case "console", "stdout":
+			exp, err := stdouttrace.New()
+			if err != nil {
+				return exporters, err
+			}
+			exporters = append(exporters, exp)
+		case "none", "":
+		default:
+			return exporters, fmt.Errorf("otel: unsupported %s value %q", EnvTracesExporter, name)
+		}
+	}
+	return exporters, nil
+}
+
+// otlpExporterFromEnv returns an OTLP span exporter which uses the
+// protocol defined by the OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or
+// OTEL_EXPORTER_OTLP_PROTOCOL environment variable.
+func otlpExporterFromEnv(ctx context.Context) (sdktrace.SpanExporter, error) {
+	protocol := cmp.Or(os.Getenv(EnvOTLPTracesProtocol), os.Getenv(EnvOTLPProtocol), "http/protobuf")
+	switch strings.ToLower(strings.TrimSpace(protocol)) {
+	case "grpc":
+		return otlptracegrpc.New(ctx)
+	case "http/protobuf":
+		return otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("otel: unsupported otlp protocol %q", protocol)
+	}
+}
+
+// samplerFromEnv returns a sampler defined by the OTEL_TRACES_SAMPLER
+// and OTEL_TRACES_SAMPLER_ARG environment variables.
+// It returns nil sampler when OTEL_TRACES_SAMPLER is not set.
+func samplerFromEnv() (sdktrace.Sampler, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv(EnvTracesSampler)))
+	arg := strings.TrimSpace(os.Getenv(EnvTracesSamplerArg))
+	ratio := func() (sdktrace.Sampler, error) {
+		if arg == "" {
+			return sdktrace.TraceIDRatioBased(1.0), nil
+		}
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("otel: invalid %s value %q: %w", EnvTracesSamplerArg, arg, err)
+		}
+		if v < 0.0 || v > 1.0 {
+			return nil, fmt.Errorf("otel: invalid %s value %q: ratio must be in [0.0, 1.0]", EnvTracesSamplerArg, arg)
+		}
+		return sdktrace.TraceIDRatioBased(v), nil
+	}
+	switch name {
+	case "":
+		return nil, nil
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		return ratio()
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		s, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(s), nil
+	default:
+		return nil, fmt.Errorf("otel: unsupported %s value %q", EnvTracesSampler, name)
+	}
+}
+
+// propagatorsFromEnv returns propagators defined by the
+// OTEL_PROPAGATORS environment variable.
+// It returns nil when OTEL_PROPAGATORS is not set.
+func propagatorsFromEnv() ([]propagation.TextMapPropagator, error) {
+	var names []string
+	for _, name := range strings.Split(os.Getenv(EnvPropagators), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	prop, err := autoprop.TextMapPropagator(names...)
+	if err != nil {
+		return nil, fmt.Errorf("otel: invalid %s value: %w", EnvPropagators, err)
+	}
+	return []propagation.TextMapPropagator{prop}, nil
+}
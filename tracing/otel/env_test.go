@@ -0,0 +1,79 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFromEnv_exporters(t *testing.T) {
+	explicit := tracetest.NewInMemoryExporter()
+	testCases := map[string]struct {
+		env  string
+		c    *Config
+		want []string
+	}{
+		"default":                {"", nil, []string{"otlp"}},
+		"explicit exporters":     {"", &Config{Exporters: []sdktrace.SpanExporter{explicit}}, []string{"explicit"}},
+		"explicit processors":    {"", &Config{Processors: []sdktrace.SpanProcessor{sdktrace.NewSimpleSpanProcessor(explicit)}}, nil},
+		"explicit sampler":       {"", &Config{ProviderOpts: []sdktrace.TracerProviderOption{sdktrace.WithSampler(sdktrace.NeverSample())}}, []string{"otlp"}},
+		"env with exporters":     {"console", &Config{Exporters: []sdktrace.SpanExporter{explicit}}, []string{"stdout", "explicit"}},
+		"env none":               {"none", nil, nil},
+		"env multiple exporters": {"otlp, stdout", nil, []string{"otlp", "stdout"}},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(EnvTracesExporter, tc.env)
+			c, err := FromEnv(context.Background(), tc.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, exp := range c.Exporters {
+				switch exp.(type) {
+				case *stdouttrace.Exporter:
+					got = append(got, "stdout")
+				case *tracetest.InMemoryExporter:
+					got = append(got, "explicit")
+				default:
+					got = append(got, "otlp")
+					_ = exp.Shutdown(context.Background())
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("want exporters %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("want exporters %v, got %v", tc.want, got)
+				}
+			}
+			if tc.c != nil && len(tc.c.Exporters) > 1 {
+				t.Error("config must not be modified")
+			}
+		})
+	}
+}
+
+func TestFromEnv_error(t *testing.T) {
+	testCases := map[string]map[string]string{
+		"unsupported exporter": {EnvTracesExporter: "otlp,unknown"},
+		"unsupported protocol": {EnvTracesExporter: "otlp", EnvOTLPTracesProtocol: "unknown"},
+		"unsupported sampler":  {EnvTracesSampler: "unknown"},
+		"invalid sampler arg":  {EnvTracesSampler: "traceidratio", EnvTracesSamplerArg: "2"},
+		"invalid propagator":   {EnvPropagators: "unknown"},
+	}
+	for name, env := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if c, err := FromEnv(context.Background(), nil); err == nil {
+				t.Errorf("want an error, got %v", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package otel
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpcx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that traces unary RPCs.
+// Trace context is extracted from the incoming metadata.
+func (t *Tracer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		span, ctx := t.grpcServerSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		finishGRPCSpan(span, grpcx.NewUnaryInfo(info.FullMethod, err, true))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that traces streaming RPCs.
+// Trace context is extracted from the incoming metadata.
+func (t *Tracer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span, ctx := t.grpcServerSpan(ss.Context(), info.FullMethod)
+		ws := &grpcx.ServerStream{ServerStream: ss, Ctx: ctx}
+		err := handler(srv, ws)
+		finishGRPCSpan(span, grpcx.NewInfo(info.FullMethod, err, ws.Sent(), ws.Received()))
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that traces unary RPCs.
+// Trace context is injected into the outgoing metadata.
+func (t *Tracer) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := t.grpcClientSpan(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishGRPCSpan(span, grpcx.NewUnaryInfo(method, err, false))
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor that traces streaming RPCs.
+// Trace context is injected into the outgoing metadata.
+// Spans are finished when the stream ends.
+func (t *Tracer) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := t.grpcClientSpan(ctx, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishGRPCSpan(span, grpcx.NewInfo(method, err, 0, 0))
+			return nil, err
+		}
+		return grpcx.NewClientStream(cs, desc, func(err error, sent, received int64) {
+			finishGRPCSpan(span, grpcx.NewInfo(method, err, sent, received))
+		}), nil
+	}
+}
+
+func (t *Tracer) grpcServerSpan(ctx context.Context, fullMethod string) (trace.Span, context.Context) {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		ctx = t.pg.Extract(ctx, grpcx.MDCarrier(grpcx.IncomingMD(ctx)))
+	}
+	ctx, span := t.tracer.Start(ctx, "server+"+fullMethod, trace.WithSpanKind(trace.SpanKindServer))
+	return span, ctx
+}
+
+func (t *Tracer) grpcClientSpan(ctx context.Context, fullMethod string) (trace.Span, context.Context) {
+	ctx, span := t.tracer.Start(ctx, "client+"+fullMethod, trace.WithSpanKind(trace.SpanKindClient))
+	md := grpcx.OutgoingMD(ctx)
+	t.pg.Inject(ctx, grpcx.MDCarrier(md))
+	return span, metadata.NewOutgoingContext(ctx, md)
+}
+
+func finishGRPCSpan(span trace.Span, info *grpcx.Info) {
+	span.SetAttributes(
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", info.Service),
+		attribute.String("rpc.method", info.Method),
+		attribute.String("rpc.grpc.status_code", info.Code),
+		attribute.Int64("rpc.messages_sent", info.Sent),
+		attribute.Int64("rpc.messages_received", info.Received),
+	)
+	if info.Err != nil {
+		span.RecordError(info.Err)
+		span.SetStatus(codes.Error, info.Err.Error())
+	}
+	span.End()
+}
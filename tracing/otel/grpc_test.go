@@ -0,0 +1,93 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpctest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	for _, tc := range grpctest.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			exp := tracetest.NewInMemoryExporter()
+			tr, err := New(&Config{
+				Exporters: []sdktrace.SpanExporter{exp},
+				Props:     []propagation.TextMapPropagator{propagation.TraceContext{}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tr.Finalize(context.Background())
+			srv, cli := grpctest.Start(t,
+				[]grpc.ServerOption{
+					grpc.UnaryInterceptor(tr.UnaryServerInterceptor()),
+					grpc.StreamInterceptor(tr.StreamServerInterceptor()),
+				},
+				[]grpc.DialOption{
+					grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor()),
+					grpc.WithStreamInterceptor(tr.StreamClientInterceptor()),
+				},
+			)
+			cli.Call(t, context.Background(), tc)
+			if err := tr.tp.ForceFlush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(srv.MD()[0].Get("traceparent")) == 0 {
+				t.Error("trace context must be propagated in the metadata")
+			}
+			spans := exp.GetSpans()
+			server, ok := findSpan(spans, "server+"+tc.FullMethod)
+			if !ok {
+				t.Fatal("server span not found")
+			}
+			client, ok := findSpan(spans, "client+"+tc.FullMethod)
+			if !ok {
+				t.Fatal("client span not found")
+			}
+			if server.Parent.SpanID() != client.SpanContext.SpanID() || server.SpanContext.TraceID() != client.SpanContext.TraceID() {
+				t.Error("server span must be the child of the client span")
+			}
+			checkGRPCSpan(t, server, tc, tc.ServerSent, tc.ServerReceived)
+			checkGRPCSpan(t, client, tc, tc.ClientSent, tc.ClientReceived)
+		})
+	}
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, s := range spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func checkGRPCSpan(t *testing.T, s tracetest.SpanStub, tc grpctest.Case, sent, received int64) {
+	t.Helper()
+	want := attribute.NewSet(
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", grpctest.Service),
+		attribute.String("rpc.method", tc.Method),
+		attribute.String("rpc.grpc.status_code", tc.Code.String()),
+		attribute.Int64("rpc.messages_sent", sent),
+		attribute.Int64("rpc.messages_received", received),
+	)
+	got := attribute.NewSet(s.Attributes...)
+	for _, kv := range want.ToSlice() {
+		if v, ok := got.Value(kv.Key); !ok || v != kv.Value {
+			t.Errorf("%s: want %s=%s, got %s", s.Name, kv.Key, kv.Value.Emit(), v.Emit())
+		}
+	}
+	if (s.Status.Code == codes.Error) != (tc.Code != grpccodes.OK) {
+		t.Errorf("%s: unexpected status %v", s.Name, s.Status)
+	}
+}
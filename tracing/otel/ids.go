@@ -0,0 +1,79 @@
+package otel
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ sdktrace.IDGenerator = &idGenerator{}
+)
+
+// idGenerator generates random ids.
+// Ids saved in the context with [tracing.ContextWithSpanIDs]
+// are used if found so that spans share ids with other tracers.
+type idGenerator struct{}
+
+func (g *idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		return ids.TraceID, ids.SpanID
+	}
+	tid := trace.TraceID{}
+	for !tid.IsValid() {
+		binary.BigEndian.PutUint64(tid[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(tid[8:], rand.Uint64())
+	}
+	return tid, g.NewSpanID(ctx, tid)
+}
+
+func (g *idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok && ids.TraceID == traceID {
+		return ids.SpanID
+	}
+	sid := trace.SpanID{}
+	for !sid.IsValid() {
+		binary.BigEndian.PutUint64(sid[:], rand.Uint64())
+	}
+	return sid
+}
+
+// joinContext returns a context to start a span with the ids.
+// The parent of the ids is set as a remote parent.
+func joinContext(ctx context.Context, ids tracing.SpanIDs) context.Context {
+	if ids.ParentID == [8]byte{} {
+		return trace.ContextWithSpanContext(ctx, trace.SpanContext{}) // Root span.
+	}
+	var flags trace.TraceFlags
+	if ids.Sampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    ids.TraceID,
+		SpanID:     ids.ParentID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+// SpanIDs returns the ids of the current span in the ctx.
+func (t *Tracer) SpanIDs(ctx context.Context) (tracing.SpanIDs, bool) {
+	span := trace.SpanFromContext(ctx)
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return tracing.SpanIDs{}, false
+	}
+	ids := tracing.SpanIDs{
+		TraceID: sc.TraceID(),
+		SpanID:  sc.SpanID(),
+		Sampled: sc.IsSampled(),
+	}
+	if ro, ok := span.(sdktrace.ReadOnlySpan); ok {
+		ids.ParentID = ro.Parent().SpanID()
+	}
+	return ids, true
+}
@@ -0,0 +1,109 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMulti_sampling(t *testing.T) {
+	never := 0.0
+	testCases := map[string]struct {
+		primary     sdktrace.Sampler
+		other       *Config
+		traceparent string
+		sampled     bool
+	}{
+		"unsampled root": {
+			primary: sdktrace.NeverSample(),
+			other:   &Config{},
+		},
+		"sampled root": {
+			primary: sdktrace.AlwaysSample(),
+			other:   &Config{ProviderOpts: []sdktrace.TracerProviderOption{sdktrace.WithSampler(JoinSampler(sdktrace.NeverSample()))}},
+			sampled: true,
+		},
+		"unsampled root with rules": {
+			primary: sdktrace.NeverSample(),
+			other:   &Config{Sampling: &sampling.Config{}},
+		},
+		"sampled root with rules": {
+			primary: sdktrace.AlwaysSample(),
+			other:   &Config{Sampling: &sampling.Config{DefaultRatio: &never}},
+			sampled: true,
+		},
+		"unsampled remote parent": {
+			primary:     sdktrace.ParentBased(sdktrace.AlwaysSample()),
+			other:       &Config{Sampling: &sampling.Config{}},
+			traceparent: "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-00",
+		},
+		"sampled remote parent": {
+			primary:     sdktrace.ParentBased(sdktrace.AlwaysSample()),
+			other:       &Config{Sampling: &sampling.Config{DefaultRatio: &never}},
+			traceparent: "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01",
+			sampled:     true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			primaryRec := tracetest.NewSpanRecorder()
+			primary, err := New(&Config{ProviderOpts: []sdktrace.TracerProviderOption{
+				sdktrace.WithSampler(tc.primary),
+				sdktrace.WithSpanProcessor(primaryRec),
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer primary.Finalize(context.Background())
+			otherRec := tracetest.NewSpanRecorder()
+			tc.other.ProviderOpts = append(tc.other.ProviderOpts, sdktrace.WithSpanProcessor(otherRec))
+			other, err := New(tc.other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer other.Finalize(context.Background())
+			m, err := tracing.NewMulti(primary, other)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids tracing.SpanIDs
+			h := m.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ids, _ = primary.SpanIDs(r.Context())
+				_, finish := m.Trace(r.Context(), "child", nil)
+				finish()
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.traceparent != "" {
+				r.Header.Set("Traceparent", tc.traceparent)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if ids.Sampled != tc.sampled {
+				t.Fatalf("primary sampled: want %v, got %v", tc.sampled, ids.Sampled)
+			}
+			want := 0
+			if tc.sampled {
+				want = 2
+			}
+			if got := len(primaryRec.Ended()); got != want {
+				t.Errorf("primary spans: want %d, got %d", want, got)
+			}
+			spans := otherRec.Ended()
+			if len(spans) != want {
+				t.Fatalf("other spans: want %d, got %d", want, len(spans))
+			}
+			for _, s := range spans {
+				if s.SpanContext().TraceID() != ids.TraceID || !s.SpanContext().IsSampled() {
+					t.Errorf("span %q: unexpected span context %v", s.Name(), s.SpanContext())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// newMonitoredProcessor returns a batch span processor that
+// exports spans to the exp and counts queued spans in the m.
+// The exp should record the export results to the m.
+// Spans are dropped by this processor before the internal queue
+// of the batch span processor overflows so that all drops are recorded.
+func newMonitoredProcessor(exp sdktrace.SpanExporter, m *health.Monitor, opts ...sdktrace.BatchSpanProcessorOption) sdktrace.SpanProcessor {
+	o := sdktrace.BatchSpanProcessorOptions{
+		MaxQueueSize: sdktrace.DefaultMaxQueueSize,
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTEL_BSP_MAX_QUEUE_SIZE")); err == nil && v > 0 {
+		o.MaxQueueSize = v
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// Explicitly set the queue size used for the admission control
+	// to make sure the batch processor uses the same value.
+	opts = append(opts, sdktrace.WithMaxQueueSize(o.MaxQueueSize))
+	return &monitoredProcessor{
+		SpanProcessor: sdktrace.NewBatchSpanProcessor(exp, opts...),
+		monitor:       m,
+		limit:         o.MaxQueueSize,
+		blocking:      o.BlockOnQueueFull,
+	}
+}
+
+// monitoredProcessor counts spans passed to the batch span processor.
+// The number of queued spans is the number of spans passed to
+// the batch span processor which have not been exported yet.
+type monitoredProcessor struct {
+	sdktrace.SpanProcessor
+	monitor  *health.Monitor
+	limit    int
+	blocking bool
+}
+
+func (p *monitoredProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return // Batch span processor ignores not sampled spans.
+	}
+	if p.blocking {
+		p.monitor.Enqueued(1)
+	} else if !p.monitor.TryEnqueue(1, p.limit) {
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// monitoredExporter records export results to the monitor.
+// Export errors are passed to the monitor and are not returned
+// to the batch span processor so that they are not handled
+// by the global OpenTelemetry error handler.
+type monitoredExporter struct {
+	next    sdktrace.SpanExporter
+	monitor *health.Monitor
+	// persistent, if true, returns export errors
+	// to the [PersistentExporter] so that the spans
+	// are persisted instead of recorded as failed.
+	persistent bool
+}
+
+func (e *monitoredExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.next.ExportSpans(ctx, spans)
+	e.monitor.ObserveLatency(time.Since(start))
+	switch {
+	case err != nil && e.persistent:
+		e.monitor.Error(err)
+		return err
+	case err != nil:
+		e.monitor.Failed(len(spans), err)
+	case diskqueue.IsReplay(ctx):
+		e.monitor.Replayed(len(spans), nil)
+	default:
+		e.monitor.Exported(len(spans))
+	}
+	return nil
+}
+
+func (e *monitoredExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
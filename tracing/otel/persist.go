@@ -0,0 +1,382 @@
+package otel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ sdktrace.SpanExporter = &PersistentExporter{}
+)
+
+// PersistentExporter is the span exporter that stores batches
+// to a disk queue when the underlying exporter failed
+// and replays them in background until succeeded.
+// New batches are also stored while the queue is not empty
+// so that spans are exported in order.
+// Batches that could not be stored are dropped and the error is returned.
+// Use [NewPersistentExporter] or the [Config.Persistence] to create a new instance.
+// Exporters created by the Config.Persistence are monitored
+// by the [Tracer.Monitor] while the collector is not available.
+type PersistentExporter struct {
+	next    sdktrace.SpanExporter
+	queue   *diskqueue.Queue
+	onError func(error)
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+	// monitor records persisted and failed spans.
+	// Export results are recorded by the next. It may be nil.
+	monitor *health.Monitor
+}
+
+// NewPersistentExporter returns a new span exporter that
+// persists batches failed to be exported by the exp.
+// Returned exporter can be used in the [Config.Exporters].
+// A background goroutine replays persisted batches until
+// the exporter is shut down.
+func NewPersistentExporter(exp sdktrace.SpanExporter, c *diskqueue.Config) (*PersistentExporter, error) {
+	return newPersistentExporter(exp, c, nil)
+}
+
+// newPersistentExporter returns a new [PersistentExporter]
+// monitored by the m. The exp is wrapped so that the export
+// results are recorded to the m if the m is not nil.
+func newPersistentExporter(exp sdktrace.SpanExporter, c *diskqueue.Config, m *health.Monitor) (*PersistentExporter, error) {
+	if m != nil {
+		exp = &monitoredExporter{next: exp, monitor: m, persistent: true}
+	}
+	q, err := diskqueue.Open(c)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &PersistentExporter{
+		next:    exp,
+		queue:   q,
+		onError: c.ErrorHandler,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		monitor: m,
+	}
+	go func() {
+		defer close(e.done)
+		q.Replay(ctx, e.replay)
+	}()
+	return e, nil
+}
+
+// Queue returns the disk queue of the exporter.
+func (e *PersistentExporter) Queue() *diskqueue.Queue {
+	return e.queue
+}
+
+func (e *PersistentExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	if e.queue.Len() == 0 {
+		err := e.next.ExportSpans(ctx, spans)
+		if err == nil {
+			return nil
+		}
+		if e.onError != nil {
+			e.onError(fmt.Errorf("otel: export failed and spans are persisted: %w", err))
+		}
+	}
+	b, err := marshalSpans(spans)
+	if err == nil {
+		err = e.queue.Push(b)
+	}
+	if err != nil {
+		err = fmt.Errorf("otel: failed to persist spans: %w", err)
+		if e.monitor != nil {
+			e.monitor.Failed(len(spans), err)
+			return nil // Handled by the monitor.
+		}
+		return err
+	}
+	if e.monitor != nil {
+		e.monitor.Persisted(len(spans))
+	}
+	return nil
+}
+
+func (e *PersistentExporter) replay(ctx context.Context, b []byte) error {
+	spans, err := unmarshalSpans(b)
+	if err != nil {
+		return nil // Broken entry. Discard it.
+	}
+	return e.next.ExportSpans(ctx, spans)
+}
+
+// Shutdown stops replaying and shuts down the underlying exporter.
+// Batches which have not been replayed are kept in the disk
+// and will be replayed by the next exporter which uses the same directory.
+func (e *PersistentExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(e.cancel)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.next.Shutdown(ctx)
+}
+
+// jsonSpan is the JSON representation of spans stored in the disk.
+type jsonSpan struct {
+	Name              string          `json:"name"`
+	SpanContext       jsonSpanContext `json:"sc"`
+	Parent            jsonSpanContext `json:"parent"`
+	SpanKind          int             `json:"kind"`
+	StartTime         time.Time       `json:"start"`
+	EndTime           time.Time       `json:"end"`
+	Attributes        []jsonAttribute `json:"attrs,omitempty"`
+	Events            []jsonEvent     `json:"events,omitempty"`
+	Links             []jsonLink      `json:"links,omitempty"`
+	StatusCode        uint32          `json:"statusCode"`
+	StatusDescription string          `json:"statusDesc,omitempty"`
+	DroppedAttributes int             `json:"droppedAttrs,omitempty"`
+	DroppedEvents     int             `json:"droppedEvents,omitempty"`
+	DroppedLinks      int             `json:"droppedLinks,omitempty"`
+	ChildSpanCount    int             `json:"children,omitempty"`
+	Resource          []jsonAttribute `json:"resource,omitempty"`
+	ResourceSchemaURL string          `json:"resourceSchema,omitempty"`
+	ScopeName         string          `json:"scope"`
+	ScopeVersion      string          `json:"scopeVersion,omitempty"`
+	ScopeSchemaURL    string          `json:"scopeSchema,omitempty"`
+	ScopeAttributes   []jsonAttribute `json:"scopeAttrs,omitempty"`
+}
+
+type jsonSpanContext struct {
+	TraceID    string `json:"traceID,omitempty"`
+	SpanID     string `json:"spanID,omitempty"`
+	TraceFlags byte   `json:"flags,omitempty"`
+	TraceState string `json:"state,omitempty"`
+	Remote     bool   `json:"remote,omitempty"`
+}
+
+type jsonEvent struct {
+	Name              string          `json:"name"`
+	Time              time.Time       `json:"time"`
+	Attributes        []jsonAttribute `json:"attrs,omitempty"`
+	DroppedAttributes int             `json:"droppedAttrs,omitempty"`
+}
+
+type jsonLink struct {
+	SpanContext       jsonSpanContext `json:"sc"`
+	Attributes        []jsonAttribute `json:"attrs,omitempty"`
+	DroppedAttributes int             `json:"droppedAttrs,omitempty"`
+}
+
+type jsonAttribute struct {
+	Key   string          `json:"k"`
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+func marshalSpans(spans []sdktrace.ReadOnlySpan) ([]byte, error) {
+	js := make([]jsonSpan, 0, len(spans))
+	for _, s := range spans {
+		scope := s.InstrumentationScope()
+		j := jsonSpan{
+			Name:              s.Name(),
+			SpanContext:       toJSONSpanContext(s.SpanContext()),
+			Parent:            toJSONSpanContext(s.Parent()),
+			SpanKind:          int(s.SpanKind()),
+			StartTime:         s.StartTime(),
+			EndTime:           s.EndTime(),
+			Attributes:        toJSONAttributes(s.Attributes()),
+			StatusCode:        uint32(s.Status().Code),
+			StatusDescription: s.Status().Description,
+			DroppedAttributes: s.DroppedAttributes(),
+			DroppedEvents:     s.DroppedEvents(),
+			DroppedLinks:      s.DroppedLinks(),
+			ChildSpanCount:    s.ChildSpanCount(),
+			ScopeName:         scope.Name,
+			ScopeVersion:      scope.Version,
+			ScopeSchemaURL:    scope.SchemaURL,
+			ScopeAttributes:   toJSONAttributes(scope.Attributes.ToSlice()),
+		}
+		if r := s.Resource(); r != nil {
+			j.Resource = toJSONAttributes(r.Attributes())
+			j.ResourceSchemaURL = r.SchemaURL()
+		}
+		for _, e := range s.Events() {
+			j.Events = append(j.Events, jsonEvent{
+				Name:              e.Name,
+				Time:              e.Time,
+				Attributes:        toJSONAttributes(e.Attributes),
+				DroppedAttributes: e.DroppedAttributeCount,
+			})
+		}
+		for _, l := range s.Links() {
+			j.Links = append(j.Links, jsonLink{
+				SpanContext:       toJSONSpanContext(l.SpanContext),
+				Attributes:        toJSONAttributes(l.Attributes),
+				DroppedAttributes: l.DroppedAttributeCount,
+			})
+		}
+		js = append(js, j)
+	}
+	return json.Marshal(js)
+}
+
+func unmarshalSpans(b []byte) ([]sdktrace.ReadOnlySpan, error) {
+	var js []jsonSpan
+	if err := json.Unmarshal(b, &js); err != nil {
+		return nil, err
+	}
+	spans := make([]sdktrace.ReadOnlySpan, 0, len(js))
+	for _, j := range js {
+		s := tracetest.SpanStub{
+			Name:              j.Name,
+			SpanContext:       fromJSONSpanContext(j.SpanContext),
+			Parent:            fromJSONSpanContext(j.Parent),
+			SpanKind:          trace.SpanKind(j.SpanKind),
+			StartTime:         j.StartTime,
+			EndTime:           j.EndTime,
+			Attributes:        fromJSONAttributes(j.Attributes),
+			Status:            sdktrace.Status{Code: codes.Code(j.StatusCode), Description: j.StatusDescription},
+			DroppedAttributes: j.DroppedAttributes,
+			DroppedEvents:     j.DroppedEvents,
+			DroppedLinks:      j.DroppedLinks,
+			ChildSpanCount:    j.ChildSpanCount,
+			Resource:          resource.NewWithAttributes(j.ResourceSchemaURL, fromJSONAttributes(j.Resource)...),
+			InstrumentationScope: instrumentation.Scope{
+				Name:       j.ScopeName,
+				Version:    j.ScopeVersion,
+				SchemaURL:  j.ScopeSchemaURL,
+				Attributes: attribute.NewSet(fromJSONAttributes(j.ScopeAttributes)...),
+			},
+		}
+		for _, e := range j.Events {
+			s.Events = append(s.Events, sdktrace.Event{
+				Name:                  e.Name,
+				Time:                  e.Time,
+				Attributes:            fromJSONAttributes(e.Attributes),
+				DroppedAttributeCount: e.DroppedAttributes,
+			})
+		}
+		for _, l := range j.Links {
+			s.Links = append(s.Links, sdktrace.Link{
+				SpanContext:           fromJSONSpanContext(l.SpanContext),
+				Attributes:            fromJSONAttributes(l.Attributes),
+				DroppedAttributeCount: l.DroppedAttributes,
+			})
+		}
+		spans = append(spans, s.Snapshot())
+	}
+	return spans, nil
+}
+
+func toJSONSpanContext(sc trace.SpanContext) jsonSpanContext {
+	if !sc.IsValid() {
+		return jsonSpanContext{}
+	}
+	return jsonSpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		TraceFlags: byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+		Remote:     sc.IsRemote(),
+	}
+}
+
+func fromJSONSpanContext(j jsonSpanContext) trace.SpanContext {
+	tid, err := trace.TraceIDFromHex(j.TraceID)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+	sid, err := trace.SpanIDFromHex(j.SpanID)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+	ts, _ := trace.ParseTraceState(j.TraceState)
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.TraceFlags(j.TraceFlags),
+		TraceState: ts,
+		Remote:     j.Remote,
+	})
+}
+
+func toJSONAttributes(kvs []attribute.KeyValue) []jsonAttribute {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make([]jsonAttribute, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := json.Marshal(kv.Value.AsInterface())
+		if err != nil {
+			continue // Invalid values such as NaN.
+		}
+		attrs = append(attrs, jsonAttribute{Key: string(kv.Key), Type: kv.Value.Type().String(), Value: v})
+	}
+	return attrs
+}
+
+func fromJSONAttributes(attrs []jsonAttribute) []attribute.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var kv attribute.KeyValue
+		var err error
+		switch a.Type {
+		case attribute.BOOL.String():
+			var v bool
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.Bool(a.Key, v)
+		case attribute.INT64.String():
+			var v int64
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.Int64(a.Key, v)
+		case attribute.FLOAT64.String():
+			var v float64
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.Float64(a.Key, v)
+		case attribute.STRING.String():
+			var v string
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.String(a.Key, v)
+		case attribute.BOOLSLICE.String():
+			var v []bool
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.BoolSlice(a.Key, v)
+		case attribute.INT64SLICE.String():
+			var v []int64
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.Int64Slice(a.Key, v)
+		case attribute.FLOAT64SLICE.String():
+			var v []float64
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.Float64Slice(a.Key, v)
+		case attribute.STRINGSLICE.String():
+			var v []string
+			err = json.Unmarshal(a.Value, &v)
+			kv = attribute.StringSlice(a.Key, v)
+		default:
+			continue
+		}
+		if err == nil {
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,288 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// flakyExporter is the exporter which fails while it is down.
+type flakyExporter struct {
+	down     atomic.Bool
+	shutdown atomic.Bool
+	mu       sync.Mutex
+	got      map[trace.SpanID]int
+	spans    []sdktrace.ReadOnlySpan
+}
+
+func (e *flakyExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.down.Load() {
+		return errors.New("collector is down")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		e.got[s.SpanContext().SpanID()]++
+	}
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *flakyExporter) Shutdown(_ context.Context) error {
+	e.shutdown.Store(true)
+	return nil
+}
+
+func (e *flakyExporter) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.spans)
+}
+
+func TestPersistentExporter_recover(t *testing.T) {
+	exp := &flakyExporter{got: map[trace.SpanID]int{}}
+	exp.down.Store(true)
+	tr, err := New(&Config{
+		Exporters: []sdktrace.SpanExporter{exp},
+		Persistence: &diskqueue.Config{
+			Dir:        t.TempDir(),
+			Fsync:      diskqueue.FsyncNever,
+			MinBackoff: 10 * time.Millisecond,
+			MaxBackoff: 10 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Finalize(context.Background())
+
+	ctx := context.Background()
+	want := map[trace.SpanID]bool{}
+	for range 3 { // Multiple batches while the collector is down.
+		for range 10 {
+			_, span := tr.tracer.Start(ctx, "test")
+			want[span.SpanContext().SpanID()] = true
+			span.End()
+		}
+		if err := tr.tp.ForceFlush(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr.Health().Healthy() {
+		t.Error("exporter must be unhealthy while the collector is down")
+	}
+	if tr.Monitor().QueueLength() != 0 {
+		t.Errorf("persisted spans must be removed from the queue: %d", tr.Monitor().QueueLength())
+	}
+
+	exp.down.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for exp.count() < len(want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond) // Make sure nothing is sent twice.
+
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	if len(exp.spans) != len(want) {
+		t.Errorf("want %d spans, got %d", len(want), len(exp.spans))
+	}
+	for id := range want {
+		if n := exp.got[id]; n != 1 {
+			t.Errorf("span %s delivered %d times", id, n)
+		}
+	}
+	if !tr.Health().Healthy() {
+		t.Error("exporter must be healthy after recovered")
+	}
+	if tr.Monitor().QueueLength() != 0 {
+		t.Errorf("unexpected queue length %d", tr.Monitor().QueueLength())
+	}
+}
+
+// stubCollector is the OTLP/HTTP collector which
+// replies 503 while it is down and counts the received requests.
+type stubCollector struct {
+	down     atomic.Bool
+	requests atomic.Int32
+}
+
+func (c *stubCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	if c.down.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	c.requests.Add(1)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestPersistentExporter_restart(t *testing.T) {
+	testCases := map[string]struct {
+		maxAge   time.Duration
+		exported int
+	}{
+		"replayed after restart": {0, 10},
+		"expired before restart": {time.Nanosecond, 0},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			col := &stubCollector{}
+			col.down.Store(true)
+			svr := httptest.NewServer(col)
+			defer svr.Close()
+			dir := t.TempDir()
+			newTracer := func(maxAge time.Duration) *Tracer {
+				exp, err := otlptracehttp.New(context.Background(),
+					otlptracehttp.WithEndpointURL(svr.URL+"/v1/traces"),
+					otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tr, err := New(&Config{
+					Exporters: []sdktrace.SpanExporter{exp},
+					Persistence: &diskqueue.Config{
+						Dir:        dir,
+						MaxAge:     maxAge,
+						Fsync:      diskqueue.FsyncNever,
+						MinBackoff: 10 * time.Millisecond,
+						MaxBackoff: 10 * time.Millisecond,
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tr
+			}
+
+			// Spans are persisted while the collector is down.
+			tr := newTracer(0)
+			for range 10 {
+				_, span := tr.tracer.Start(context.Background(), "test")
+				span.End()
+			}
+			if err := tr.Finalize(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if col.requests.Load() != 0 {
+				t.Fatal("collector must not receive spans while down")
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+			if len(files) == 0 {
+				t.Fatal("spans must be persisted")
+			}
+
+			// Persisted spans are replayed by the next process.
+			col.down.Store(false)
+			tr = newTracer(tc.maxAge)
+			defer tr.Finalize(context.Background())
+			entries := func() int {
+				files, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+				return len(files)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for entries() > 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if n := entries(); n != 0 {
+				t.Fatalf("want no entries, got %d", n)
+			}
+			if tc.exported > 0 && col.requests.Load() == 0 {
+				t.Error("persisted spans must be sent to the collector")
+			}
+			if tc.exported == 0 && col.requests.Load() != 0 {
+				t.Error("expired spans must not be sent to the collector")
+			}
+			want := `
+# HELP observability_exporter_exported_total Number of exported items
+# TYPE observability_exporter_exported_total counter
+observability_exporter_exported_total{exporter="otel",signal="spans"} ` + strconv.Itoa(tc.exported) + `
+`
+			if err := testutil.CollectAndCompare(tr.Monitor(), strings.NewReader(want), "observability_exporter_exported_total"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPersistentExporter_full(t *testing.T) {
+	exp := &flakyExporter{got: map[trace.SpanID]int{}}
+	exp.down.Store(true)
+	pe, err := NewPersistentExporter(exp, &diskqueue.Config{Dir: t.TempDir(), MaxBytes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pe.Shutdown(context.Background())
+	tp := sdktrace.NewTracerProvider()
+	_, span := tp.Tracer("test").Start(context.Background(), "test")
+	span.End()
+	err = pe.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)})
+	if !errors.Is(err, diskqueue.ErrFull) {
+		t.Errorf("want ErrFull, got %v", err)
+	}
+}
+
+func TestMarshalSpans(t *testing.T) {
+	exp := &flakyExporter{got: map[trace.SpanID]int{}}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	_, span := tp.Tracer("test").Start(context.Background(), "test", trace.WithSpanKind(trace.SpanKindServer))
+	span.SetAttributes(
+		attribute.String("str", "foo"),
+		attribute.Int("int", 1),
+		attribute.Bool("bool", true),
+		attribute.StringSlice("strs", []string{"a", "b"}),
+	)
+	span.AddEvent("event", trace.WithAttributes(attribute.Float64("float", 1.5)))
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+
+	in := exp.spans[0]
+	b, err := marshalSpans(exp.spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans, err := unmarshalSpans(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := spans[0]
+	if out.Name() != in.Name() || out.SpanKind() != in.SpanKind() || out.Status() != in.Status() {
+		t.Errorf("unexpected span %s %s %v", out.Name(), out.SpanKind(), out.Status())
+	}
+	if out.SpanContext().SpanID() != in.SpanContext().SpanID() || out.SpanContext().TraceID() != in.SpanContext().TraceID() {
+		t.Error("span context not restored")
+	}
+	if !out.StartTime().Equal(in.StartTime()) || !out.EndTime().Equal(in.EndTime()) {
+		t.Error("time not restored")
+	}
+	want, got := attribute.NewSet(in.Attributes()...), attribute.NewSet(out.Attributes()...)
+	if !got.Equals(&want) {
+		t.Errorf("unexpected attributes %v", out.Attributes())
+	}
+	if len(out.Events()) != 1 || out.Events()[0].Name != "event" || len(out.Events()[0].Attributes) != 1 {
+		t.Errorf("unexpected events %v", out.Events())
+	}
+	if out.InstrumentationScope().Name != "test" {
+		t.Errorf("unexpected scope %v", out.InstrumentationScope())
+	}
+}
@@ -0,0 +1,65 @@
+package otel
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ tracing.Propagator    = &Tracer{}
+	_ tracing.MessageTracer = &Tracer{}
+)
+
+// Inject injects the trace context of the current span in the ctx
+// into the carrier using the configured propagators.
+func (t *Tracer) Inject(ctx context.Context, carrier tracing.Carrier) {
+	t.pg.Inject(ctx, carrier)
+}
+
+// Extract extracts the trace context from the carrier
+// using the configured propagators.
+func (t *Tracer) Extract(ctx context.Context, carrier tracing.Carrier) context.Context {
+	return t.pg.Extract(ctx, carrier)
+}
+
+// StartProducer starts a producer span and injects
+// its trace context into the carrier.
+func (t *Tracer) StartProducer(ctx context.Context, name string, carrier tracing.Carrier, tags map[string]string) (spanCtx context.Context, finish func()) {
+	spanCtx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attributes(tags)...),
+	)
+	t.pg.Inject(spanCtx, carrier)
+	return spanCtx, func() { span.End() }
+}
+
+// StartConsumer starts a consumer span for the message
+// which has the carrier as its headers.
+// The producer span is linked when the span has another parent.
+func (t *Tracer) StartConsumer(ctx context.Context, name string, carrier tracing.Carrier, tags map[string]string) (spanCtx context.Context, finish func()) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attributes(tags)...),
+	}
+	remote := trace.SpanContextFromContext(t.pg.Extract(context.Background(), carrier))
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		if remote.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: remote}))
+		}
+	} else {
+		ctx = t.pg.Extract(ctx, carrier)
+	}
+	spanCtx, span := t.tracer.Start(ctx, name, opts...)
+	return spanCtx, func() { span.End() }
+}
+
+func attributes(tags map[string]string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs
+}
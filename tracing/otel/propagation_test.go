@@ -0,0 +1,138 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// msgHeader is the header type of a message queue client.
+type msgHeader struct {
+	key   string
+	value []byte
+}
+
+func newRecordingTracer(t *testing.T) (*Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tr, err := New(&Config{ProviderOpts: []sdktrace.TracerProviderOption{sdktrace.WithSpanProcessor(rec)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tr.Finalize(context.Background()) })
+	return tr, rec
+}
+
+func TestTracer_InjectExtract(t *testing.T) {
+	var headers []msgHeader
+	testCases := map[string]tracing.Carrier{
+		"map":    tracing.MapCarrier{},
+		"header": tracing.HeaderCarrier(http.Header{}),
+		"bytes":  &tracing.ByteHeaders{},
+		"slice": &tracing.SliceCarrier[msgHeader]{
+			Headers: &headers,
+			Key:     func(h msgHeader) string { return h.key },
+			Value:   func(h msgHeader) []byte { return h.value },
+			New:     func(k string, v []byte) msgHeader { return msgHeader{key: k, value: v} },
+		},
+	}
+	tr, _ := newRecordingTracer(t)
+	m, _ := baggage.NewMember("tenant", "a")
+	b, _ := baggage.New(m)
+	ctx, span := tr.tracer.Start(baggage.ContextWithBaggage(context.Background(), b), "parent")
+	defer span.End()
+	for name, carrier := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tr.Inject(ctx, carrier)
+			got := tr.Extract(context.Background(), carrier)
+			sc := trace.SpanContextFromContext(got)
+			if !sc.IsRemote() || !sc.Equal(span.SpanContext().WithRemote(true)) {
+				t.Errorf("want %v, got %v", span.SpanContext(), sc)
+			}
+			if v := baggage.FromContext(got).Member("tenant").Value(); v != "a" {
+				t.Errorf("want baggage a, got %q", v)
+			}
+		})
+	}
+}
+
+func TestTracer_StartConsumer(t *testing.T) {
+	testCases := map[string]struct {
+		produce bool
+		parent  bool
+	}{
+		"child of producer":  {produce: true},
+		"link to producer":   {produce: true, parent: true},
+		"no producer":        {},
+		"parent no producer": {parent: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tr, rec := newRecordingTracer(t)
+			carrier := &tracing.ByteHeaders{}
+			var producer trace.SpanContext
+			if tc.produce {
+				ctx, finish := tr.StartProducer(context.Background(), "send", carrier, map[string]string{"topic": "orders"})
+				producer = trace.SpanContextFromContext(ctx)
+				finish()
+			}
+			ctx := context.Background()
+			var parent trace.SpanContext
+			if tc.parent {
+				var span trace.Span
+				ctx, span = tr.tracer.Start(ctx, "poll")
+				defer span.End()
+				parent = span.SpanContext()
+			}
+			_, finish := tr.StartConsumer(ctx, "receive", carrier, map[string]string{"topic": "orders"})
+			finish()
+
+			spans := rec.Ended()
+			s := spans[len(spans)-1]
+			if s.SpanKind() != trace.SpanKindConsumer || !hasAttribute(s, attribute.String("topic", "orders")) {
+				t.Errorf("unexpected consumer span %v %v", s.SpanKind(), s.Attributes())
+			}
+			if tc.produce && (spans[0].SpanKind() != trace.SpanKindProducer || carrier.Get("traceparent") == "") {
+				t.Errorf("producer must inject its trace context: %v", carrier)
+			}
+			switch {
+			case tc.parent:
+				if s.Parent().SpanID() != parent.SpanID() || s.Parent().IsRemote() {
+					t.Errorf("want the local parent %v, got %v", parent, s.Parent())
+				}
+			case tc.produce:
+				if s.Parent().SpanID() != producer.SpanID() || !s.Parent().IsRemote() {
+					t.Errorf("want the producer parent %v, got %v", producer, s.Parent())
+				}
+			default:
+				if s.Parent().IsValid() {
+					t.Errorf("want a root span, got %v", s.Parent())
+				}
+			}
+			links := s.Links()
+			if tc.produce && tc.parent {
+				if len(links) != 1 || links[0].SpanContext.SpanID() != producer.SpanID() {
+					t.Errorf("want a link to the producer, got %v", links)
+				}
+			} else if len(links) != 0 {
+				t.Errorf("want no links, got %v", links)
+			}
+		})
+	}
+}
+
+func hasAttribute(s sdktrace.ReadOnlySpan, kv attribute.KeyValue) bool {
+	for _, a := range s.Attributes() {
+		if a == kv {
+			return true
+		}
+	}
+	return false
+}
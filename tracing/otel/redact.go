@@ -0,0 +1,124 @@
+package otel
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aileron-projects/aileron-observability/redact"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	_ sdktrace.SpanProcessor = &RedactingProcessor{}
+	_ sdktrace.ReadOnlySpan  = &redactedSpan{}
+)
+
+// RedactingProcessor redacts the finished spans with the [redact.Redactor]
+// and passes them to the next span processors.
+// Span names are redacted with [redact.Redactor.Path] and string
+// attributes of spans, events and links are redacted with
+// [redact.Redactor.Attribute]. Event names and the status
+// description are redacted with [redact.Redactor.String].
+// Resource attributes are not redacted.
+// Use [NewRedactingProcessor] to create a new instance.
+type RedactingProcessor struct {
+	redactor *redact.Redactor
+	next     []sdktrace.SpanProcessor
+}
+
+// NewRedactingProcessor returns a new span processor that
+// redacts the finished spans and passes them to the next.
+func NewRedactingProcessor(r *redact.Redactor, next ...sdktrace.SpanProcessor) *RedactingProcessor {
+	return &RedactingProcessor{redactor: r, next: next}
+}
+
+func (p *RedactingProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	for _, n := range p.next {
+		n.OnStart(ctx, s)
+	}
+}
+
+func (p *RedactingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	rs := &redactedSpan{
+		ReadOnlySpan: s,
+		name:         p.redactor.Path(s.Name()),
+		attrs:        redactAttributes(p.redactor, s.Attributes()),
+		status:       s.Status(),
+	}
+	rs.status.Description, _ = p.redactor.String(rs.status.Description)
+	for _, e := range s.Events() {
+		e.Name, _ = p.redactor.String(e.Name)
+		e.Attributes = redactAttributes(p.redactor, e.Attributes)
+		rs.events = append(rs.events, e)
+	}
+	for _, l := range s.Links() {
+		l.Attributes = redactAttributes(p.redactor, l.Attributes)
+		rs.links = append(rs.links, l)
+	}
+	for _, n := range p.next {
+		n.OnEnd(rs)
+	}
+}
+
+func (p *RedactingProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, n := range p.next {
+		errs = append(errs, n.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *RedactingProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, n := range p.next {
+		errs = append(errs, n.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// redactAttributes returns the redacted copy of the attrs.
+// Attributes of the drop action are removed.
+func redactAttributes(r *redact.Redactor, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(attrs) == 0 {
+		return attrs
+	}
+	out := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		switch kv.Value.Type() {
+		case attribute.STRING:
+			v, ok := r.Attribute(string(kv.Key), kv.Value.AsString())
+			if !ok {
+				continue
+			}
+			kv.Value = attribute.StringValue(v)
+		case attribute.STRINGSLICE:
+			vs := kv.Value.AsStringSlice()
+			kept := vs[:0]
+			for _, v := range vs {
+				if v, ok := r.Attribute(string(kv.Key), v); ok {
+					kept = append(kept, v)
+				}
+			}
+			kv.Value = attribute.StringSliceValue(kept)
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+// redactedSpan is the read only span with the redacted values.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	name   string
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+	links  []sdktrace.Link
+	status sdktrace.Status
+}
+
+func (s *redactedSpan) Name() string                     { return s.name }
+func (s *redactedSpan) Attributes() []attribute.KeyValue { return s.attrs }
+func (s *redactedSpan) Events() []sdktrace.Event         { return s.events }
+func (s *redactedSpan) Links() []sdktrace.Link           { return s.links }
+func (s *redactedSpan) Status() sdktrace.Status          { return s.status }
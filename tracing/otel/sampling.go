@@ -0,0 +1,83 @@
+package otel
+
+import (
+	"encoding/binary"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ sdktrace.Sampler = &ruleSampler{}
+	_ sdktrace.Sampler = &joinSampler{}
+)
+
+// RuleSampler returns a sampler that makes decisions using the s.
+// Rules are applied to the requests saved in the context
+// with [sampling.ContextWithRequest] by the [Tracer.ServerMiddleware].
+// Spans with a local parent follow the parent decision.
+func RuleSampler(s *sampling.Sampler) sdktrace.Sampler {
+	return &ruleSampler{sampler: s}
+}
+
+type ruleSampler struct {
+	sampler *sampling.Sampler
+}
+
+func (s *ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	sampled := false
+	if psc.IsValid() && (!psc.IsRemote() || s.sampler.ParentBased()) {
+		sampled = psc.IsSampled()
+	} else {
+		r := sampling.RequestFromContext(p.ParentContext)
+		sampled = s.sampler.Sample(r, binary.BigEndian.Uint64(p.TraceID[8:16]))
+	}
+	decision := sdktrace.Drop
+	if sampled {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: psc.TraceState(),
+	}
+}
+
+func (s *ruleSampler) Description() string {
+	return "RuleSampler"
+}
+
+// JoinSampler returns a sampler that follows the decisions of the spans
+// started by another tracer of the [tracing.Multi] when the spans are joined.
+// Other spans, including the children of the joined spans, are sampled by the s.
+// [New] uses it to wrap the sampler of the tracer provider
+// except for the one given by [Config.ProviderOpts].
+func JoinSampler(s sdktrace.Sampler) sdktrace.Sampler {
+	return &joinSampler{next: s}
+}
+
+type joinSampler struct {
+	next sdktrace.Sampler
+}
+
+func (s *joinSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	// The ids are consumed after the joined span is started.
+	ids, ok := tracing.SpanIDsFromContext(p.ParentContext)
+	if !ok || ids.TraceID != p.TraceID {
+		return s.next.ShouldSample(p)
+	}
+	decision := sdktrace.Drop
+	if ids.Sampled {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *joinSampler) Description() string {
+	return "JoinSampler{" + s.next.Description() + "}"
+}
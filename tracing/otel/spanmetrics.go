@@ -0,0 +1,93 @@
+package otel
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ sdktrace.SpanProcessor = &SpanMetricsProcessor{}
+)
+
+// SpanMetricsProcessor records the finished spans to the recorders
+// such as the [spanmetrics.Metrics] and the [servicegraph.Graph].
+// The service name is read from the "service.name" resource attribute.
+// Spans without status are recorded as errors
+// if the "http.status_code" attribute is an error status.
+// See [spanmetrics.HTTPStatus].
+// Only the recorded spans are passed to span processors
+// so spans dropped by head samplers are not counted.
+// Use [NewSpanMetricsProcessor] to create a new instance.
+type SpanMetricsProcessor struct {
+	recorders []spanmetrics.Recorder
+}
+
+// NewSpanMetricsProcessor returns a new span processor that
+// records the finished spans to the recorders.
+func NewSpanMetricsProcessor(rs ...spanmetrics.Recorder) *SpanMetricsProcessor {
+	return &SpanMetricsProcessor{recorders: rs}
+}
+
+func (p *SpanMetricsProcessor) OnStart(_ context.Context, _ sdktrace.ReadWriteSpan) {}
+
+func (p *SpanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	service := ""
+	if v, ok := s.Resource().Set().Value(semconv.ServiceNameKey); ok {
+		service = v.AsString()
+	}
+	kind := spanKinds[s.SpanKind()]
+	status := statusCodes[s.Status().Code]
+	attrs := attribute.NewSet(s.Attributes()...)
+	if v, ok := attrs.Value("http.status_code"); ok && s.Status().Code == codes.Unset {
+		status = spanmetrics.HTTPStatus(kind, int(v.AsInt64()))
+	}
+	peer := ""
+	for _, k := range spanmetrics.PeerKeys {
+		if v, ok := attrs.Value(attribute.Key(k)); ok && v.AsString() != "" {
+			peer = v.AsString()
+			break
+		}
+	}
+	span := &spanmetrics.Span{
+		IDs: tracing.SpanIDs{
+			TraceID:  s.SpanContext().TraceID(),
+			SpanID:   s.SpanContext().SpanID(),
+			ParentID: s.Parent().SpanID(),
+			Sampled:  s.SpanContext().IsSampled(),
+		},
+		Service:  service,
+		Name:     s.Name(),
+		Kind:     kind,
+		Status:   status,
+		Duration: s.EndTime().Sub(s.StartTime()),
+		Peer:     peer,
+	}
+	for _, r := range p.recorders {
+		r.Record(context.Background(), span)
+	}
+}
+
+func (p *SpanMetricsProcessor) Shutdown(_ context.Context) error { return nil }
+
+func (p *SpanMetricsProcessor) ForceFlush(_ context.Context) error { return nil }
+
+var spanKinds = map[trace.SpanKind]string{
+	trace.SpanKindInternal: spanmetrics.KindInternal,
+	trace.SpanKindServer:   spanmetrics.KindServer,
+	trace.SpanKindClient:   spanmetrics.KindClient,
+	trace.SpanKindProducer: spanmetrics.KindProducer,
+	trace.SpanKindConsumer: spanmetrics.KindConsumer,
+}
+
+var statusCodes = map[codes.Code]string{
+	codes.Unset: spanmetrics.StatusUnset,
+	codes.Ok:    spanmetrics.StatusOK,
+	codes.Error: spanmetrics.StatusError,
+}
@@ -0,0 +1,386 @@
+package otel
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ sdktrace.SpanProcessor = &TailSamplingProcessor{}
+	_ prometheus.Collector   = &TailSamplingProcessor{}
+)
+
+// TailSamplingPolicy decides whether to keep a trace
+// from all the spans of the trace ended in the decision window.
+// It returns true to keep the trace.
+type TailSamplingPolicy func(spans []sdktrace.ReadOnlySpan) bool
+
+// ErrorPolicy keeps traces which have at least one span with error status.
+func ErrorPolicy() TailSamplingPolicy {
+	return func(spans []sdktrace.ReadOnlySpan) bool {
+		for _, s := range spans {
+			if s.Status().Code == codes.Error {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// LatencyPolicy keeps traces whose duration exceeds the threshold.
+// The duration is the time between the earliest start time
+// and the latest end time of the spans.
+func LatencyPolicy(threshold time.Duration) TailSamplingPolicy {
+	return func(spans []sdktrace.ReadOnlySpan) bool {
+		var start, end time.Time
+		for _, s := range spans {
+			if start.IsZero() || s.StartTime().Before(start) {
+				start = s.StartTime()
+			}
+			if s.EndTime().After(end) {
+				end = s.EndTime()
+			}
+		}
+		return end.Sub(start) > threshold
+	}
+}
+
+// AttributePolicy keeps traces which have at least one span
+// that has any of the given attributes.
+// Both the key and the value must be equal.
+func AttributePolicy(kvs ...attribute.KeyValue) TailSamplingPolicy {
+	return func(spans []sdktrace.ReadOnlySpan) bool {
+		for _, s := range spans {
+			for _, a := range s.Attributes() {
+				for _, kv := range kvs {
+					if a.Key == kv.Key && a.Value == kv.Value {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ProbabilityPolicy keeps the given fraction of traces.
+// The decision is made from the trace id in the same way as
+// [sdktrace.TraceIDRatioBased] so that it is consistent across services.
+// Fractions >= 1 keep all traces and fractions <= 0 keep no traces.
+func ProbabilityPolicy(fraction float64) TailSamplingPolicy {
+	upper := uint64(min(max(fraction, 0), 1) * (1 << 63))
+	return func(spans []sdktrace.ReadOnlySpan) bool {
+		if fraction >= 1 {
+			return true
+		}
+		if len(spans) == 0 {
+			return false
+		}
+		tid := spans[0].SpanContext().TraceID()
+		return binary.BigEndian.Uint64(tid[8:16])>>1 < upper
+	}
+}
+
+// TailSamplingConfig is the configuration for the [TailSamplingProcessor].
+type TailSamplingConfig struct {
+	// DecisionWait is the time to wait for spans of a trace
+	// after the first span of the trace ended.
+	// Sampling decision is made after the DecisionWait.
+	// If zero or negative, 10 seconds is used.
+	DecisionWait time.Duration
+	// MaxTraces is the maximum number of traces buffered.
+	// When exceeded, the oldest trace is evicted by
+	// making the sampling decision early.
+	// If zero or negative, 10000 is used.
+	MaxTraces int
+	// MaxSpansPerTrace is the maximum number of spans
+	// buffered for a trace. Spans exceeding the limit are dropped.
+	// If zero or negative, 1000 is used.
+	MaxSpansPerTrace int
+	// Policies is the list of sampling policies.
+	// A trace is kept when any of the policies keeps it.
+	// At least one policy is required.
+	Policies []TailSamplingPolicy
+}
+
+// TailSamplingProcessor is the span processor that buffers ended spans
+// per trace and forwards them to the next processors
+// only when the trace is sampled by the policies.
+// Spans ended after the decision follow the decision made for the trace.
+// Spans ended after the shutdown are dropped.
+// Note that spans must be sampled by the head sampler
+// to be processed by the TailSamplingProcessor.
+//
+// TailSamplingProcessor implements [prometheus.Collector] and can also
+// be registered to an OpenTelemetry meter with [TailSamplingProcessor.RegisterMeter].
+// Following metrics are exposed.
+//
+//   - observability_tail_sampling_traces_total{decision=sampled|not_sampled}: number of decided traces.
+//   - observability_tail_sampling_traces_evicted_total: number of traces decided early because of MaxTraces.
+//   - observability_tail_sampling_spans_dropped_total: number of spans dropped because of MaxSpansPerTrace.
+//   - observability_tail_sampling_traces_buffered: number of traces waiting for decision.
+type TailSamplingProcessor struct {
+	next     []sdktrace.SpanProcessor
+	wait     time.Duration
+	maxTrace int
+	maxSpan  int
+	policies []TailSamplingPolicy
+
+	mu      sync.Mutex
+	traces  map[trace.TraceID]*traceBuffer
+	pending []trace.TraceID // Buffered traces from the oldest.
+	decided map[trace.TraceID]bool
+	history []trace.TraceID // Decided traces from the oldest.
+	closed  bool
+
+	sampled    atomic.Int64
+	notSampled atomic.Int64
+	evicted    atomic.Int64
+	dropped    atomic.Int64
+	buffered   atomic.Int64
+
+	tracesDesc   *prometheus.Desc
+	evictedDesc  *prometheus.Desc
+	droppedDesc  *prometheus.Desc
+	bufferedDesc *prometheus.Desc
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// traceBuffer holds the ended spans of a trace.
+type traceBuffer struct {
+	spans []sdktrace.ReadOnlySpan
+	first time.Time
+}
+
+// NewTailSamplingProcessor returns a new tail sampling processor
+// which forwards sampled spans to the next processors.
+func NewTailSamplingProcessor(c *TailSamplingConfig, next ...sdktrace.SpanProcessor) (*TailSamplingProcessor, error) {
+	if len(c.Policies) == 0 {
+		return nil, errors.New("otel: tail sampling requires at least one policy")
+	}
+	p := &TailSamplingProcessor{
+		next:         next,
+		wait:         cmp.Or(max(c.DecisionWait, 0), 10*time.Second),
+		maxTrace:     cmp.Or(max(c.MaxTraces, 0), 10000),
+		maxSpan:      cmp.Or(max(c.MaxSpansPerTrace, 0), 1000),
+		policies:     c.Policies,
+		traces:       map[trace.TraceID]*traceBuffer{},
+		decided:      map[trace.TraceID]bool{},
+		tracesDesc:   prometheus.NewDesc("observability_tail_sampling_traces_total", "Number of traces decided by the tail sampler", []string{"decision"}, nil),
+		evictedDesc:  prometheus.NewDesc("observability_tail_sampling_traces_evicted_total", "Number of traces decided before the decision wait", nil, nil),
+		droppedDesc:  prometheus.NewDesc("observability_tail_sampling_spans_dropped_total", "Number of spans dropped by the per trace limit", nil, nil),
+		bufferedDesc: prometheus.NewDesc("observability_tail_sampling_traces_buffered", "Number of traces waiting for decision", nil, nil),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go p.run(max(p.wait/10, 10*time.Millisecond))
+	return p, nil
+}
+
+func (p *TailSamplingProcessor) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.forward(p.decide(now.Add(-p.wait)))
+		}
+	}
+}
+
+func (p *TailSamplingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, n := range p.next {
+		n.OnStart(parent, s)
+	}
+}
+
+func (p *TailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	tid := s.SpanContext().TraceID()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	if keep, ok := p.decided[tid]; ok {
+		p.mu.Unlock()
+		if keep {
+			p.forward([]sdktrace.ReadOnlySpan{s})
+		}
+		return
+	}
+	var spans []sdktrace.ReadOnlySpan
+	tb, ok := p.traces[tid]
+	if !ok {
+		if len(p.traces) >= p.maxTrace {
+			spans = p.decideLocked(p.pending[0])
+			p.evicted.Add(1)
+		}
+		tb = &traceBuffer{first: time.Now()}
+		p.traces[tid] = tb
+		p.pending = append(p.pending, tid)
+		p.buffered.Add(1)
+	}
+	if len(tb.spans) < p.maxSpan {
+		tb.spans = append(tb.spans, s)
+	} else {
+		p.dropped.Add(1)
+	}
+	p.mu.Unlock()
+	p.forward(spans)
+}
+
+// decide makes decisions for traces whose first span
+// ended before the deadline and returns sampled spans.
+func (p *TailSamplingProcessor) decide(deadline time.Time) []sdktrace.ReadOnlySpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var spans []sdktrace.ReadOnlySpan
+	for len(p.pending) > 0 {
+		if tb := p.traces[p.pending[0]]; tb.first.After(deadline) {
+			break
+		}
+		spans = append(spans, p.decideLocked(p.pending[0])...)
+	}
+	return spans
+}
+
+// decideLocked makes the decision for the oldest buffered trace
+// with the tid and returns spans if sampled.
+func (p *TailSamplingProcessor) decideLocked(tid trace.TraceID) []sdktrace.ReadOnlySpan {
+	tb := p.traces[tid]
+	delete(p.traces, tid)
+	p.pending = p.pending[1:]
+	p.buffered.Add(-1)
+
+	keep := false
+	for _, policy := range p.policies {
+		if policy(tb.spans) {
+			keep = true
+			break
+		}
+	}
+	p.decided[tid] = keep
+	p.history = append(p.history, tid)
+	if len(p.history) > p.maxTrace {
+		delete(p.decided, p.history[0])
+		p.history = p.history[1:]
+	}
+	if !keep {
+		p.notSampled.Add(1)
+		return nil
+	}
+	p.sampled.Add(1)
+	return tb.spans
+}
+
+func (p *TailSamplingProcessor) forward(spans []sdktrace.ReadOnlySpan) {
+	for _, s := range spans {
+		for _, n := range p.next {
+			n.OnEnd(s)
+		}
+	}
+}
+
+// ForceFlush makes decisions for the buffered traces
+// whose DecisionWait has elapsed and flushes the next processors.
+// Traces still in the decision window are kept buffered
+// so that decisions are not made on incomplete traces.
+func (p *TailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	p.forward(p.decide(time.Now().Add(-p.wait)))
+	var errs []error
+	for _, n := range p.next {
+		errs = append(errs, n.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown makes decisions for all buffered traces
+// and shuts down the next processors.
+// Spans ended after the shutdown are dropped.
+func (p *TailSamplingProcessor) Shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.forward(p.decide(time.Now()))
+	var errs []error
+	for _, n := range p.next {
+		errs = append(errs, n.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Describe implements [prometheus.Collector].
+func (p *TailSamplingProcessor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.tracesDesc
+	ch <- p.evictedDesc
+	ch <- p.droppedDesc
+	ch <- p.bufferedDesc
+}
+
+// Collect implements [prometheus.Collector].
+func (p *TailSamplingProcessor) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(p.tracesDesc, prometheus.CounterValue, float64(p.sampled.Load()), "sampled")
+	ch <- prometheus.MustNewConstMetric(p.tracesDesc, prometheus.CounterValue, float64(p.notSampled.Load()), "not_sampled")
+	ch <- prometheus.MustNewConstMetric(p.evictedDesc, prometheus.CounterValue, float64(p.evicted.Load()))
+	ch <- prometheus.MustNewConstMetric(p.droppedDesc, prometheus.CounterValue, float64(p.dropped.Load()))
+	ch <- prometheus.MustNewConstMetric(p.bufferedDesc, prometheus.GaugeValue, float64(p.buffered.Load()))
+}
+
+// RegisterMeter registers the metrics of the processor to the meter.
+// Call [metric.Registration.Unregister] to stop collecting them.
+func (p *TailSamplingProcessor) RegisterMeter(meter metric.Meter) (metric.Registration, error) {
+	traces, err := meter.Int64ObservableCounter("observability_tail_sampling_traces_total",
+		metric.WithDescription("Number of traces decided by the tail sampler"))
+	if err != nil {
+		return nil, err
+	}
+	evicted, err := meter.Int64ObservableCounter("observability_tail_sampling_traces_evicted_total",
+		metric.WithDescription("Number of traces decided before the decision wait"))
+	if err != nil {
+		return nil, err
+	}
+	dropped, err := meter.Int64ObservableCounter("observability_tail_sampling_spans_dropped_total",
+		metric.WithDescription("Number of spans dropped by the per trace limit"))
+	if err != nil {
+		return nil, err
+	}
+	buffered, err := meter.Int64ObservableGauge("observability_tail_sampling_traces_buffered",
+		metric.WithDescription("Number of traces waiting for decision"))
+	if err != nil {
+		return nil, err
+	}
+	sampledOpt := metric.WithAttributes(attribute.String("decision", "sampled"))
+	notSampledOpt := metric.WithAttributes(attribute.String("decision", "not_sampled"))
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(traces, p.sampled.Load(), sampledOpt)
+		o.ObserveInt64(traces, p.notSampled.Load(), notSampledOpt)
+		o.ObserveInt64(evicted, p.evicted.Load())
+		o.ObserveInt64(dropped, p.dropped.Load())
+		o.ObserveInt64(buffered, p.buffered.Load())
+		return nil
+	}, traces, evicted, dropped, buffered)
+}
@@ -0,0 +1,216 @@
+package otel
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// stubSpans returns read only spans from the stubs.
+func stubSpans(stubs ...tracetest.SpanStub) []sdktrace.ReadOnlySpan {
+	spans := make([]sdktrace.ReadOnlySpan, 0, len(stubs))
+	for _, s := range stubs {
+		spans = append(spans, s.Snapshot())
+	}
+	return spans
+}
+
+func TestTailSamplingPolicy(t *testing.T) {
+	now := time.Now()
+	errSpan := tracetest.SpanStub{Status: sdktrace.Status{Code: codes.Error}}
+	okSpan := tracetest.SpanStub{Status: sdktrace.Status{Code: codes.Ok}}
+	attrSpan := tracetest.SpanStub{Attributes: []attribute.KeyValue{attribute.String("user", "admin"), attribute.Int("code", 500)}}
+	traceSpan := func(id byte) tracetest.SpanStub {
+		// The lower 64 bits of the trace id decide the probability.
+		return tracetest.SpanStub{SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{15: 1, 8: id},
+			SpanID:  trace.SpanID{1},
+		})}
+	}
+	testCases := map[string]struct {
+		policy TailSamplingPolicy
+		spans  []sdktrace.ReadOnlySpan
+		keep   bool
+	}{
+		"error kept":          {ErrorPolicy(), stubSpans(okSpan, errSpan), true},
+		"error not kept":      {ErrorPolicy(), stubSpans(okSpan, tracetest.SpanStub{}), false},
+		"error no spans":      {ErrorPolicy(), nil, false},
+		"latency kept":        {LatencyPolicy(time.Second), stubSpans(tracetest.SpanStub{StartTime: now, EndTime: now.Add(time.Millisecond)}, tracetest.SpanStub{StartTime: now.Add(time.Second), EndTime: now.Add(2 * time.Second)}), true},
+		"latency not kept":    {LatencyPolicy(time.Second), stubSpans(tracetest.SpanStub{StartTime: now, EndTime: now.Add(time.Second)}), false},
+		"latency no spans":    {LatencyPolicy(0), nil, false},
+		"attribute kept":      {AttributePolicy(attribute.Int("code", 500)), stubSpans(okSpan, attrSpan), true},
+		"attribute any":       {AttributePolicy(attribute.String("foo", "bar"), attribute.String("user", "admin")), stubSpans(attrSpan), true},
+		"attribute value":     {AttributePolicy(attribute.String("user", "guest")), stubSpans(attrSpan), false},
+		"attribute type":      {AttributePolicy(attribute.String("code", "500")), stubSpans(attrSpan), false},
+		"probability one":     {ProbabilityPolicy(1), nil, true},
+		"probability zero":    {ProbabilityPolicy(0), stubSpans(traceSpan(0)), false},
+		"probability no span": {ProbabilityPolicy(0.5), nil, false},
+		"probability lower":   {ProbabilityPolicy(0.5), stubSpans(traceSpan(0x7f)), true},
+		"probability upper":   {ProbabilityPolicy(0.5), stubSpans(traceSpan(0x80)), false},
+		"probability ratio":   {ProbabilityPolicy(0.5), stubSpans(traceSpan(0x7f), traceSpan(0x80)), true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if keep := tc.policy(tc.spans); keep != tc.keep {
+				t.Errorf("want %v, got %v", tc.keep, keep)
+			}
+		})
+	}
+}
+
+func TestProbabilityPolicy_consistent(t *testing.T) {
+	// The policy must agree with the head sampler of the same ratio.
+	sampler := sdktrace.TraceIDRatioBased(0.3)
+	policy := ProbabilityPolicy(0.3)
+	for i := range 1000 {
+		tid := trace.TraceID{8: byte(i), 9: byte(i >> 8), 10: byte(i * 31), 15: 1}
+		sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: trace.SpanID{1}})
+		want := sampler.ShouldSample(sdktrace.SamplingParameters{TraceID: tid}).Decision == sdktrace.RecordAndSample
+		if got := policy(stubSpans(tracetest.SpanStub{SpanContext: sc})); got != want {
+			t.Fatalf("trace %s: want %v, got %v", tid, want, got)
+		}
+	}
+}
+
+func TestTailSamplingProcessor_ForceFlush(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	p, err := NewTailSamplingProcessor(&TailSamplingConfig{
+		DecisionWait: time.Hour,
+		Policies:     []TailSamplingPolicy{ProbabilityPolicy(1)},
+	}, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	defer tp.Shutdown(context.Background())
+
+	ctx, root := tp.Tracer("test").Start(context.Background(), "root")
+	_, child := tp.Tracer("test").Start(ctx, "child")
+	child.End()
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Ended()); n != 0 {
+		t.Fatalf("trace in the decision window must be kept buffered, got %d spans", n)
+	}
+	root.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Ended()); n != 2 {
+		t.Errorf("want 2 spans after shutdown, got %d", n)
+	}
+}
+
+func TestTailSamplingProcessor_Shutdown(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	p, err := NewTailSamplingProcessor(&TailSamplingConfig{
+		Policies: []TailSamplingPolicy{ProbabilityPolicy(1)},
+	}, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spans ended after the shutdown must not be buffered.
+	tp := sdktrace.NewTracerProvider()
+	_, span := tp.Tracer("test").Start(context.Background(), "late")
+	span.End()
+	p.OnEnd(span.(sdktrace.ReadOnlySpan))
+	if n := p.buffered.Load(); n != 0 {
+		t.Errorf("want no buffered traces after shutdown, got %d", n)
+	}
+	if n := len(rec.Ended()); n != 0 {
+		t.Errorf("want no spans after shutdown, got %d", n)
+	}
+}
+
+func TestTailSamplingProcessor_MaxTraces(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	p, err := NewTailSamplingProcessor(&TailSamplingConfig{
+		DecisionWait: time.Hour,
+		MaxTraces:    2,
+		Policies:     []TailSamplingPolicy{ErrorPolicy()},
+	}, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	defer tp.Shutdown(context.Background())
+
+	for _, name := range []string{"first", "second", "third"} {
+		_, span := tp.Tracer("test").Start(context.Background(), name)
+		span.SetStatus(codes.Error, "")
+		span.End()
+	}
+	// The oldest trace is decided early and forwarded.
+	spans := rec.Ended()
+	if len(spans) != 1 || spans[0].Name() != "first" {
+		t.Fatalf("want the first trace evicted, got %d spans", len(spans))
+	}
+	want := `
+# HELP observability_tail_sampling_traces_buffered Number of traces waiting for decision
+# TYPE observability_tail_sampling_traces_buffered gauge
+observability_tail_sampling_traces_buffered 2
+# HELP observability_tail_sampling_traces_evicted_total Number of traces decided before the decision wait
+# TYPE observability_tail_sampling_traces_evicted_total counter
+observability_tail_sampling_traces_evicted_total 1
+# HELP observability_tail_sampling_traces_total Number of traces decided by the tail sampler
+# TYPE observability_tail_sampling_traces_total counter
+observability_tail_sampling_traces_total{decision="not_sampled"} 0
+observability_tail_sampling_traces_total{decision="sampled"} 1
+`
+	names := []string{
+		"observability_tail_sampling_traces_buffered",
+		"observability_tail_sampling_traces_evicted_total",
+		"observability_tail_sampling_traces_total",
+	}
+	if err := testutil.CollectAndCompare(p, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTailSamplingProcessor_MaxSpansPerTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	p, err := NewTailSamplingProcessor(&TailSamplingConfig{
+		DecisionWait:     time.Hour,
+		MaxSpansPerTrace: 2,
+		Policies:         []TailSamplingPolicy{ErrorPolicy()},
+	}, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	defer tp.Shutdown(context.Background())
+
+	ctx, root := tp.Tracer("test").Start(context.Background(), "root")
+	for range 2 {
+		_, span := tp.Tracer("test").Start(ctx, "child")
+		span.End()
+	}
+	// The error span exceeds the limit and is not seen by the policy.
+	root.SetStatus(codes.Error, "")
+	root.End()
+	if n := p.dropped.Load(); n != 1 {
+		t.Errorf("want 1 dropped span, got %d", n)
+	}
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Ended()); n != 0 {
+		t.Errorf("want the trace not sampled, got %d spans", n)
+	}
+	if n := p.notSampled.Load(); n != 1 {
+		t.Errorf("want 1 not sampled trace, got %d", n)
+	}
+}
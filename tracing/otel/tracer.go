@@ -3,30 +3,62 @@ package otel
 import (
 	"context"
 	"net/http"
-	"path"
-	"runtime"
 
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/recovery"
 	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
 	"github.com/aileron-projects/go/znet/zhttp"
 	"github.com/aileron-projects/go/zx/zuid"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	_ zhttp.ServerMiddleware = &Tracer{}
-	_ zhttp.ClientMiddleware = &Tracer{}
-	_ tracing.Tracer         = &Tracer{}
+	_ zhttp.ServerMiddleware  = &Tracer{}
+	_ zhttp.ClientMiddleware  = &Tracer{}
+	_ tracing.Tracer          = &Tracer{}
+	_ tracing.TraceMiddleware = &Tracer{}
+	_ tracing.IDTracer        = &Tracer{}
 )
 
 type Tracer struct {
 	tracer trace.Tracer
 	tp     *sdktrace.TracerProvider
 	pg     propagation.TextMapPropagator
+	// monitor monitors the exporters given by Config.Exporters.
+	monitor *health.Monitor
+	// tailSampler is the tail sampling processor
+	// created from Config.TailSampling.
+	tailSampler *TailSamplingProcessor
+	// sampler is the rule based sampler
+	// created from Config.Sampling.
+	sampler *sampling.Sampler
+	// spanMetrics records the metrics of finished spans
+	// given by Config.SpanMetrics. It may be nil.
+	spanMetrics *spanmetrics.Metrics
+	// serviceGraph records the service graph
+	// given by Config.ServiceGraph. It may be nil.
+	serviceGraph *servicegraph.Graph
 
-	addCaller bool
+	// caller captures the callers of root spans.
+	// It is nil if the caller is not added.
+	caller *tracing.CallerCapture
+	// spanName returns the names of http spans.
+	spanName tracing.SpanNameFunc
+	// recordPanics, if true, records panics
+	// in the server middleware.
+	recordPanics bool
+
+	// responseHeaders writes the trace id
+	// in the response headers if not nil.
+	responseHeaders *tracing.ResponseHeaderConfig
 
 	serverSpanHook func(span trace.Span, w http.ResponseWriter, r *http.Request)
 	clientSpanHook func(span trace.Span, w *http.Response, r *http.Request)
@@ -39,17 +71,25 @@ func (t *Tracer) ServerMiddleware(next http.Handler) http.Handler {
 			c = v.(int) + 1
 		}
 		r = r.WithContext(context.WithValue(r.Context(), tracing.ServerCtxKey, c))
+		if t.sampler != nil && c == 1 {
+			r = r.WithContext(sampling.ContextWithRequest(r.Context(), r))
+		}
 
-		span, ctx := t.spanContext(r, "server+"+r.URL.Path)
+		name := t.spanName("server", r)
+		span, ctx := t.spanContext(r, name, trace.SpanKindServer)
 		defer span.End()
 		r = r.WithContext(ctx)
+		if t.recordPanics {
+			defer func() {
+				if v := recover(); v != nil {
+					recovery.Handle(v, func(p *recovery.Panic) { recordPanic(span, p) })
+				}
+			}()
+		}
 
-		if t.addCaller {
-			if ptr, file, _, ok := runtime.Caller(2); ok {
-				f, _ := runtime.CallersFrames([]uintptr{ptr}).Next()
-				span.SetAttributes(attribute.String("caller.file", path.Base(path.Dir(file))+"/"+path.Base(file)))
-				span.SetAttributes(attribute.String("caller.func", f.Function))
-				span.SetAttributes(attribute.Int("caller.line", f.Line))
+		if t.caller != nil && c == 1 { // Only for root span.
+			if cl, ok := t.caller.Capture(); ok {
+				setCaller(span, cl)
 			}
 		}
 
@@ -57,8 +97,17 @@ func (t *Tracer) ServerMiddleware(next http.Handler) http.Handler {
 			ww := zhttp.WrapResponseWriter(w)
 			w = ww
 			defer t.serverSpanHook(span, ww, r)
+			if t.responseHeaders != nil {
+				ids, _ := t.SpanIDs(ctx)
+				var done func()
+				r, done = t.responseHeaders.Hook(ww, r, ids)
+				defer done()
+			}
 		}
 		next.ServeHTTP(w, r)
+		if n := t.spanName("server", r); n != name {
+			span.SetName(n) // Route may be matched by the handler.
+		}
 	})
 }
 
@@ -70,16 +119,15 @@ func (t *Tracer) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 		}
 		r = r.WithContext(context.WithValue(r.Context(), tracing.ClientCtxKey, c))
 
-		span, ctx := t.spanContext(r, "client+"+r.URL.Path)
+		span, ctx := t.spanContext(r, t.spanName("client", r), trace.SpanKindClient)
 		defer span.End()
 		r = r.WithContext(ctx)
+		r.Header = cloneHeader(r.Header)
+		t.pg.Inject(ctx, propagation.HeaderCarrier(r.Header))
 
-		if t.addCaller {
-			if ptr, file, _, ok := runtime.Caller(2); ok {
-				f, _ := runtime.CallersFrames([]uintptr{ptr}).Next()
-				span.SetAttributes(attribute.String("caller.file", path.Base(path.Dir(file))+"/"+path.Base(file)))
-				span.SetAttributes(attribute.String("caller.func", f.Function))
-				span.SetAttributes(attribute.Int("caller.line", f.Line))
+		if t.caller != nil && c == 1 { // Only for root span.
+			if cl, ok := t.caller.Capture(); ok {
+				setCaller(span, cl)
 			}
 		}
 
@@ -91,15 +139,17 @@ func (t *Tracer) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 	})
 }
 
-// spanContext returns nre span and context for the request.
-func (t *Tracer) spanContext(r *http.Request, name string) (trace.Span, context.Context) {
+// spanContext returns new span of the kind and context for the request.
+func (t *Tracer) spanContext(r *http.Request, name string, kind trace.SpanKind) (trace.Span, context.Context) {
 	var span trace.Span
 	ctx := r.Context()
-	if parentSpan := trace.SpanFromContext(ctx); parentSpan.SpanContext().IsValid() {
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		ctx, span = t.joinSpan(ctx, name, ids, trace.WithSpanKind(kind))
+	} else if parentSpan := trace.SpanFromContext(ctx); parentSpan.SpanContext().IsValid() {
 		ctx, span = t.tracer.Start(
 			ctx,
 			name,
-			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithSpanKind(kind),
 			trace.WithLinks(trace.Link{SpanContext: parentSpan.SpanContext()}),
 		)
 	} else {
@@ -107,24 +157,40 @@ func (t *Tracer) spanContext(r *http.Request, name string) (trace.Span, context.
 		sc := trace.SpanFromContext(remoteCtx).SpanContext()
 		if sc.IsValid() {
 			ctx, span = t.tracer.Start(remoteCtx, name,
-				trace.WithSpanKind(trace.SpanKindInternal),
+				trace.WithSpanKind(kind),
 				trace.WithLinks(trace.Link{SpanContext: sc}),
 			)
 		} else {
-			ctx, span = t.tracer.Start(ctx, name,
-				trace.WithSpanKind(trace.SpanKindServer),
+			// The remoteCtx may have baggage without trace context.
+			ctx, span = t.tracer.Start(remoteCtx, name,
+				trace.WithSpanKind(kind),
 			)
 		}
 	}
 	return span, ctx
 }
 
+// joinSpan starts a span with the ids created by another tracer.
+// The ids in the returned context are consumed.
+// The opts are applied after the default span kind.
+func (t *Tracer) joinSpan(ctx context.Context, name string, ids tracing.SpanIDs, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	kind := trace.SpanKindInternal
+	if ids.ParentID == [8]byte{} {
+		kind = trace.SpanKindServer
+	}
+	opts = append([]trace.SpanStartOption{trace.WithSpanKind(kind)}, opts...)
+	ctx, span := t.tracer.Start(joinContext(ctx, ids), name, opts...)
+	return tracing.ContextWithSpanIDs(ctx, tracing.SpanIDs{}), span
+}
+
 // Trace is the method than can be called from any types of resources.
 // Callers must update their context with the returned one.
 // The returned function with finishes spans must be called when finishing spans.
 func (t *Tracer) Trace(ctx context.Context, name string, tags map[string]string) (spanCtx context.Context, finish func()) {
 	var span trace.Span
-	if parentSpan := trace.SpanFromContext(ctx); parentSpan.SpanContext().IsValid() {
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		spanCtx, span = t.joinSpan(ctx, name, ids)
+	} else if parentSpan := trace.SpanFromContext(ctx); parentSpan.SpanContext().IsValid() {
 		spanCtx, span = t.tracer.Start(ctx, name,
 			trace.WithSpanKind(trace.SpanKindInternal),
 			trace.WithLinks(trace.Link{SpanContext: parentSpan.SpanContext()}),
@@ -140,52 +206,140 @@ func (t *Tracer) Trace(ctx context.Context, name string, tags map[string]string)
 	return spanCtx, func() { span.End() }
 }
 
+// Monitor returns the monitor of the exporters given by [Config.Exporters].
+// The monitor can be registered to a metrics backend to
+// publish the internal metrics of exporters.
+func (t *Tracer) Monitor() *health.Monitor {
+	return t.monitor
+}
+
+// Health returns the health status of the exporters given by [Config.Exporters].
+func (t *Tracer) Health() health.Status {
+	return t.monitor.Health()
+}
+
+// TailSampler returns the tail sampling processor
+// configured by the Config.TailSampling.
+// It returns nil if tail sampling is not enabled.
+func (t *Tracer) TailSampler() *TailSamplingProcessor {
+	return t.tailSampler
+}
+
+// Sampler returns the rule based sampler configured by the Config.Sampling.
+// It returns nil if not configured.
+// The sampler can be registered to a metrics backend
+// to publish the sampling metrics.
+func (t *Tracer) Sampler() *sampling.Sampler {
+	return t.sampler
+}
+
+// SpanMetrics returns the span metrics given by the Config.SpanMetrics.
+// It returns nil if not configured.
+// The metrics can be registered to a metrics backend
+// to publish the request, error and duration metrics of spans.
+func (t *Tracer) SpanMetrics() *spanmetrics.Metrics {
+	return t.spanMetrics
+}
+
+// ServiceGraph returns the service graph given by the Config.ServiceGraph.
+// It returns nil if not configured.
+// The graph can be registered to a metrics backend
+// to publish the service graph metrics.
+func (t *Tracer) ServiceGraph() *servicegraph.Graph {
+	return t.serviceGraph
+}
+
 // Finalize calls t.tp.Shutdown and flushes remaining data.
 func (t *Tracer) Finalize(ctx context.Context) error {
 	return t.tp.Shutdown(ctx)
 }
 
 func serverSpanHook(span trace.Span, w http.ResponseWriter, r *http.Request) {
-	if id := zuid.FromContext(r.Context(), "context"); id != "" {
-		span.SetAttributes(attribute.String("context", id))
-	}
 	proto := "http"
 	if r.TLS != nil {
 		proto = "https"
 	}
-	span.SetAttributes(attribute.String("http.schema", proto))
-	span.SetAttributes(attribute.String("http.method", r.Method))
-	span.SetAttributes(attribute.String("http.path", r.URL.Path))
-	span.SetAttributes(attribute.String("http.query", r.URL.RawQuery))
-	span.SetAttributes(attribute.String("http.referer", r.Referer()))
-	span.SetAttributes(attribute.String("http.route", r.Pattern))
-	span.SetAttributes(attribute.Int64("http.request_content_length", r.ContentLength))
-	span.SetAttributes(attribute.String("net.addr", r.RemoteAddr))
-	span.SetAttributes(attribute.String("net.host", r.Host))
 	ww := w.(*zhttp.ResponseWrapper)
-	span.SetAttributes(attribute.Int("http.status_code", ww.StatusCode()))
-	span.SetAttributes(attribute.Int64("http.response_content_length", ww.Written()))
+	attrs := make([]attribute.KeyValue, 0, 13)
+	if id := zuid.FromContext(r.Context(), "context"); id != "" {
+		attrs = append(attrs, attribute.String("context", id))
+	}
+	attrs = append(attrs,
+		attribute.String("http.schema", proto),
+		attribute.String("http.method", r.Method),
+		attribute.String("http.path", r.URL.Path),
+		attribute.String("http.query", r.URL.RawQuery),
+		attribute.String("http.referer", r.Referer()),
+		attribute.String("http.route", r.Pattern),
+		attribute.Int64("http.request_content_length", r.ContentLength),
+		attribute.String("net.addr", r.RemoteAddr),
+		attribute.String("net.host", r.Host),
+		attribute.Int("http.status_code", ww.StatusCode()),
+		attribute.Int64("http.response_content_length", ww.Written()),
+	)
+	span.SetAttributes(attrs...) // Set at once to lock the span only once.
 }
 
-func clientSpanHook(span trace.Span, w *http.Response, r *http.Request) {
-	if id := zuid.FromContext(r.Context(), "context"); id != "" {
-		span.SetAttributes(attribute.String("context", id))
+// setCaller sets the caller attributes and
+// the "caller.stack" event if the stack is available.
+func setCaller(span trace.Span, c *tracing.Caller) {
+	span.SetAttributes(
+		attribute.String("caller.file", c.File),
+		attribute.String("caller.func", c.Func),
+		attribute.Int("caller.line", c.Line),
+	)
+	if c.Stack != "" {
+		span.AddEvent("caller.stack", trace.WithAttributes(attribute.String("caller.stack", c.Stack)))
 	}
+}
+
+// recordPanic records the panic as an exception event
+// and marks the span as an error with the status code 500.
+func recordPanic(span trace.Span, p *recovery.Panic) {
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+		semconv.ExceptionType(p.Type()),
+		semconv.ExceptionMessage(p.Message()),
+		semconv.ExceptionStacktrace(string(p.Stack)),
+		semconv.ExceptionEscaped(true),
+	))
+	span.SetAttributes(attribute.Int("http.status_code", http.StatusInternalServerError))
+	span.SetStatus(codes.Error, p.Error())
+}
+
+func clientSpanHook(span trace.Span, w *http.Response, r *http.Request) {
 	proto := "http"
 	if r.TLS != nil {
 		proto = "https"
 	}
-	span.SetAttributes(attribute.String("http.schema", proto))
-	span.SetAttributes(attribute.String("http.method", r.Method))
-	span.SetAttributes(attribute.String("http.path", r.URL.Path))
-	span.SetAttributes(attribute.String("http.query", r.URL.RawQuery))
-	span.SetAttributes(attribute.Int64("http.request_content_length", r.ContentLength))
-	span.SetAttributes(attribute.String("net.addr", r.RemoteAddr))
-	span.SetAttributes(attribute.String("peer.host", r.URL.Host))
+	attrs := make([]attribute.KeyValue, 0, 11)
+	if id := zuid.FromContext(r.Context(), "context"); id != "" {
+		attrs = append(attrs, attribute.String("context", id))
+	}
+	attrs = append(attrs,
+		attribute.String("http.schema", proto),
+		attribute.String("http.method", r.Method),
+		attribute.String("http.path", r.URL.Path),
+		attribute.String("http.query", r.URL.RawQuery),
+		attribute.Int64("http.request_content_length", r.ContentLength),
+		attribute.String("net.addr", r.RemoteAddr),
+		attribute.String("peer.host", r.URL.Host),
+	)
 	if w == nil {
-		span.SetAttributes(attribute.Int("http.status_code", 0))
+		attrs = append(attrs, attribute.Int("http.status_code", 0))
 	} else {
-		span.SetAttributes(attribute.Int("http.status_code", w.StatusCode))
-		span.SetAttributes(attribute.Int64("http.response_content_length", w.ContentLength))
+		attrs = append(attrs,
+			attribute.Int("http.status_code", w.StatusCode),
+			attribute.Int64("http.response_content_length", w.ContentLength),
+		)
+	}
+	span.SetAttributes(attrs...) // Set at once to lock the span only once.
+}
+
+// cloneHeader returns a copy of the h so that
+// client middleware do not modify the original request.
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return http.Header{}
 	}
+	return h.Clone()
 }
@@ -0,0 +1,80 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unbatchedServerSpanHook sets the same attributes as the
+// serverSpanHook one by one. It is the baseline of the benchmark.
+func unbatchedServerSpanHook(span trace.Span, w http.ResponseWriter, r *http.Request) {
+	ww := w.(*zhttp.ResponseWrapper)
+	span.SetAttributes(attribute.String("http.schema", "http"))
+	span.SetAttributes(attribute.String("http.method", r.Method))
+	span.SetAttributes(attribute.String("http.path", r.URL.Path))
+	span.SetAttributes(attribute.String("http.query", r.URL.RawQuery))
+	span.SetAttributes(attribute.String("http.referer", r.Referer()))
+	span.SetAttributes(attribute.String("http.route", r.Pattern))
+	span.SetAttributes(attribute.Int64("http.request_content_length", r.ContentLength))
+	span.SetAttributes(attribute.String("net.addr", r.RemoteAddr))
+	span.SetAttributes(attribute.String("net.host", r.Host))
+	span.SetAttributes(attribute.Int("http.status_code", ww.StatusCode()))
+	span.SetAttributes(attribute.Int64("http.response_content_length", ww.Written()))
+}
+
+// unbatchedClientSpanHook sets the same attributes as the
+// clientSpanHook one by one. It is the baseline of the benchmark.
+func unbatchedClientSpanHook(span trace.Span, w *http.Response, r *http.Request) {
+	span.SetAttributes(attribute.String("http.schema", "http"))
+	span.SetAttributes(attribute.String("http.method", r.Method))
+	span.SetAttributes(attribute.String("http.path", r.URL.Path))
+	span.SetAttributes(attribute.String("http.query", r.URL.RawQuery))
+	span.SetAttributes(attribute.Int64("http.request_content_length", r.ContentLength))
+	span.SetAttributes(attribute.String("net.addr", r.RemoteAddr))
+	span.SetAttributes(attribute.String("peer.host", r.URL.Host))
+	span.SetAttributes(attribute.Int("http.status_code", w.StatusCode))
+	span.SetAttributes(attribute.Int64("http.response_content_length", w.ContentLength))
+}
+
+// benchmarkSpans runs the f with a recording span of the SDK
+// which is ended after the benchmark.
+func benchmarkSpans(b *testing.B, f func(span trace.Span)) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	_, span := tp.Tracer("bench").Start(context.Background(), "bench")
+	defer span.End()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		f(span)
+	}
+}
+
+func BenchmarkServerSpanHook(b *testing.B) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/test?foo=bar", nil)
+	w := zhttp.WrapResponseWriter(httptest.NewRecorder())
+	b.Run("batched", func(b *testing.B) {
+		benchmarkSpans(b, func(span trace.Span) { serverSpanHook(span, w, r) })
+	})
+	b.Run("unbatched", func(b *testing.B) {
+		benchmarkSpans(b, func(span trace.Span) { unbatchedServerSpanHook(span, w, r) })
+	})
+}
+
+func BenchmarkClientSpanHook(b *testing.B) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/test?foo=bar", nil)
+	res := &http.Response{StatusCode: http.StatusOK}
+	b.Run("batched", func(b *testing.B) {
+		benchmarkSpans(b, func(span trace.Span) { clientSpanHook(span, res, r) })
+	})
+	b.Run("unbatched", func(b *testing.B) {
+		benchmarkSpans(b, func(span trace.Span) { unbatchedClientSpanHook(span, res, r) })
+	})
+}
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aileron-projects/go/znet/zhttp"
+)
+
+// ResponseHeaderConfig is the configuration to write
+// the trace id of server spans in response headers.
+// Headers are written just before the status code is written
+// so they are available even for streaming responses.
+type ResponseHeaderConfig struct {
+	// TraceResponse, if true, writes the W3C "traceresponse" header
+	// such as "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01".
+	TraceResponse bool `json:"traceResponse"`
+	// TraceIDHeader is the header name to write the trace id
+	// in hex such as "X-Trace-Id". 64-bit trace ids are written
+	// in 16 characters. If empty, the header is not written.
+	TraceIDHeader string `json:"traceIDHeader"`
+	// ServerTiming, if true, adds "Server-Timing" header entries.
+	// The "traceparent" entry has the trace context in the desc,
+	// the "total" entry has the duration until the status code is written
+	// and other entries are the phases recorded by
+	// [StartServerTiming] and [AddServerTiming].
+	ServerTiming bool `json:"serverTiming"`
+}
+
+// Hook sets the hook to the ww that writes the response headers
+// with the ids before the status code is written.
+// The returned request has the context to record server timings.
+// The returned function must be called after the handler returned
+// so that the headers are written even if the handler wrote nothing.
+func (c *ResponseHeaderConfig) Hook(ww *zhttp.ResponseWrapper, r *http.Request, ids SpanIDs) (*http.Request, func()) {
+	if c == nil || !ids.IsValid() || (!c.TraceResponse && c.TraceIDHeader == "" && !c.ServerTiming) {
+		return r, func() {}
+	}
+	start := time.Now()
+	var timings *serverTimings
+	if c.ServerTiming {
+		timings = &serverTimings{}
+		r = r.WithContext(context.WithValue(r.Context(), serverTimingKey{}, timings))
+	}
+	var once sync.Once
+	write := func() {
+		once.Do(func() { c.write(ww.Header(), ids, time.Since(start), timings) })
+	}
+	prev := ww.StatusWritten
+	ww.StatusWritten = func(statusCode int) {
+		write()
+		if prev != nil {
+			prev(statusCode)
+		}
+	}
+	return r, func() {
+		if ww.StatusCode() < 0 {
+			write() // Nothing written by the handler.
+		}
+	}
+}
+
+func (c *ResponseHeaderConfig) write(h http.Header, ids SpanIDs, total time.Duration, timings *serverTimings) {
+	traceID := hex.EncodeToString(ids.TraceID[:])
+	flags := "00"
+	if ids.Sampled {
+		flags = "01"
+	}
+	tp := "00-" + traceID + "-" + hex.EncodeToString(ids.SpanID[:]) + "-" + flags
+	if c.TraceResponse {
+		h.Set("Traceresponse", tp)
+	}
+	if c.TraceIDHeader != "" {
+		if [8]byte(ids.TraceID[:8]) == [8]byte{} {
+			h.Set(c.TraceIDHeader, traceID[16:]) // 64-bit trace id.
+		} else {
+			h.Set(c.TraceIDHeader, traceID)
+		}
+	}
+	if c.ServerTiming {
+		entries := []string{`traceparent;desc="` + tp + `"`, "total;dur=" + milliseconds(total)}
+		entries = append(entries, timings.entries()...)
+		h.Add("Server-Timing", strings.Join(entries, ", "))
+	}
+}
+
+type serverTimingKey struct{}
+
+// serverTimings is the phases recorded in a request.
+type serverTimings struct {
+	mu     sync.Mutex
+	phases []string
+}
+
+func (t *serverTimings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	t.phases = append(t.phases, name+";dur="+milliseconds(d))
+	t.mu.Unlock()
+}
+
+func (t *serverTimings) entries() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.phases)
+}
+
+// StartServerTiming starts the phase of the name and returns
+// the function that ends the phase.
+// The name must be a token such as "db" or "cache".
+// Phases are written in the "Server-Timing" header
+// only when they ended before the status code is written.
+// It does nothing if the server timing is not enabled in the ctx.
+func StartServerTiming(ctx context.Context, name string) (end func()) {
+	t, ok := ctx.Value(serverTimingKey{}).(*serverTimings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.add(name, time.Since(start)) }
+}
+
+// AddServerTiming adds the phase of the name with the duration.
+// See also [StartServerTiming].
+func AddServerTiming(ctx context.Context, name string, d time.Duration) {
+	if t, ok := ctx.Value(serverTimingKey{}).(*serverTimings); ok {
+		t.add(name, d)
+	}
+}
+
+// milliseconds returns the d in milliseconds.
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
@@ -0,0 +1,144 @@
+package sampling
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdaptiveConfig is the configuration for adaptive sampling.
+// Adaptive sampling adjusts the sampling ratio of each route
+// so that the number of sampled traces per second
+// approaches the TargetPerSecond.
+type AdaptiveConfig struct {
+	// TargetPerSecond is the target number of sampled traces
+	// per second for each route. Only root spans are counted
+	// because child spans follow the decision of the root.
+	TargetPerSecond float64 `json:"targetPerSecond"`
+	// Interval is the interval to adjust ratios.
+	// It is encoded as a string such as "10s" in JSON.
+	// If zero or negative, 10 seconds is used.
+	Interval time.Duration `json:"interval"`
+	// MaxRoutes is the maximum number of routes tracked.
+	// Requests for other routes share a single ratio.
+	// If zero or negative, 100 is used.
+	MaxRoutes int `json:"maxRoutes"`
+	// RouteFunc returns the route of the request.
+	// If nil, "<method> <path>" is used.
+	// Using path templates is recommended to bound the number of routes.
+	RouteFunc func(r *http.Request) string `json:"-"`
+}
+
+// adaptiveJSON is the JSON representation of the [AdaptiveConfig]
+// which has the Interval as a duration string.
+type adaptiveJSON struct {
+	*plainAdaptiveConfig
+	Interval string `json:"interval,omitempty"`
+}
+
+// plainAdaptiveConfig is the [AdaptiveConfig] without the JSON methods.
+type plainAdaptiveConfig AdaptiveConfig
+
+func (c AdaptiveConfig) MarshalJSON() ([]byte, error) {
+	v := adaptiveJSON{plainAdaptiveConfig: (*plainAdaptiveConfig)(&c)}
+	if c.Interval != 0 {
+		v.Interval = c.Interval.String()
+	}
+	return json.Marshal(v)
+}
+
+func (c *AdaptiveConfig) UnmarshalJSON(b []byte) error {
+	v := adaptiveJSON{plainAdaptiveConfig: (*plainAdaptiveConfig)(c)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("sampling: invalid adaptive config: %w", err)
+	}
+	c.Interval = 0
+	if v.Interval != "" {
+		d, err := time.ParseDuration(v.Interval)
+		if err != nil {
+			return fmt.Errorf("sampling: invalid adaptive interval %q: %w", v.Interval, err)
+		}
+		c.Interval = d
+	}
+	return nil
+}
+
+// otherRoute is the route shared by requests of untracked routes
+// and spans not associated with requests.
+const otherRoute = "other"
+
+// adaptive calculates per route sampling ratios.
+type adaptive struct {
+	target    float64
+	interval  time.Duration
+	maxRoutes int
+	routeFunc func(r *http.Request) string
+
+	mu     sync.Mutex
+	routes map[string]*routeState
+}
+
+type routeState struct {
+	ratio float64
+	count int
+	start time.Time
+}
+
+func newAdaptive(c *AdaptiveConfig) *adaptive {
+	a := &adaptive{
+		target:    c.TargetPerSecond,
+		interval:  cmp.Or(max(c.Interval, 0), 10*time.Second),
+		maxRoutes: cmp.Or(max(c.MaxRoutes, 0), 100),
+		routeFunc: c.RouteFunc,
+		routes:    map[string]*routeState{},
+	}
+	if a.routeFunc == nil {
+		a.routeFunc = func(r *http.Request) string { return r.Method + " " + r.URL.Path }
+	}
+	return a
+}
+
+// ratio records a request to the route of the r
+// and returns the current ratio of the route.
+// The ratio is adjusted once in an interval from the observed request rate.
+func (a *adaptive) ratio(r *http.Request, now time.Time) float64 {
+	route := otherRoute
+	if r != nil {
+		route = a.routeFunc(r)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.routes[route]
+	if !ok {
+		if len(a.routes) >= a.maxRoutes {
+			route = otherRoute
+			st = a.routes[route]
+		}
+		if st == nil {
+			st = &routeState{ratio: 1, start: now}
+			a.routes[route] = st
+		}
+	}
+	st.count++
+	if elapsed := now.Sub(st.start); elapsed >= a.interval {
+		rate := float64(st.count) / elapsed.Seconds()
+		st.ratio = min(1, a.target/rate)
+		st.count = 0
+		st.start = now
+	}
+	return st.ratio
+}
+
+// ratios returns the copy of the current ratios of all routes.
+func (a *adaptive) ratios() map[string]float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m := make(map[string]float64, len(a.routes))
+	for route, st := range a.routes {
+		m[route] = st.ratio
+	}
+	return m
+}
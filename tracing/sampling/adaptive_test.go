@@ -0,0 +1,33 @@
+package sampling
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveConfig_JSON(t *testing.T) {
+	var c Config
+	in := `{"adaptive":{"targetPerSecond":5,"interval":"1m30s","maxRoutes":3}}`
+	if err := json.Unmarshal([]byte(in), &c); err != nil {
+		t.Fatal(err)
+	}
+	want := AdaptiveConfig{TargetPerSecond: 5, Interval: 90 * time.Second, MaxRoutes: 3}
+	if a := c.Adaptive; a == nil || a.TargetPerSecond != want.TargetPerSecond || a.Interval != want.Interval || a.MaxRoutes != want.MaxRoutes {
+		t.Fatalf("want %+v, got %+v", want, c.Adaptive)
+	}
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != `{"targetPerSecond":5,"maxRoutes":3,"interval":"1m30s"}` {
+		t.Errorf("unexpected JSON %s", got)
+	}
+
+	for _, in := range []string{`{"interval":10}`, `{"interval":"10"}`, `{"interval":"abc"}`} {
+		var a AdaptiveConfig
+		if err := json.Unmarshal([]byte(in), &a); err == nil {
+			t.Errorf("%s: want an error, got %+v", in, a)
+		}
+	}
+}
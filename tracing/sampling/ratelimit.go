@@ -0,0 +1,93 @@
+package sampling
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket rate limiter.
+// The bucket size is max(rate, 1) so that
+// bursts up to one second are allowed.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  max(rate, 1),
+		tokens: max(rate, 1),
+		last:   time.Now(),
+	}
+}
+
+// allow consumes a token and returns true if available.
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.After(l.last) {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// rateMeter measures the number of events per second
+// over fixed windows.
+type rateMeter struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	count int
+	rate  float64
+	// rolled is true after the first window completed.
+	rolled bool
+}
+
+func newRateMeter(window time.Duration) *rateMeter {
+	return &rateMeter{window: window, start: time.Now()}
+}
+
+// add records n events.
+func (m *rateMeter) add(now time.Time, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollLocked(now)
+	m.count += n
+}
+
+// current returns the rate of the last completed window.
+// The rate of the current window is returned
+// until the first window completes.
+func (m *rateMeter) current(now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollLocked(now)
+	if !m.rolled {
+		if elapsed := now.Sub(m.start).Seconds(); elapsed > 0 {
+			return float64(m.count) / elapsed
+		}
+	}
+	return m.rate
+}
+
+func (m *rateMeter) rollLocked(now time.Time) {
+	elapsed := now.Sub(m.start)
+	if elapsed < m.window {
+		return
+	}
+	m.rate = float64(m.count) / elapsed.Seconds()
+	m.rolled = true
+	m.count = 0
+	m.start = now
+}
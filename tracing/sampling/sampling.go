@@ -0,0 +1,331 @@
+package sampling
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	_ prometheus.Collector = &Sampler{}
+)
+
+// Rule is a sampling rule.
+// A request matches the rule when it matches all
+// of the non-empty conditions.
+type Rule struct {
+	// Methods is the list of HTTP methods such as "GET".
+	// If empty, all methods match.
+	Methods []string `json:"methods"`
+	// Routes is the list of URL path patterns.
+	// Patterns follow the syntax of [path.Match].
+	// Patterns ending with "/**" match the prefix and all paths under it.
+	// For example, "/payments/**" matches "/payments" and "/payments/a/b".
+	// If empty, all paths match.
+	Routes []string `json:"routes"`
+	// Hosts is the list of host patterns without port.
+	// Patterns follow the syntax of [path.Match]
+	// such as "*.example.com".
+	// If empty, all hosts match.
+	Hosts []string `json:"hosts"`
+	// Headers is the header conditions.
+	// Headers match when all of the headers have the given value.
+	// Empty value matches when the header exists.
+	// For example, {"X-Debug-Trace": "1"}.
+	Headers map[string]string `json:"headers"`
+	// Ratio is the sampling ratio in the range of [0.0, 1.0]
+	// applied to the matched requests. If nil, 1.0 is used.
+	Ratio *float64 `json:"ratio"`
+	// Force, if true, samples the matched requests
+	// regardless of the rate limit.
+	// It is useful for debug rules such as header rules.
+	Force bool `json:"force"`
+}
+
+// Config is the configuration for the [Sampler].
+type Config struct {
+	// Rules is the list of sampling rules.
+	// Rules are evaluated in order and the first matched rule is used.
+	Rules []Rule `json:"rules"`
+	// DefaultRatio is the sampling ratio used when
+	// no rule matched. If nil, 1.0 is used.
+	DefaultRatio *float64 `json:"defaultRatio"`
+	// ParentBased, if true, respects the sampling decision
+	// propagated from the parent and applies rules
+	// only to the root spans.
+	ParentBased bool `json:"parentBased"`
+	// RateLimit is the maximum number of sampled traces per second.
+	// Traces sampled by rules or ratios are dropped when exceeded
+	// except for the traces matched to the rules with Force.
+	// If zero or negative, sampled traces are not limited.
+	RateLimit float64 `json:"rateLimit"`
+	// Adaptive enables adaptive sampling for requests
+	// that matched no rules. If set, the DefaultRatio is not used.
+	Adaptive *AdaptiveConfig `json:"adaptive"`
+}
+
+// Sampler makes sampling decisions for HTTP requests
+// using the rules. Sampler is used by tracers and
+// the same decision is made regardless of tracing backends.
+// Use [New] to create a new instance.
+//
+// Sampler implements [prometheus.Collector] and can also
+// be registered to an OpenTelemetry meter with [Sampler.RegisterMeter].
+// Following metrics are exposed.
+//
+//   - observability_sampling_decisions_total{decision=sampled|not_sampled}: number of decisions.
+//   - observability_sampling_rate_limited_total: number of traces dropped by the rate limit.
+//   - observability_sampling_effective_rate: number of sampled traces per second.
+//   - observability_sampling_adaptive_ratio{route}: current ratio of each route of adaptive sampling.
+type Sampler struct {
+	rules       []Rule
+	def         float64
+	parentBased bool
+	limiter     *rateLimiter
+	adaptive    *adaptive
+	meter       *rateMeter
+
+	sampled     atomic.Int64
+	notSampled  atomic.Int64
+	rateLimited atomic.Int64
+
+	decisionsDesc   *prometheus.Desc
+	rateLimitedDesc *prometheus.Desc
+	rateDesc        *prometheus.Desc
+	ratioDesc       *prometheus.Desc
+}
+
+// New returns a new sampler.
+// It returns an error when the config is invalid.
+func New(c *Config) (*Sampler, error) {
+	s := &Sampler{
+		rules:           c.Rules,
+		def:             1,
+		parentBased:     c.ParentBased,
+		meter:           newRateMeter(10 * time.Second),
+		decisionsDesc:   prometheus.NewDesc("observability_sampling_decisions_total", "Number of sampling decisions", []string{"decision"}, nil),
+		rateLimitedDesc: prometheus.NewDesc("observability_sampling_rate_limited_total", "Number of traces dropped by the rate limit", nil, nil),
+		rateDesc:        prometheus.NewDesc("observability_sampling_effective_rate", "Number of sampled traces per second", nil, nil),
+		ratioDesc:       prometheus.NewDesc("observability_sampling_adaptive_ratio", "Current sampling ratio of adaptive sampling", []string{"route"}, nil),
+	}
+	if c.RateLimit > 0 {
+		s.limiter = newRateLimiter(c.RateLimit)
+	}
+	if c.Adaptive != nil {
+		if c.Adaptive.TargetPerSecond <= 0 {
+			return nil, fmt.Errorf("sampling: adaptive target must be positive but got %v", c.Adaptive.TargetPerSecond)
+		}
+		s.adaptive = newAdaptive(c.Adaptive)
+	}
+	if c.DefaultRatio != nil {
+		s.def = *c.DefaultRatio
+	}
+	if s.def < 0 || s.def > 1 {
+		return nil, fmt.Errorf("sampling: default ratio must be in [0.0, 1.0] but got %v", s.def)
+	}
+	for i, r := range c.Rules {
+		if ratio := r.ratio(); ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("sampling: rule[%d] ratio must be in [0.0, 1.0] but got %v", i, ratio)
+		}
+		for _, p := range slices.Concat(r.Routes, r.Hosts) {
+			if _, err := path.Match(strings.TrimSuffix(p, "/**"), ""); err != nil {
+				return nil, fmt.Errorf("sampling: rule[%d] invalid pattern %q: %w", i, p, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// ParentBased returns true if the sampling decision
+// of the parent should be respected.
+func (s *Sampler) ParentBased() bool {
+	return s.parentBased
+}
+
+// ratio returns the sampling ratio for the request
+// and whether the rate limit is bypassed.
+// The default or adaptive ratio is returned if the r is nil
+// or no rule matched.
+func (s *Sampler) ratio(r *http.Request, now time.Time) (float64, bool) {
+	if r != nil {
+		for _, rule := range s.rules {
+			if rule.match(r) {
+				return rule.ratio(), rule.Force
+			}
+		}
+	}
+	if s.adaptive != nil {
+		return s.adaptive.ratio(r, now), false
+	}
+	return s.def, false
+}
+
+// Sample returns the sampling decision for the request.
+// The r can be nil for spans which are not associated with requests.
+// The id is the lower 64 bits of the trace id or a random value
+// when the trace id is not determined yet.
+// The same id always gets the same decision for the same ratio
+// unless the rate limit is exceeded.
+func (s *Sampler) Sample(r *http.Request, id uint64) bool {
+	now := time.Now()
+	ratio, force := s.ratio(r, now)
+	sampled := ratio >= 1 || id>>1 < uint64(ratio*(1<<63))
+	if sampled && !force && s.limiter != nil && !s.limiter.allow(now) {
+		s.rateLimited.Add(1)
+		sampled = false
+	}
+	if sampled {
+		s.sampled.Add(1)
+		s.meter.add(now, 1)
+	} else {
+		s.notSampled.Add(1)
+	}
+	return sampled
+}
+
+// EffectiveRate returns the number of sampled traces per second
+// measured over the last 10 seconds window.
+func (s *Sampler) EffectiveRate() float64 {
+	return s.meter.current(time.Now())
+}
+
+// Describe implements [prometheus.Collector].
+func (s *Sampler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.decisionsDesc
+	ch <- s.rateLimitedDesc
+	ch <- s.rateDesc
+	ch <- s.ratioDesc
+}
+
+// Collect implements [prometheus.Collector].
+func (s *Sampler) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(s.decisionsDesc, prometheus.CounterValue, float64(s.sampled.Load()), "sampled")
+	ch <- prometheus.MustNewConstMetric(s.decisionsDesc, prometheus.CounterValue, float64(s.notSampled.Load()), "not_sampled")
+	ch <- prometheus.MustNewConstMetric(s.rateLimitedDesc, prometheus.CounterValue, float64(s.rateLimited.Load()))
+	ch <- prometheus.MustNewConstMetric(s.rateDesc, prometheus.GaugeValue, s.EffectiveRate())
+	if s.adaptive != nil {
+		for route, ratio := range s.adaptive.ratios() {
+			ch <- prometheus.MustNewConstMetric(s.ratioDesc, prometheus.GaugeValue, ratio, route)
+		}
+	}
+}
+
+// RegisterMeter registers the metrics of the sampler to the meter.
+// Call [metric.Registration.Unregister] to stop collecting them.
+func (s *Sampler) RegisterMeter(meter metric.Meter) (metric.Registration, error) {
+	decisions, err := meter.Int64ObservableCounter("observability_sampling_decisions_total",
+		metric.WithDescription("Number of sampling decisions"))
+	if err != nil {
+		return nil, err
+	}
+	rateLimited, err := meter.Int64ObservableCounter("observability_sampling_rate_limited_total",
+		metric.WithDescription("Number of traces dropped by the rate limit"))
+	if err != nil {
+		return nil, err
+	}
+	rate, err := meter.Float64ObservableGauge("observability_sampling_effective_rate",
+		metric.WithDescription("Number of sampled traces per second"))
+	if err != nil {
+		return nil, err
+	}
+	ratio, err := meter.Float64ObservableGauge("observability_sampling_adaptive_ratio",
+		metric.WithDescription("Current sampling ratio of adaptive sampling"))
+	if err != nil {
+		return nil, err
+	}
+	sampledOpt := metric.WithAttributes(attribute.String("decision", "sampled"))
+	notSampledOpt := metric.WithAttributes(attribute.String("decision", "not_sampled"))
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(decisions, s.sampled.Load(), sampledOpt)
+		o.ObserveInt64(decisions, s.notSampled.Load(), notSampledOpt)
+		o.ObserveInt64(rateLimited, s.rateLimited.Load())
+		o.ObserveFloat64(rate, s.EffectiveRate())
+		if s.adaptive != nil {
+			for route, r := range s.adaptive.ratios() {
+				o.ObserveFloat64(ratio, r, metric.WithAttributes(attribute.String("route", route)))
+			}
+		}
+		return nil
+	}, decisions, rateLimited, rate, ratio)
+}
+
+func (r *Rule) ratio() float64 {
+	if r.Ratio == nil {
+		return 1
+	}
+	return *r.Ratio
+}
+
+func (r *Rule) match(req *http.Request) bool {
+	if len(r.Methods) > 0 && !matchAny(r.Methods, func(m string) bool { return strings.EqualFold(m, req.Method) }) {
+		return false
+	}
+	if len(r.Routes) > 0 && !matchAny(r.Routes, func(p string) bool { return matchRoute(p, req.URL.Path) }) {
+		return false
+	}
+	if len(r.Hosts) > 0 {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !matchAny(r.Hosts, func(p string) bool { ok, _ := path.Match(p, host); return ok }) {
+			return false
+		}
+	}
+	for k, v := range r.Headers {
+		vals, ok := req.Header[http.CanonicalHeaderKey(k)]
+		if !ok || (v != "" && !matchAny(vals, func(val string) bool { return val == v })) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchRoute(pattern, p string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		// Match the path itself or any of its parents.
+		for ; p != "/" && p != "." && p != ""; p = path.Dir(p) {
+			if ok, _ := path.Match(prefix, p); ok {
+				return true
+			}
+		}
+		return false
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+func matchAny(vals []string, f func(string) bool) bool {
+	for _, v := range vals {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// ContextWithRequest returns a new context with the request.
+// Tracers save the server request in the context
+// so that samplers can refer to it.
+func ContextWithRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// RequestFromContext returns the request saved in the ctx.
+// It returns nil if not found.
+func RequestFromContext(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(contextKey{}).(*http.Request)
+	return r
+}
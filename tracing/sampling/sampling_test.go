@@ -0,0 +1,67 @@
+package sampling
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSampler_rules(t *testing.T) {
+	var c Config
+	in := `{
+		"rules": [
+			{"headers": {"X-Debug-Trace": "1"}, "force": true},
+			{"routes": ["/health"], "ratio": 0}
+		],
+		"defaultRatio": 0,
+		"rateLimit": 1
+	}`
+	if err := json.Unmarshal([]byte(in), &c); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	debug := httptest.NewRequest("GET", "/users", nil)
+	debug.Header.Set("X-Debug-Trace", "1")
+	for i := range 10 {
+		if !s.Sample(debug, uint64(i)) {
+			t.Fatalf("forced rule without ratio must always sample, dropped %d", i)
+		}
+	}
+	if s.Sample(httptest.NewRequest("GET", "/health", nil), 0) {
+		t.Error("rule with zero ratio must not sample")
+	}
+	if s.Sample(httptest.NewRequest("GET", "/users", nil), 0) {
+		t.Error("default ratio must be used for unmatched requests")
+	}
+	if n := s.rateLimited.Load(); n != 0 {
+		t.Errorf("forced traces must not be rate limited, got %d", n)
+	}
+}
+
+func TestSampler_rateLimit(t *testing.T) {
+	s, err := New(&Config{Rules: []Rule{{Routes: []string{"/**"}}}, RateLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/users", nil)
+	if !s.Sample(r, 0) {
+		t.Fatal("first trace must be sampled")
+	}
+	if s.Sample(r, 1) {
+		t.Error("rule without Force must be rate limited")
+	}
+	if n := s.rateLimited.Load(); n != 1 {
+		t.Errorf("want 1 rate limited, got %d", n)
+	}
+}
+
+func TestNew_invalidRuleRatio(t *testing.T) {
+	ratio := 1.5
+	if _, err := New(&Config{Rules: []Rule{{Ratio: &ratio}}}); err == nil {
+		t.Error("want an error for invalid rule ratio")
+	}
+}
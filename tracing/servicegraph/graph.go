@@ -0,0 +1,405 @@
+// Package servicegraph provides the service dependency graph
+// built from the pairs of client and server spans.
+// Tracers record spans to the [Graph] through
+// the span processor or reporter wrappers of each backend.
+package servicegraph
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+var (
+	_ prometheus.Collector = &Graph{}
+	_ spanmetrics.Recorder = &Graph{}
+	_ http.Handler         = &Graph{}
+)
+
+const (
+	// UserNode is the client name of the edges
+	// whose server spans are root spans.
+	UserNode = "user"
+	// UnknownNode is the client or server name of the edges
+	// whose counterpart span was not found.
+	UnknownNode = "unknown"
+)
+
+// Config is the configuration for the [Graph].
+type Config struct {
+	// Buckets is the buckets of the latency histograms in seconds.
+	// If empty, [prometheus.DefBuckets] is used.
+	Buckets []float64
+	// Wait is the maximum time to wait for the counterpart
+	// of a client or server span. Spans not paired within Wait
+	// are recorded with the [UnknownNode] or the peer of client spans.
+	// If zero or negative, 2 seconds is used.
+	Wait time.Duration
+	// MaxItems is the maximum number of spans waiting for the counterpart.
+	// Spans exceeding the limit are dropped.
+	// If zero or negative, 10000 is used.
+	MaxItems int
+	// MaxEdges is the maximum number of distinct edges.
+	// Spans of new edges exceeding the limit are dropped.
+	// If zero or negative, 1000 is used.
+	MaxEdges int
+}
+
+// Edge is the edge between a client and a server service.
+type Edge struct {
+	Client   string    `json:"client"`
+	Server   string    `json:"server"`
+	Requests uint64    `json:"requests"`
+	Failed   uint64    `json:"failed"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Graph builds the service graph from the finished spans.
+// Client spans are paired with the server spans that are the
+// children of them. Producer and consumer spans are paired in the same way.
+// Graph implements [prometheus.Collector] and can also
+// be registered to an OpenTelemetry meter with [Graph.RegisterMeter].
+// Graph also implements [http.Handler] which responds the edges as JSON.
+// Following metrics are recorded with the "client" and "server" labels.
+//
+//   - traces_service_graph_request_total: number of requests between services.
+//   - traces_service_graph_request_failed_total: number of failed requests.
+//   - traces_service_graph_request_server_seconds: latency of server spans.
+//   - traces_service_graph_request_client_seconds: latency of client spans.
+//
+// The traces_service_graph_dropped_spans_total counts the spans
+// dropped by the limits in the [Config].
+//
+// Server spans of requests from other processes are paired
+// only when the client spans are recorded to the same Graph.
+// Otherwise, the client is the [UserNode] for root spans
+// or the [UnknownNode] for others.
+type Graph struct {
+	requests      *prometheus.CounterVec
+	failed        *prometheus.CounterVec
+	serverLatency *prometheus.HistogramVec
+	clientLatency *prometheus.HistogramVec
+	dropped       prometheus.Counter
+	buckets       []float64
+
+	wait     time.Duration
+	maxItems int
+	maxEdges int
+
+	mu      sync.Mutex
+	pending map[key]*pair
+	queue   []queued
+	edges   map[edgeKey]*Edge
+
+	// otel is the instruments registered by RegisterMeter.
+	otel atomic.Pointer[otelInstruments]
+}
+
+type otelInstruments struct {
+	requests      metric.Int64Counter
+	failed        metric.Int64Counter
+	serverLatency metric.Float64Histogram
+	clientLatency metric.Float64Histogram
+	dropped       metric.Int64Counter
+}
+
+// key is the trace id and the span id of the client span.
+type key struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+type queued struct {
+	key    key
+	expire time.Time
+}
+
+type edgeKey struct {
+	client, server string
+}
+
+// pair is the client and server spans waiting for the counterpart.
+type pair struct {
+	expire time.Time
+	client *half
+	server *half
+}
+
+// half is the client or server side of an edge.
+type half struct {
+	service  string
+	peer     string
+	failed   bool
+	duration time.Duration
+}
+
+// New returns a new [Graph].
+func New(c *Config) *Graph {
+	labels := []string{"client", "server"}
+	buckets := c.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return &Graph{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "traces_service_graph_request_total",
+			Help: "Total number of requests between services",
+		}, labels),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "traces_service_graph_request_failed_total",
+			Help: "Total number of failed requests between services",
+		}, labels),
+		serverLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "traces_service_graph_request_server_seconds",
+			Help:    "Latency of requests between services observed by servers",
+			Buckets: buckets,
+		}, labels),
+		clientLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "traces_service_graph_request_client_seconds",
+			Help:    "Latency of requests between services observed by clients",
+			Buckets: buckets,
+		}, labels),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "traces_service_graph_dropped_spans_total",
+			Help: "Total number of spans dropped by the service graph limits",
+		}),
+		buckets:  buckets,
+		wait:     cmp.Or(max(c.Wait, 0), 2*time.Second),
+		maxItems: cmp.Or(max(c.MaxItems, 0), 10000),
+		maxEdges: cmp.Or(max(c.MaxEdges, 0), 1000),
+		pending:  map[key]*pair{},
+		edges:    map[edgeKey]*Edge{},
+	}
+}
+
+// Record records the finished span.
+// Spans other than client, server, producer and consumer spans are ignored.
+func (g *Graph) Record(ctx context.Context, s *spanmetrics.Span) {
+	h := &half{
+		service:  s.Service,
+		peer:     s.Peer,
+		failed:   s.Status == spanmetrics.StatusError,
+		duration: s.Duration,
+	}
+	var k key
+	var client bool
+	switch s.Kind {
+	case spanmetrics.KindClient, spanmetrics.KindProducer:
+		k, client = key{traceID: s.IDs.TraceID, spanID: s.IDs.SpanID}, true
+	case spanmetrics.KindServer, spanmetrics.KindConsumer:
+		k = key{traceID: s.IDs.TraceID, spanID: s.IDs.ParentID}
+	default:
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	g.expire(ctx, now)
+	if !client && s.IDs.ParentID == [8]byte{} {
+		g.complete(ctx, now, UserNode, s.Service, &pair{server: h})
+		return
+	}
+	p, ok := g.pending[k]
+	if !ok {
+		if len(g.pending) >= g.maxItems {
+			g.drop(ctx)
+			return
+		}
+		p = &pair{expire: now.Add(g.wait)}
+		g.pending[k] = p
+		g.queue = append(g.queue, queued{key: k, expire: p.expire})
+	}
+	if client {
+		p.client = h
+	} else {
+		p.server = h
+	}
+	if p.client != nil && p.server != nil {
+		delete(g.pending, k)
+		g.complete(ctx, now, p.client.service, p.server.service, p)
+	}
+}
+
+// expire records the pairs waiting longer than the wait time
+// without the counterpart. It must be called with the lock held.
+func (g *Graph) expire(ctx context.Context, now time.Time) {
+	n := 0
+	for _, q := range g.queue {
+		if q.expire.After(now) {
+			break
+		}
+		n++
+		p, ok := g.pending[q.key]
+		if !ok || !p.expire.Equal(q.expire) {
+			continue // Already paired.
+		}
+		delete(g.pending, q.key)
+		if p.client != nil {
+			g.complete(ctx, now, p.client.service, cmp.Or(p.client.peer, UnknownNode), p)
+		} else {
+			g.complete(ctx, now, UnknownNode, p.server.service, p)
+		}
+	}
+	g.queue = g.queue[n:]
+}
+
+// complete records the edge of the pair.
+// It must be called with the lock held.
+func (g *Graph) complete(ctx context.Context, now time.Time, client, server string, p *pair) {
+	ek := edgeKey{client: client, server: server}
+	e, ok := g.edges[ek]
+	if !ok {
+		if len(g.edges) >= g.maxEdges {
+			g.drop(ctx)
+			return
+		}
+		e = &Edge{Client: client, Server: server}
+		g.edges[ek] = e
+	}
+	failed := (p.client != nil && p.client.failed) || (p.server != nil && p.server.failed)
+	e.Requests++
+	e.LastSeen = now
+	g.requests.WithLabelValues(client, server).Inc()
+	if failed {
+		e.Failed++
+		g.failed.WithLabelValues(client, server).Inc()
+	}
+	if p.server != nil {
+		g.serverLatency.WithLabelValues(client, server).Observe(p.server.duration.Seconds())
+	}
+	if p.client != nil {
+		g.clientLatency.WithLabelValues(client, server).Observe(p.client.duration.Seconds())
+	}
+	if o := g.otel.Load(); o != nil {
+		opt := metric.WithAttributes(attribute.String("client", client), attribute.String("server", server))
+		o.requests.Add(ctx, 1, opt)
+		if failed {
+			o.failed.Add(ctx, 1, opt)
+		}
+		if p.server != nil {
+			o.serverLatency.Record(ctx, p.server.duration.Seconds(), opt)
+		}
+		if p.client != nil {
+			o.clientLatency.Record(ctx, p.client.duration.Seconds(), opt)
+		}
+	}
+}
+
+func (g *Graph) drop(ctx context.Context) {
+	g.dropped.Inc()
+	if o := g.otel.Load(); o != nil {
+		o.dropped.Add(ctx, 1)
+	}
+}
+
+// Edges returns the current edges sorted by the client and server names.
+// Pairs waiting longer than the wait time are recorded before returned.
+func (g *Graph) Edges() []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expire(context.Background(), time.Now())
+	edges := make([]Edge, 0, len(g.edges))
+	for _, e := range g.edges {
+		edges = append(edges, *e)
+	}
+	slices.SortFunc(edges, func(a, b Edge) int {
+		return cmp.Or(strings.Compare(a.Client, b.Client), strings.Compare(a.Server, b.Server))
+	})
+	return edges
+}
+
+// ServeHTTP responds the [Graph.Edges] as JSON.
+func (g *Graph) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	b, _ := json.MarshalIndent(map[string]any{"edges": g.Edges()}, "", "  ")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(b, '\n'))
+}
+
+// Describe implements [prometheus.Collector].
+func (g *Graph) Describe(ch chan<- *prometheus.Desc) {
+	g.requests.Describe(ch)
+	g.failed.Describe(ch)
+	g.serverLatency.Describe(ch)
+	g.clientLatency.Describe(ch)
+	g.dropped.Describe(ch)
+}
+
+// Collect implements [prometheus.Collector].
+// Pairs waiting longer than the wait time are recorded before collected.
+func (g *Graph) Collect(ch chan<- prometheus.Metric) {
+	g.mu.Lock()
+	g.expire(context.Background(), time.Now())
+	g.mu.Unlock()
+	g.requests.Collect(ch)
+	g.failed.Collect(ch)
+	g.serverLatency.Collect(ch)
+	g.clientLatency.Collect(ch)
+	g.dropped.Collect(ch)
+}
+
+// RegisterMeter registers the instruments to the meter.
+// Values are recorded to both prometheus and the meter after registered.
+// Call [metric.Registration.Unregister] to stop recording to the meter.
+func (g *Graph) RegisterMeter(meter metric.Meter) (metric.Registration, error) {
+	requests, err := meter.Int64Counter("traces_service_graph_request_total",
+		metric.WithDescription("Total number of requests between services"))
+	if err != nil {
+		return nil, err
+	}
+	failed, err := meter.Int64Counter("traces_service_graph_request_failed_total",
+		metric.WithDescription("Total number of failed requests between services"))
+	if err != nil {
+		return nil, err
+	}
+	serverLatency, err := meter.Float64Histogram("traces_service_graph_request_server_seconds",
+		metric.WithDescription("Latency of requests between services observed by servers"), metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(g.buckets...))
+	if err != nil {
+		return nil, err
+	}
+	clientLatency, err := meter.Float64Histogram("traces_service_graph_request_client_seconds",
+		metric.WithDescription("Latency of requests between services observed by clients"), metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(g.buckets...))
+	if err != nil {
+		return nil, err
+	}
+	dropped, err := meter.Int64Counter("traces_service_graph_dropped_spans_total",
+		metric.WithDescription("Total number of spans dropped by the service graph limits"))
+	if err != nil {
+		return nil, err
+	}
+	g.otel.Store(&otelInstruments{
+		requests:      requests,
+		failed:        failed,
+		serverLatency: serverLatency,
+		clientLatency: clientLatency,
+		dropped:       dropped,
+	})
+	return &registration{g: g}, nil
+}
+
+// registration stops recording to the meter when unregistered.
+type registration struct {
+	embedded.Registration
+	g *Graph
+}
+
+func (r *registration) Unregister() error {
+	r.g.otel.Store(nil)
+	return nil
+}
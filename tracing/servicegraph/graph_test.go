@@ -0,0 +1,76 @@
+package servicegraph_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/jaeger"
+	"github.com/aileron-projects/aileron-observability/tracing/otel"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/zipkin"
+	zipkingo "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"github.com/uber/jaeger-client-go/config"
+)
+
+func TestGraph_backends(t *testing.T) {
+	testCases := map[string]func(service string, g *servicegraph.Graph) (tracing.TraceMiddleware, error){
+		"otel": func(service string, g *servicegraph.Graph) (tracing.TraceMiddleware, error) {
+			return otel.New(&otel.Config{ServiceName: service, ServiceGraph: g})
+		},
+		"jaeger": func(service string, g *servicegraph.Graph) (tracing.TraceMiddleware, error) {
+			return jaeger.New(&jaeger.Config{JaegerConfig: config.Configuration{ServiceName: service}, ServiceGraph: g})
+		},
+		"zipkin": func(service string, g *servicegraph.Graph) (tracing.TraceMiddleware, error) {
+			ep, err := zipkingo.NewEndpoint(service, "")
+			if err != nil {
+				return nil, err
+			}
+			return zipkin.New(&zipkin.Config{
+				Reporter:     recorder.NewReporter(),
+				TracerOpts:   []zipkingo.TracerOption{zipkingo.WithLocalEndpoint(ep)},
+				ServiceGraph: g,
+			})
+		},
+	}
+	for name, newTracer := range testCases {
+		t.Run(name, func(t *testing.T) {
+			g := servicegraph.New(&servicegraph.Config{Wait: time.Hour})
+			a, err := newTracer("svc-a", g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Finalize(context.Background())
+			b, err := newTracer("svc-b", g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Finalize(context.Background())
+
+			svr := httptest.NewServer(b.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+			defer svr.Close()
+			client := &http.Client{Transport: a.ClientMiddleware(http.DefaultTransport)}
+			res, err := client.Get(svr.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			// The server span may end after the response is received.
+			want := servicegraph.Edge{Client: "svc-a", Server: "svc-b", Requests: 1}
+			var edges []servicegraph.Edge
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				if edges = g.Edges(); len(edges) > 0 {
+					break
+				}
+			}
+			if len(edges) != 1 || edges[0].Client != want.Client || edges[0].Server != want.Server || edges[0].Requests != want.Requests {
+				t.Errorf("want %+v, got %+v", want, edges)
+			}
+		})
+	}
+}
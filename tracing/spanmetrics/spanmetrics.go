@@ -0,0 +1,233 @@
+// Package spanmetrics provides the request, error and duration
+// metrics derived from finished spans.
+// Tracers record spans to the [Recorder] such as the [Metrics] through
+// the span processor or reporter wrappers of each backend.
+package spanmetrics
+
+import (
+	"cmp"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+var (
+	_ prometheus.Collector = &Metrics{}
+	_ Recorder             = &Metrics{}
+)
+
+// Span kinds. Values are the same as
+// the span metrics connector of the opentelemetry collector.
+const (
+	KindUnspecified = "SPAN_KIND_UNSPECIFIED"
+	KindInternal    = "SPAN_KIND_INTERNAL"
+	KindServer      = "SPAN_KIND_SERVER"
+	KindClient      = "SPAN_KIND_CLIENT"
+	KindProducer    = "SPAN_KIND_PRODUCER"
+	KindConsumer    = "SPAN_KIND_CONSUMER"
+)
+
+// Span status codes. Values are the same as
+// the span metrics connector of the opentelemetry collector.
+const (
+	StatusUnset = "STATUS_CODE_UNSET"
+	StatusOK    = "STATUS_CODE_OK"
+	StatusError = "STATUS_CODE_ERROR"
+)
+
+// OtherSpanName is the span name recorded instead of
+// span names exceeding the [Config.MaxSpanNames].
+const OtherSpanName = "other"
+
+// PeerKeys is the tag keys of the remote service name
+// of client spans in the order of priority.
+// Tracers read the [Span.Peer] from the tags.
+var PeerKeys = []string{"peer.service", "server.address", "net.peer.name", "peer.host", "rpc.service"}
+
+// Recorder records finished spans.
+// Tracers pass finished spans to recorders through
+// the span processor or reporter wrappers of each backend.
+type Recorder interface {
+	Record(ctx context.Context, s *Span)
+}
+
+// Span is the finished span.
+type Span struct {
+	// IDs is the trace id, span id and parent id of the span.
+	IDs tracing.SpanIDs
+	// Service is the service name.
+	Service string
+	// Name is the span name.
+	Name string
+	// Kind is the span kind such as [KindServer].
+	Kind string
+	// Status is the span status code such as [StatusError].
+	Status string
+	// Duration is the duration of the span.
+	Duration time.Duration
+	// Peer is the remote service name of client spans
+	// read from the [PeerKeys]. It may be empty.
+	Peer string
+}
+
+// HTTPStatus returns the span status derived from the http status code.
+// Server spans with 5xx and client spans with 4xx, 5xx or
+// without responses (code 0) are errors following the
+// opentelemetry semantic conventions. Other spans are [StatusUnset].
+// Tracers use it when the span status is not set explicitly.
+func HTTPStatus(kind string, code int) string {
+	switch {
+	case kind == KindServer && code >= 500:
+		return StatusError
+	case kind == KindClient && (code == 0 || code >= 400):
+		return StatusError
+	}
+	return StatusUnset
+}
+
+// Config is the configuration for the [Metrics].
+type Config struct {
+	// Buckets is the buckets of the duration histogram in seconds.
+	// If empty, [prometheus.DefBuckets] is used.
+	Buckets []float64
+	// MaxSpanNames is the maximum number of distinct span names.
+	// Spans with names exceeding the limit are recorded
+	// with the [OtherSpanName] to bound the cardinality.
+	// If zero or negative, 1000 is used.
+	MaxSpanNames int
+}
+
+// Metrics records the metrics of finished spans.
+// Metrics implements [prometheus.Collector] and can also
+// be registered to an OpenTelemetry meter with [Metrics.RegisterMeter].
+// Following metrics are recorded with the "service_name", "span_name",
+// "span_kind" and "status_code" labels.
+//
+//   - traces_span_metrics_calls_total: number of finished spans.
+//     Errors are the calls with status_code="STATUS_CODE_ERROR".
+//   - traces_span_metrics_duration_seconds: duration of spans.
+//
+// Only the spans sampled by tracers are recorded.
+type Metrics struct {
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	buckets  []float64
+
+	mu       sync.Mutex
+	names    map[string]struct{}
+	maxNames int
+
+	// otel is the instruments registered by RegisterMeter.
+	otel atomic.Pointer[otelInstruments]
+}
+
+type otelInstruments struct {
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// New returns a new [Metrics].
+func New(c *Config) *Metrics {
+	labels := []string{"service_name", "span_name", "span_kind", "status_code"}
+	buckets := c.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "traces_span_metrics_calls_total",
+			Help: "Total number of finished spans",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "traces_span_metrics_duration_seconds",
+			Help:    "Duration of spans",
+			Buckets: buckets,
+		}, labels),
+		buckets:  buckets,
+		names:    map[string]struct{}{},
+		maxNames: cmp.Or(max(c.MaxSpanNames, 0), 1000),
+	}
+}
+
+// Record records the finished span.
+func (m *Metrics) Record(ctx context.Context, s *Span) {
+	name := m.spanName(s.Name)
+	kind := cmp.Or(s.Kind, KindUnspecified)
+	status := cmp.Or(s.Status, StatusUnset)
+	m.calls.WithLabelValues(s.Service, name, kind, status).Inc()
+	m.duration.WithLabelValues(s.Service, name, kind, status).Observe(s.Duration.Seconds())
+	if o := m.otel.Load(); o != nil {
+		opt := metric.WithAttributes(
+			attribute.String("service_name", s.Service),
+			attribute.String("span_name", name),
+			attribute.String("span_kind", kind),
+			attribute.String("status_code", status),
+		)
+		o.calls.Add(ctx, 1, opt)
+		o.duration.Record(ctx, s.Duration.Seconds(), opt)
+	}
+}
+
+// spanName returns the name or the [OtherSpanName]
+// if the number of names exceeds the limit.
+func (m *Metrics) spanName(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.names[name]; ok {
+		return name
+	}
+	if len(m.names) >= m.maxNames {
+		return OtherSpanName
+	}
+	m.names[name] = struct{}{}
+	return name
+}
+
+// Describe implements [prometheus.Collector].
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.calls.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+// Collect implements [prometheus.Collector].
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.calls.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+// RegisterMeter registers the instruments to the meter.
+// Values are recorded to both prometheus and the meter after registered.
+// Call [metric.Registration.Unregister] to stop recording to the meter.
+func (m *Metrics) RegisterMeter(meter metric.Meter) (metric.Registration, error) {
+	calls, err := meter.Int64Counter("traces_span_metrics_calls_total",
+		metric.WithDescription("Total number of finished spans"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("traces_span_metrics_duration_seconds",
+		metric.WithDescription("Duration of spans"), metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(m.buckets...))
+	if err != nil {
+		return nil, err
+	}
+	m.otel.Store(&otelInstruments{calls: calls, duration: duration})
+	return &registration{m: m}, nil
+}
+
+// registration stops recording to the meter when unregistered.
+type registration struct {
+	embedded.Registration
+	m *Metrics
+}
+
+func (r *registration) Unregister() error {
+	r.m.otel.Store(nil)
+	return nil
+}
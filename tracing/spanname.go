@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Span naming strategies of the [SpanNameConfig].
+const (
+	// SpanNameLegacy names spans such as "server+/users/123".
+	// See [LegacySpanName].
+	SpanNameLegacy = "legacy"
+	// SpanNameRoute names spans such as "GET /users/{id}".
+	// See [RouteSpanName].
+	SpanNameRoute = "route"
+	// SpanNameTemplate names spans such as "GET /users/{id}".
+	// See [TemplateSpanName].
+	SpanNameTemplate = "template"
+	// SpanNameFixed names all spans with a fixed name.
+	// See [FixedSpanName].
+	SpanNameFixed = "fixed"
+)
+
+// SpanNameFunc returns the name of the http span of the request.
+// The kind is "server" or "client".
+// Server span names are resolved again after the handler returned
+// so that the route matched by the handler can be used.
+// The span is renamed only when the name changed.
+type SpanNameFunc func(kind string, r *http.Request) string
+
+// LegacySpanName returns the kind and the path joined by "+"
+// such as "server+/users/123". It is the default of all tracers
+// to keep the compatibility with existing dashboards.
+// The cardinality of names is unbounded if paths contain ids.
+func LegacySpanName(kind string, r *http.Request) string {
+	return kind + "+" + r.URL.Path
+}
+
+// RouteSpanName returns the method and the route pattern
+// matched by the [http.ServeMux] such as "GET /users/{id}".
+// The method and the host in the pattern are omitted.
+// It returns only the method if no pattern matched
+// following the OpenTelemetry semantic conventions.
+// Patterns are available only when the ServeMux received the same
+// request that the tracing middleware passed to the next handler.
+// Client spans are always named with the method.
+func RouteSpanName(_ string, r *http.Request) string {
+	if route := Route(r.Pattern); route != "" {
+		return r.Method + " " + route
+	}
+	return r.Method
+}
+
+// TemplateSpanName returns the method and the path template
+// such as "GET /users/{id}". See [PathTemplate].
+// It can be used for client spans and server spans
+// of requests not routed by the [http.ServeMux].
+func TemplateSpanName(_ string, r *http.Request) string {
+	return r.Method + " " + PathTemplate(r.URL.Path)
+}
+
+// FixedSpanName returns the [SpanNameFunc] that
+// always returns the name such as "http.request".
+func FixedSpanName(name string) SpanNameFunc {
+	return func(_ string, _ *http.Request) string {
+		return name
+	}
+}
+
+// SpanNameConfig is the configuration of the span naming strategy.
+type SpanNameConfig struct {
+	// Strategy is the naming strategy.
+	// Valid values are "legacy", "route", "template" and "fixed".
+	// If empty, "legacy" is used for the compatibility
+	// with existing dashboards.
+	Strategy string `json:"strategy"`
+	// Name is the span name of the "fixed" strategy.
+	// It must not be empty for the "fixed" strategy.
+	Name string `json:"name"`
+}
+
+// SpanNameFunc returns the [SpanNameFunc] of the strategy.
+// It returns [LegacySpanName] if the c is nil.
+func (c *SpanNameConfig) SpanNameFunc() (SpanNameFunc, error) {
+	if c == nil {
+		return LegacySpanName, nil
+	}
+	switch strings.ToLower(c.Strategy) {
+	case "", SpanNameLegacy:
+		return LegacySpanName, nil
+	case SpanNameRoute:
+		return RouteSpanName, nil
+	case SpanNameTemplate:
+		return TemplateSpanName, nil
+	case SpanNameFixed:
+		if c.Name == "" {
+			return nil, errors.New("tracing: fixed span name must not be empty")
+		}
+		return FixedSpanName(c.Name), nil
+	default:
+		return nil, fmt.Errorf("tracing: unknown span name strategy %q", c.Strategy)
+	}
+}
+
+// Route returns the path of the [http.ServeMux] pattern
+// such as "/users/{id}" for "GET example.com/users/{id}".
+// It returns an empty string if the pattern is empty.
+func Route(pattern string) string {
+	if _, p, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimLeft(p, " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:] // Remove host.
+	}
+	return pattern
+}
+
+// PathTemplate returns the path whose id like segments are
+// replaced with "{id}" such as "/users/{id}" for "/users/123".
+// Segments of decimal numbers, UUIDs and hex strings of
+// 16 or more characters are treated as ids.
+func PathTemplate(path string) string {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		if isID(s) {
+			segs[i] = "{id}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// isID returns true if the s looks like an id.
+func isID(s string) bool {
+	if s == "" {
+		return false
+	}
+	digits, dashes := 0, 0
+	for i := range len(s) {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digits++
+		case 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		case c == '-':
+			dashes++
+		default:
+			return false
+		}
+	}
+	switch {
+	case digits == len(s):
+		return true
+	case dashes == 4 && len(s) == 36:
+		return s[8] == '-' && s[13] == '-' && s[18] == '-' && s[23] == '-' // UUID.
+	case dashes == 0 && len(s) >= 16:
+		return true
+	}
+	return false
+}
@@ -20,6 +20,6 @@ type Tracer interface {
 type TraceMiddleware interface {
 	zhttp.ServerMiddleware
 	zhttp.ClientMiddleware
-	Finalize(context.Context)
+	Finalize(context.Context) error
 	Trace(ctx context.Context, name string, tags map[string]string) (spanCtx context.Context, finish func())
 }
@@ -0,0 +1,391 @@
+// Package tracingtest provides an in-memory tracer for testing
+// code that depends on [tracing.Tracer] or [tracing.TraceMiddleware].
+package tracingtest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/go/znet/zhttp"
+)
+
+var (
+	_ tracing.Tracer          = &Recorder{}
+	_ tracing.TraceMiddleware = &Recorder{}
+	_ tracing.IDTracer        = &Recorder{}
+)
+
+// SpanKind is the kind of spans.
+type SpanKind string
+
+const (
+	SpanKindServer   SpanKind = "server"
+	SpanKindClient   SpanKind = "client"
+	SpanKindInternal SpanKind = "internal"
+)
+
+// StatusCode is the status code of spans.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// Status is the status of a span.
+type Status struct {
+	Code        StatusCode
+	Description string
+}
+
+// Event is an event recorded in a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]string
+}
+
+// Span is a recorded span.
+type Span struct {
+	tracing.SpanIDs
+	Name       string
+	Kind       SpanKind
+	Attributes map[string]string
+	Events     []Event
+	Status     Status
+	Start      time.Time
+	End        time.Time
+}
+
+// TraceIDString returns the trace id in hex format.
+func (s *Span) TraceIDString() string {
+	return hex.EncodeToString(s.TraceID[:])
+}
+
+// SpanIDString returns the span id in hex format.
+func (s *Span) SpanIDString() string {
+	return hex.EncodeToString(s.SpanID[:])
+}
+
+// IsRoot returns true if the span has no parent.
+func (s *Span) IsRoot() bool {
+	return s.ParentID == [8]byte{}
+}
+
+// HasAttributes returns true if the span has all the attributes.
+func (s *Span) HasAttributes(attrs map[string]string) bool {
+	for k, v := range attrs {
+		if got, ok := s.Attributes[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Span) clone() Span {
+	c := *s
+	c.Attributes = maps.Clone(s.Attributes)
+	c.Events = slices.Clone(s.Events)
+	return c
+}
+
+// RecordingSpan is a span being recorded.
+// Use [SpanFromContext] to get the current span
+// to add attributes, events or status.
+type RecordingSpan struct {
+	mu   sync.Mutex
+	span Span
+	rec  *Recorder
+}
+
+// SetAttribute sets the attribute to the span.
+func (s *RecordingSpan) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+// AddEvent adds an event to the span.
+func (s *RecordingSpan) AddEvent(name string, attrs map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Events = append(s.span.Events, Event{Name: name, Time: time.Now(), Attributes: maps.Clone(attrs)})
+}
+
+// SetStatus sets the status of the span.
+func (s *RecordingSpan) SetStatus(code StatusCode, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Status = Status{Code: code, Description: description}
+}
+
+// Snapshot returns the copy of the current state of the span.
+func (s *RecordingSpan) Snapshot() Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.span.clone()
+}
+
+// Finish ends the span and records it to the recorder.
+// Calling Finish more than once has no effect.
+func (s *RecordingSpan) Finish() {
+	s.mu.Lock()
+	if !s.span.End.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.span.End = time.Now()
+	span := s.span.clone()
+	s.mu.Unlock()
+	s.rec.mu.Lock()
+	s.rec.spans = append(s.rec.spans, span)
+	s.rec.mu.Unlock()
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span in the ctx.
+// It returns nil if not found.
+func SpanFromContext(ctx context.Context) *RecordingSpan {
+	s, _ := ctx.Value(spanKey{}).(*RecordingSpan)
+	return s
+}
+
+// Recorder is the tracer that records spans in memory.
+// Trace context is propagated with the W3C traceparent header.
+// Use [NewRecorder] to create a new instance.
+type Recorder struct {
+	mu        sync.Mutex
+	spans     []Span
+	finalized bool
+}
+
+// NewRecorder returns a new [Recorder].
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) ServerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if SpanFromContext(ctx) == nil {
+			if ids, ok := extract(req.Header); ok {
+				ctx = tracing.ContextWithSpanIDs(ctx, ids)
+			}
+		}
+		span, ctx := r.start(ctx, "server+"+req.URL.Path, SpanKindServer)
+		defer span.Finish()
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.path", req.URL.Path)
+		span.SetAttribute("http.query", req.URL.RawQuery)
+		span.SetAttribute("net.host", req.Host)
+		ww := zhttp.WrapResponseWriter(w)
+		next.ServeHTTP(ww, req.WithContext(ctx))
+		span.SetAttribute("http.route", req.Pattern)
+		span.SetAttribute("http.status_code", strconv.Itoa(ww.StatusCode()))
+		if ww.StatusCode() >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, http.StatusText(ww.StatusCode()))
+		}
+	})
+}
+
+func (r *Recorder) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
+	return zhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		span, ctx := r.start(req.Context(), "client+"+req.URL.Path, SpanKindClient)
+		defer span.Finish()
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.path", req.URL.Path)
+		span.SetAttribute("peer.host", req.URL.Host)
+		req = req.Clone(ctx)
+		inject(req.Header, span.Snapshot().SpanIDs)
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			span.AddEvent("exception", map[string]string{"exception.message": err.Error()})
+			span.SetStatus(StatusError, err.Error())
+			return res, err
+		}
+		span.SetAttribute("http.status_code", strconv.Itoa(res.StatusCode))
+		if res.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, http.StatusText(res.StatusCode))
+		}
+		return res, nil
+	})
+}
+
+// Trace starts a new internal span with the tags as attributes.
+func (r *Recorder) Trace(ctx context.Context, name string, tags map[string]string) (spanCtx context.Context, finish func()) {
+	span, ctx := r.start(ctx, name, SpanKindInternal)
+	for k, v := range tags {
+		span.SetAttribute(k, v)
+	}
+	return ctx, span.Finish
+}
+
+// SpanIDs returns the ids of the current span in the ctx.
+func (r *Recorder) SpanIDs(ctx context.Context) (tracing.SpanIDs, bool) {
+	s := SpanFromContext(ctx)
+	if s == nil {
+		return tracing.SpanIDs{}, false
+	}
+	return s.Snapshot().SpanIDs, true
+}
+
+// Finalize marks the recorder finalized.
+// Recorded spans are kept.
+func (r *Recorder) Finalize(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finalized = true
+	return nil
+}
+
+// Finalized returns true if the Finalize was called.
+func (r *Recorder) Finalized() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finalized
+}
+
+// start starts a new span. The ids given by [tracing.ContextWithSpanIDs]
+// are used if found in the ctx.
+func (r *Recorder) start(ctx context.Context, name string, kind SpanKind) (*RecordingSpan, context.Context) {
+	ids, ok := tracing.SpanIDsFromContext(ctx)
+	if ok {
+		ctx = tracing.ContextWithSpanIDs(ctx, tracing.SpanIDs{}) // Consume the ids.
+	} else if parent := SpanFromContext(ctx); parent != nil {
+		p := parent.Snapshot()
+		ids = tracing.SpanIDs{TraceID: p.TraceID, SpanID: newSpanID(), ParentID: p.SpanID, Sampled: p.Sampled}
+	} else {
+		ids = tracing.SpanIDs{SpanID: newSpanID(), Sampled: true}
+		binary.BigEndian.PutUint64(ids.TraceID[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(ids.TraceID[8:], rand.Uint64()|1)
+	}
+	s := &RecordingSpan{
+		span: Span{
+			SpanIDs:    ids,
+			Name:       name,
+			Kind:       kind,
+			Attributes: map[string]string{},
+			Start:      time.Now(),
+		},
+		rec: r,
+	}
+	return s, context.WithValue(ctx, spanKey{}, s)
+}
+
+// Spans returns the finished spans in the order they finished.
+func (r *Recorder) Spans() []Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.spans)
+}
+
+// FindSpans returns the finished spans with the name.
+func (r *Recorder) FindSpans(name string) []Span {
+	var spans []Span
+	for _, s := range r.Spans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Parent returns the finished parent span of the s.
+// It returns false if the s is a root span or the parent is not finished.
+func (r *Recorder) Parent(s Span) (Span, bool) {
+	return r.find(func(p Span) bool { return p.TraceID == s.TraceID && p.SpanID == s.ParentID })
+}
+
+// Children returns the finished child spans of the s.
+func (r *Recorder) Children(s Span) []Span {
+	var spans []Span
+	for _, c := range r.Spans() {
+		if c.TraceID == s.TraceID && c.ParentID == s.SpanID {
+			spans = append(spans, c)
+		}
+	}
+	return spans
+}
+
+// Reset removes all recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+// AssertSpan asserts that a finished span with the name
+// and all of the attributes exists and returns the first one found.
+// The test fails immediately if not found.
+func (r *Recorder) AssertSpan(t testing.TB, name string, attrs map[string]string) Span {
+	t.Helper()
+	s, ok := r.find(func(s Span) bool { return s.Name == name && s.HasAttributes(attrs) })
+	if !ok {
+		names := make([]string, 0, len(r.Spans()))
+		for _, s := range r.Spans() {
+			names = append(names, strconv.Quote(s.Name))
+		}
+		t.Fatalf("tracingtest: span %q with attributes %v not found in [%s]", name, attrs, strings.Join(names, ", "))
+	}
+	return s
+}
+
+func (r *Recorder) find(f func(Span) bool) (Span, bool) {
+	for _, s := range r.Spans() {
+		if f(s) {
+			return s, true
+		}
+	}
+	return Span{}, false
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], rand.Uint64()|1)
+	return id
+}
+
+// extract extracts the ids from the W3C traceparent header.
+// The returned ids have the parent span id as the ParentID
+// and a new span id.
+func extract(h http.Header) (tracing.SpanIDs, bool) {
+	parts := strings.Split(h.Get("traceparent"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tracing.SpanIDs{}, false
+	}
+	ids := tracing.SpanIDs{SpanID: newSpanID()}
+	if _, err := hex.Decode(ids.TraceID[:], []byte(parts[1])); err != nil {
+		return tracing.SpanIDs{}, false
+	}
+	if _, err := hex.Decode(ids.ParentID[:], []byte(parts[2])); err != nil {
+		return tracing.SpanIDs{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return tracing.SpanIDs{}, false
+	}
+	ids.Sampled = flags&1 == 1
+	return ids, ids.IsValid() && ids.ParentID != [8]byte{}
+}
+
+// inject sets the W3C traceparent header.
+func inject(h http.Header, ids tracing.SpanIDs) {
+	flags := "00"
+	if ids.Sampled {
+		flags = "01"
+	}
+	h.Set("traceparent", "00-"+hex.EncodeToString(ids.TraceID[:])+"-"+hex.EncodeToString(ids.SpanID[:])+"-"+flags)
+}
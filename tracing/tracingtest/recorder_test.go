@@ -0,0 +1,198 @@
+package tracingtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+)
+
+// fatalTB records the failure instead of stopping the test.
+type fatalTB struct {
+	testing.TB
+	msg string
+}
+
+func (t *fatalTB) Helper() {}
+
+func (t *fatalTB) Fatalf(format string, args ...any) {
+	t.msg = fmt.Sprintf(format, args...)
+}
+
+func TestRecorder_middleware(t *testing.T) {
+	rec := NewRecorder()
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", rec.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if SpanFromContext(r.Context()) == nil {
+			t.Error("server span not found in the request context")
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})))
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+	client := &http.Client{Transport: rec.ClientMiddleware(http.DefaultTransport)}
+
+	res, err := client.Get(svr.URL + "/users/1?q=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	server := rec.AssertSpan(t, "server+/users/1", map[string]string{
+		"http.method":      http.MethodGet,
+		"http.query":       "q=x",
+		"http.route":       "GET /users/{id}",
+		"http.status_code": "500",
+	})
+	cs := rec.AssertSpan(t, "client+/users/1", map[string]string{"http.status_code": "500"})
+	if server.Kind != SpanKindServer || cs.Kind != SpanKindClient {
+		t.Errorf("unexpected kinds %s and %s", server.Kind, cs.Kind)
+	}
+	if server.Status.Code != StatusError || cs.Status.Code != StatusError {
+		t.Errorf("5xx must be an error: %v %v", server.Status, cs.Status)
+	}
+	if p, ok := rec.Parent(server); !ok || p.SpanID != cs.SpanID || server.TraceIDString() != cs.TraceIDString() {
+		t.Error("server span must be the child of the client span")
+	}
+	if !cs.IsRoot() || server.IsRoot() {
+		t.Error("only the client span must be the root")
+	}
+}
+
+func TestRecorder_clientError(t *testing.T) {
+	rec := NewRecorder()
+	client := &http.Client{Transport: rec.ClientMiddleware(http.DefaultTransport)}
+	if _, err := client.Get("http://127.0.0.1:0/"); err == nil {
+		t.Fatal("want an error")
+	}
+	s := rec.AssertSpan(t, "client+/", nil)
+	if s.Status.Code != StatusError || len(s.Events) != 1 || s.Events[0].Name != "exception" {
+		t.Errorf("failed request must be recorded as an error: %v %v", s.Status, s.Events)
+	}
+}
+
+func TestRecorder_Trace(t *testing.T) {
+	rec := NewRecorder()
+	ctx, finishRoot := rec.Trace(context.Background(), "root", map[string]string{"key": "root"})
+	for i := range 2 {
+		childCtx, finish := rec.Trace(ctx, "child", map[string]string{"key": fmt.Sprint(i)})
+		SpanFromContext(childCtx).AddEvent("event", map[string]string{"i": fmt.Sprint(i)})
+		finish()
+		finish() // Finishing twice has no effect.
+	}
+	if _, ok := rec.Parent(rec.FindSpans("child")[0]); ok {
+		t.Error("parent must not be found before finished")
+	}
+	finishRoot()
+
+	if n := len(rec.Spans()); n != 3 {
+		t.Fatalf("want 3 spans, got %d", n)
+	}
+	children := rec.FindSpans("child")
+	if len(children) != 2 || children[0].Attributes["key"] != "0" || children[1].Attributes["key"] != "1" {
+		t.Fatalf("children must be found in the order finished: %v", children)
+	}
+	root := rec.AssertSpan(t, "root", map[string]string{"key": "root"})
+	if got := rec.Children(root); len(got) != 2 {
+		t.Errorf("want 2 children, got %d", len(got))
+	}
+	for _, c := range children {
+		if p, ok := rec.Parent(c); !ok || p.SpanID != root.SpanID || p.TraceID != c.TraceID {
+			t.Errorf("child %s must have the root parent", c.SpanIDString())
+		}
+		if len(c.Events) != 1 || c.Events[0].Name != "event" {
+			t.Errorf("unexpected events %v", c.Events)
+		}
+	}
+	if got := rec.FindSpans("unknown"); got != nil {
+		t.Errorf("want no spans, got %v", got)
+	}
+
+	rec.Reset()
+	if n := len(rec.Spans()); n != 0 {
+		t.Errorf("want no spans after reset, got %d", n)
+	}
+	if rec.Finalized() {
+		t.Error("must not be finalized")
+	}
+	_ = rec.Finalize(context.Background())
+	if !rec.Finalized() {
+		t.Error("must be finalized")
+	}
+}
+
+func TestRecorder_AssertSpan(t *testing.T) {
+	rec := NewRecorder()
+	_, finish := rec.Trace(context.Background(), "op", map[string]string{"a": "1", "b": "2"})
+	finish()
+
+	if s := rec.AssertSpan(t, "op", map[string]string{"a": "1"}); s.Name != "op" {
+		t.Errorf("want span op, got %q", s.Name)
+	}
+	testCases := map[string]struct {
+		name  string
+		attrs map[string]string
+	}{
+		"unknown name":       {"unknown", nil},
+		"unknown attribute":  {"op", map[string]string{"c": "3"}},
+		"different value":    {"op", map[string]string{"a": "2"}},
+		"partially matching": {"op", map[string]string{"a": "1", "b": "3"}},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tb := &fatalTB{TB: t}
+			rec.AssertSpan(tb, tc.name, tc.attrs)
+			if !strings.Contains(tb.msg, `not found in ["op"]`) {
+				t.Errorf("unexpected failure message %q", tb.msg)
+			}
+		})
+	}
+}
+
+func TestRecorder_SpanIDs(t *testing.T) {
+	rec := NewRecorder()
+	if _, ok := rec.SpanIDs(context.Background()); ok {
+		t.Error("ids must not be found without spans")
+	}
+	ids := tracing.SpanIDs{TraceID: [16]byte{1}, SpanID: [8]byte{2}, ParentID: [8]byte{3}, Sampled: true}
+	ctx, finish := rec.Trace(tracing.ContextWithSpanIDs(context.Background(), ids), "op", nil)
+	finish()
+	if got, ok := rec.SpanIDs(ctx); !ok || got != ids {
+		t.Errorf("want the given ids %v, got %v", ids, got)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	testCases := map[string]struct {
+		header string
+		ok     bool
+	}{
+		"valid":         {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true},
+		"empty":         {"", false},
+		"invalid hex":   {"00-0af7651916cd43dd8448eb211c80319x-b7ad6b7169203331-01", false},
+		"zero trace id": {"00-00000000000000000000000000000000-b7ad6b7169203331-01", false},
+		"zero span id":  {"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", false},
+		"short":         {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331", false},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{"Traceparent": {tc.header}}
+			ids, ok := extract(h)
+			if ok != tc.ok {
+				t.Fatalf("want %v, got %v", tc.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			h2 := http.Header{}
+			inject(h2, tracing.SpanIDs{TraceID: ids.TraceID, SpanID: ids.ParentID, Sampled: ids.Sampled})
+			if got := h2.Get("traceparent"); got != tc.header {
+				t.Errorf("want %s, got %s", tc.header, got)
+			}
+		})
+	}
+}
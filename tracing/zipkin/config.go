@@ -1,33 +1,210 @@
 package zipkin
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"slices"
 
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
 	"github.com/openzipkin/zipkin-go"
 	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 )
 
 type Config struct {
+	// Reporter is the span reporter.
+	// If nil, an http reporter which sends spans to the
+	// HTTPEndpoint is created and monitored by the [Tracer.Monitor].
+	// Given reporter is not monitored.
+	// Reporter is closed by [Tracer.Finalize], or by [New]
+	// if it fails after the reporter is set up.
 	Reporter   reporter.Reporter
 	TracerOpts []zipkin.TracerOption
 
+	// HTTPEndpoint is the url of the zipkin collector
+	// such as "http://localhost:9411/api/v2/spans".
+	// It is used when the Reporter is nil.
+	HTTPEndpoint string
+	// HTTPClient is the client used by the http reporter.
+	// If nil, [http.DefaultClient] is used.
+	HTTPClient zipkinhttp.HTTPDoer
+	// HTTPOpts is the options for the http reporter.
+	// Client and Logger options are overwritten
+	// to monitor the reporter.
+	HTTPOpts []zipkinhttp.ReporterOption
+	// Persistence enables the disk queue for the http reporter.
+	// Batches failed to be sent to the HTTPEndpoint are stored
+	// in the disk and are replayed when the collector recovered.
+	// Persisted spans are recorded by the [Tracer.Monitor]
+	// as exported or failed when they are replayed.
+	// See [PersistentClient].
+	Persistence *diskqueue.Config
+	// ErrorHandler handles errors of the http reporter.
+	// Errors are recorded in the [Tracer.Monitor] regardless of ErrorHandler.
+	ErrorHandler health.ErrorHandler
+	// Sampling is the rule based sampling configuration.
+	// If set, sampling decisions of server spans are made by the rules.
+	// Other root spans are sampled by the [RuleSampler] unless
+	// a sampler is configured by TracerOpts.
+	Sampling *sampling.Config
+
+	// Propagation is the list of trace context propagation formats.
+	// Trace context is extracted trying the formats in the order
+	// and injected in all the formats.
+	// If empty, B3 single and multi headers are extracted
+	// and B3 multi headers are injected.
+	// Baggage is propagated in the W3C baggage format by default.
+	Propagation []Format
+	// BaggageKeys is the list of baggage keys copied to
+	// the tags of spans when the spans are started.
+	BaggageKeys []string
+
+	// ResponseHeaders writes the trace id of the root server spans
+	// in the response headers. If nil, nothing is written.
+	ResponseHeaders *tracing.ResponseHeaderConfig
+
+	// SpanMetrics records the request, error and duration metrics
+	// of the finished spans with the [SpanMetricsReporter].
+	// If nil, metrics are not recorded.
+	SpanMetrics *spanmetrics.Metrics
+	// ServiceGraph records the service graph built from the
+	// pairs of client and server spans with the [SpanMetricsReporter].
+	// If nil, the graph is not recorded.
+	ServiceGraph *servicegraph.Graph
+	// Redactor redacts the span names and tags with the [RedactingReporter]
+	// before the spans are sent to the reporter, the SpanMetrics
+	// and the ServiceGraph. If nil, spans are not redacted.
+	Redactor *redact.Redactor
+
+	// AddCaller, if true, add caller info
+	// to the root span tag.
 	AddCaller bool
+	// Caller configures the caller capture of the AddCaller.
+	// If nil, frames of the [tracing.DefaultCallerSkipPrefixes]
+	// are skipped and the stack is not recorded.
+	Caller *tracing.CallerConfig
+	// SpanNameFunc returns the names of http spans.
+	// If nil, [tracing.LegacySpanName] is used for the compatibility
+	// with existing dashboards. Use [tracing.RouteSpanName] or
+	// [tracing.TemplateSpanName] for low cardinality names.
+	SpanNameFunc tracing.SpanNameFunc
+	// RecordPanics, if true, records panics in the server middleware
+	// as "error" tags and annotations of the spans with the status code 500
+	// and then panics again with the [recovery.Panic].
+	// Use the [recovery.Middleware] to reply 500 or to re-panic.
+	RecordPanics bool
 
 	ServerSpanHook func(span zipkin.Span, w http.ResponseWriter, r *http.Request)
 	ClientSpanHook func(span zipkin.Span, w *http.Response, r *http.Request)
 }
 
 func New(c *Config) (*Tracer, error) {
-	tracer, err := zipkin.NewTracer(c.Reporter, c.TracerOpts...)
+	prop, err := newPropagator(c.Propagation)
 	if err != nil {
 		return nil, err
 	}
+	monitor := health.NewMonitor(&health.Config{
+		Exporter:     "zipkin",
+		Signal:       "spans",
+		ErrorHandler: c.ErrorHandler,
+	})
+	tracerOpts := c.TracerOpts
+	var sampler *sampling.Sampler
+	if c.Sampling != nil {
+		s, err := sampling.New(c.Sampling)
+		if err != nil {
+			return nil, err
+		}
+		sampler = s
+		tracerOpts = slices.Concat([]zipkin.TracerOption{zipkin.WithSampler(RuleSampler(s))}, c.TracerOpts)
+	}
+	rep := c.Reporter
+	var persist *PersistentClient
+	if rep == nil {
+		if c.HTTPEndpoint == "" {
+			return nil, errors.New("zipkin: reporter or http endpoint must be configured")
+		}
+		var client zipkinhttp.HTTPDoer = http.DefaultClient
+		if c.HTTPClient != nil {
+			client = c.HTTPClient
+		}
+		if c.Persistence != nil {
+			pc, err := newPersistentClient(client, c.Persistence, monitor)
+			if err != nil {
+				return nil, err
+			}
+			persist, client = pc, pc
+		} else {
+			client = &monitoredDoer{next: client, monitor: monitor}
+		}
+		opts := slices.Concat(c.HTTPOpts, []zipkinhttp.ReporterOption{
+			zipkinhttp.Client(client),
+			zipkinhttp.Logger(log.New(&logWriter{monitor: monitor}, "", 0)),
+		})
+		rep = &monitoredReporter{
+			Reporter: zipkinhttp.NewReporter(c.HTTPEndpoint, opts...),
+			monitor:  monitor,
+		}
+	}
+	var recorders []spanmetrics.Recorder
+	if c.SpanMetrics != nil {
+		recorders = append(recorders, c.SpanMetrics)
+	}
+	if c.ServiceGraph != nil {
+		recorders = append(recorders, c.ServiceGraph)
+	}
+	if len(recorders) > 0 {
+		rep = NewSpanMetricsReporter(rep, recorders...)
+	}
+	if c.Redactor != nil {
+		rep = NewRedactingReporter(rep, c.Redactor)
+	}
+	tracer, err := zipkin.NewTracer(rep, tracerOpts...)
+	if err != nil {
+		closeReporter(rep, persist)
+		return nil, err
+	}
+	// Joined server spans are started as child spans of the remote parent
+	// rather than sharing the span id so that the ids are consistent
+	// with the tracer that created them.
+	gen := &fixedIDGenerator{}
+	jt, err := zipkin.NewTracer(rep, slices.Concat(tracerOpts, []zipkin.TracerOption{
+		zipkin.WithSharedSpans(false),
+		zipkin.WithIDGenerator(gen),
+	})...)
+	if err != nil {
+		closeReporter(rep, persist)
+		return nil, err
+	}
 	t := &Tracer{
-		tracer:         tracer,
-		reporter:       c.Reporter,
-		addCaller:      c.AddCaller,
-		serverSpanHook: c.ServerSpanHook,
-		clientSpanHook: c.ClientSpanHook,
+		tracer:          tracer,
+		reporter:        rep,
+		join:            &joinTracer{gen: gen, tracer: jt},
+		prop:            prop,
+		baggageKeys:     c.BaggageKeys,
+		monitor:         monitor,
+		persist:         persist,
+		sampler:         sampler,
+		spanMetrics:     c.SpanMetrics,
+		serviceGraph:    c.ServiceGraph,
+		spanName:        c.SpanNameFunc,
+		recordPanics:    c.RecordPanics,
+		responseHeaders: c.ResponseHeaders,
+		serverSpanHook:  c.ServerSpanHook,
+		clientSpanHook:  c.ClientSpanHook,
+	}
+	if c.AddCaller {
+		t.caller = tracing.NewCallerCapture(c.Caller)
+	}
+	if t.spanName == nil {
+		t.spanName = tracing.LegacySpanName
 	}
 	if t.serverSpanHook == nil {
 		t.serverSpanHook = serverSpanHook
@@ -37,3 +214,12 @@ func New(c *Config) (*Tracer, error) {
 	}
 	return t, nil
 }
+
+// closeReporter closes the reporter and the persistent client
+// created by [New] when it fails after they are set up.
+func closeReporter(rep reporter.Reporter, persist *PersistentClient) {
+	_ = rep.Close()
+	if persist != nil {
+		_ = persist.Close()
+	}
+}
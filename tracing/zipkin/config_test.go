@@ -0,0 +1,108 @@
+package zipkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestNew_config(t *testing.T) {
+	httpOpts := make([]zipkinhttp.ReporterOption, 1, 4)
+	httpOpts[0] = zipkinhttp.BatchSize(1)
+	tracerOpts := make([]zipkin.TracerOption, 1, 4)
+	tracerOpts[0] = zipkin.WithTraceID128Bit(true)
+	c := &Config{
+		HTTPEndpoint: "http://localhost:9411/api/v2/spans",
+		HTTPOpts:     httpOpts,
+		TracerOpts:   tracerOpts,
+		Sampling:     &sampling.Config{},
+	}
+	tr, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Finalize(context.Background())
+	if len(c.HTTPOpts) != 1 || httpOpts[:4][1] != nil {
+		t.Error("http options of the config must not be modified")
+	}
+	if len(c.TracerOpts) != 1 || tracerOpts[:4][1] != nil {
+		t.Error("tracer options of the config must not be modified")
+	}
+}
+
+func TestNew_sharedSpans(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, err := New(&Config{Reporter: rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Finalize(context.Background())
+	h := tr.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Server spans share the span id of the remote client span by default.
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	r.Header.Set("X-B3-TraceId", "0000000000000001")
+	r.Header.Set("X-B3-SpanId", "0000000000000002")
+	r.Header.Set("X-B3-Sampled", "1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	spans := rec.Flush()
+	if len(spans) != 1 || spans[0].ID != model.ID(2) || !spans[0].Shared {
+		t.Errorf("server span must share the id of the remote span: %+v", spans)
+	}
+
+	// Joined server spans use the given ids.
+	ids := tracing.SpanIDs{
+		TraceID:  [16]byte{15: 1},
+		SpanID:   [8]byte{7: 3},
+		ParentID: [8]byte{7: 2},
+		Sampled:  true,
+	}
+	r = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	r = r.WithContext(tracing.ContextWithSpanIDs(r.Context(), ids))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	spans = rec.Flush()
+	if len(spans) != 1 || spans[0].ID != model.ID(3) || spans[0].ParentID == nil || *spans[0].ParentID != model.ID(2) {
+		t.Errorf("joined span must have the given ids: %+v", spans)
+	}
+}
+
+// closeCountReporter counts the number of Close calls.
+type closeCountReporter struct {
+	*recorder.ReporterRecorder
+	closed int
+}
+
+func (r *closeCountReporter) Close() error {
+	r.closed++
+	return r.ReporterRecorder.Close()
+}
+
+func TestNew_closeOnError(t *testing.T) {
+	testCases := map[string]struct {
+		config *Config
+		closed int
+	}{
+		"invalid tracer option": {&Config{TracerOpts: []zipkin.TracerOption{zipkin.WithExtractFailurePolicy(-1)}}, 1},
+		"invalid sampling":      {&Config{Sampling: &sampling.Config{Adaptive: &sampling.AdaptiveConfig{}}}, 0},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rep := &closeCountReporter{ReporterRecorder: recorder.NewReporter()}
+			tc.config.Reporter = rep
+			if _, err := New(tc.config); err == nil {
+				t.Fatal("want an error")
+			}
+			if rep.closed != tc.closed {
+				t.Errorf("want %d close, got %d", tc.closed, rep.closed)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Format is the trace context propagation format.
+// Values are the same as the ones of the OTEL_PROPAGATORS
+// environment variable.
+type Format string
+
+const (
+	// FormatB3Multi is the B3 multiple headers format
+	// such as "X-B3-TraceId" and "X-B3-SpanId".
+	FormatB3Multi Format = "b3multi"
+	// FormatB3Single is the B3 single header format
+	// which uses the "b3" header.
+	FormatB3Single Format = "b3"
+	// FormatTraceContext is the W3C trace context format
+	// which uses the "traceparent" header.
+	FormatTraceContext Format = "tracecontext"
+	// FormatBaggage is the W3C baggage format
+	// which uses the "baggage" header.
+	// Baggage is stored in the context in the same way as opentelemetry.
+	FormatBaggage Format = "baggage"
+)
+
+// propagator injects and extracts trace context
+// in multiple formats.
+type propagator struct {
+	// extract is the formats to extract in priority order.
+	extract []Format
+	// inject is the formats to inject.
+	inject []Format
+}
+
+func newPropagator(formats []Format) (*propagator, error) {
+	if len(formats) == 0 {
+		// Same as the b3.ExtractHTTP and b3.InjectHTTP
+		// in addition to the baggage.
+		return &propagator{
+			extract: []Format{FormatB3Single, FormatB3Multi, FormatBaggage},
+			inject:  []Format{FormatB3Multi, FormatBaggage},
+		}, nil
+	}
+	for _, f := range formats {
+		switch f {
+		case FormatB3Multi, FormatB3Single, FormatTraceContext, FormatBaggage:
+		default:
+			return nil, fmt.Errorf("zipkin: unsupported propagation format %q", f)
+		}
+	}
+	return &propagator{extract: formats, inject: formats}, nil
+}
+
+// Inject injects the sc and the baggage in the ctx into the carrier.
+func (p *propagator) Inject(ctx context.Context, sc model.SpanContext, carrier tracing.Carrier) {
+	valid := !sc.TraceID.Empty() && sc.ID != 0
+	for _, f := range p.inject {
+		switch f {
+		case FormatB3Multi:
+			if valid {
+				m := b3.Map{}
+				_ = m.Inject()(sc)
+				for k, v := range m {
+					carrier.Set(k, v)
+				}
+			}
+		case FormatB3Single:
+			if valid {
+				carrier.Set(b3.Context, b3.BuildSingleHeader(sc))
+			}
+		case FormatTraceContext:
+			if valid {
+				ctx := trace.ContextWithRemoteSpanContext(context.Background(), toOTel(sc))
+				propagation.TraceContext{}.Inject(ctx, carrier)
+			}
+		case FormatBaggage:
+			tracing.InjectBaggage(ctx, carrier)
+		}
+	}
+}
+
+// Extract extracts the span context from the carrier trying
+// the formats in the priority order.
+// The returned context has the baggage extracted from the carrier
+// if the baggage format is configured.
+// The Err of the returned span context is [b3.ErrEmptyContext]
+// if no trace context found.
+func (p *propagator) Extract(ctx context.Context, carrier tracing.Carrier) (context.Context, model.SpanContext) {
+	var found bool
+	var sc model.SpanContext
+	var errs []error
+	for _, f := range p.extract {
+		if f == FormatBaggage {
+			ctx = tracing.ExtractBaggage(ctx, carrier)
+			continue
+		}
+		if found {
+			continue
+		}
+		var psc *model.SpanContext
+		var err error
+		switch f {
+		case FormatB3Multi:
+			if carrier.Get(b3.TraceID) == "" && carrier.Get(b3.SpanID) == "" &&
+				carrier.Get(b3.Sampled) == "" && carrier.Get(b3.Flags) == "" {
+				continue
+			}
+			psc, err = b3.ParseHeaders(carrier.Get(b3.TraceID), carrier.Get(b3.SpanID),
+				carrier.Get(b3.ParentSpanID), carrier.Get(b3.Sampled), carrier.Get(b3.Flags))
+		case FormatB3Single:
+			v := carrier.Get(b3.Context)
+			if v == "" {
+				continue
+			}
+			psc, err = b3.ParseSingleHeader(v)
+		case FormatTraceContext:
+			tsc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+			if !tsc.IsValid() {
+				continue
+			}
+			psc = fromOTel(tsc)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if psc != nil {
+			sc, found = *psc, true
+		}
+	}
+	if !found {
+		sc.Err = b3.ErrEmptyContext
+		if len(errs) > 0 {
+			sc.Err = errors.Join(errs...)
+		}
+	}
+	return ctx, sc
+}
+
+// toOTel converts the zipkin span context
+// to the opentelemetry span context.
+func toOTel(sc model.SpanContext) trace.SpanContext {
+	var tid trace.TraceID
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(tid[:8], sc.TraceID.High)
+	binary.BigEndian.PutUint64(tid[8:], sc.TraceID.Low)
+	binary.BigEndian.PutUint64(sid[:], uint64(sc.ID))
+	var flags trace.TraceFlags
+	if sc.Debug || (sc.Sampled != nil && *sc.Sampled) {
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
+
+// fromOTel converts the opentelemetry span context
+// to the zipkin span context.
+func fromOTel(tsc trace.SpanContext) *model.SpanContext {
+	tid, sid := tsc.TraceID(), tsc.SpanID()
+	sampled := tsc.IsSampled()
+	return &model.SpanContext{
+		TraceID: model.TraceID{
+			High: binary.BigEndian.Uint64(tid[:8]),
+			Low:  binary.BigEndian.Uint64(tid[8:]),
+		},
+		ID:      model.ID(binary.BigEndian.Uint64(sid[:])),
+		Sampled: &sampled,
+	}
+}
@@ -0,0 +1,281 @@
+package zipkin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/go/znet/zhttp"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID128 = "0af7651916cd43dd8448eb211c80319c"
+	testTraceID64  = "8448eb211c80319c"
+	testSpanID     = "b7ad6b7169203331"
+	testOtherID    = "00f067aa0ba902b7"
+)
+
+func TestNewPropagator(t *testing.T) {
+	if _, err := newPropagator([]Format{FormatB3Single, "jaeger"}); err == nil {
+		t.Error("want an error for unsupported formats")
+	}
+	p, err := newPropagator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.extract) != 3 || p.extract[0] != FormatB3Single || len(p.inject) != 2 || p.inject[0] != FormatB3Multi {
+		t.Errorf("unexpected default formats %v %v", p.extract, p.inject)
+	}
+}
+
+func TestPropagator_Extract(t *testing.T) {
+	multi := map[string]string{
+		b3.TraceID: testTraceID64,
+		b3.SpanID:  testOtherID,
+		b3.Sampled: "1",
+	}
+	testCases := map[string]struct {
+		formats []Format
+		headers map[string]string
+		traceID string
+		spanID  string
+		sampled bool
+		err     error
+	}{
+		"b3 single over multi": {
+			headers: merge(multi, map[string]string{b3.Context: testTraceID128 + "-" + testSpanID + "-1"}),
+			traceID: testTraceID128, spanID: testSpanID, sampled: true,
+		},
+		"b3 multi": {
+			headers: multi,
+			traceID: testTraceID64, spanID: testOtherID, sampled: true,
+		},
+		"b3 multi unsampled": {
+			headers: merge(multi, map[string]string{b3.Sampled: "0"}),
+			traceID: testTraceID64, spanID: testOtherID,
+		},
+		"invalid b3 single falls back": {
+			headers: merge(multi, map[string]string{b3.Context: "invalid"}),
+			traceID: testTraceID64, spanID: testOtherID, sampled: true,
+		},
+		"invalid only": {
+			headers: map[string]string{b3.Context: "invalid"},
+			err:     b3.ErrInvalidTraceIDValue,
+		},
+		"traceparent not configured": {
+			headers: map[string]string{"traceparent": "00-" + testTraceID128 + "-" + testSpanID + "-01"},
+			err:     b3.ErrEmptyContext,
+		},
+		"traceparent over b3": {
+			formats: []Format{FormatTraceContext, FormatB3Multi},
+			headers: merge(multi, map[string]string{"traceparent": "00-" + testTraceID128 + "-" + testSpanID + "-01"}),
+			traceID: testTraceID128, spanID: testSpanID, sampled: true,
+		},
+		"b3 over traceparent": {
+			formats: []Format{FormatB3Multi, FormatTraceContext},
+			headers: merge(multi, map[string]string{"traceparent": "00-" + testTraceID128 + "-" + testSpanID + "-01"}),
+			traceID: testTraceID64, spanID: testOtherID, sampled: true,
+		},
+		"traceparent unsampled": {
+			formats: []Format{FormatTraceContext},
+			headers: map[string]string{"traceparent": "00-" + testTraceID128 + "-" + testSpanID + "-00"},
+			traceID: testTraceID128, spanID: testSpanID,
+		},
+		"traceparent 64 bits": {
+			formats: []Format{FormatTraceContext},
+			headers: map[string]string{"traceparent": "00-0000000000000000" + testTraceID64 + "-" + testSpanID + "-01"},
+			traceID: testTraceID64, spanID: testSpanID, sampled: true,
+		},
+		"invalid traceparent": {
+			formats: []Format{FormatTraceContext},
+			headers: map[string]string{"traceparent": "00-" + testTraceID128 + "-0000000000000000-01"},
+			err:     b3.ErrEmptyContext,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p, err := newPropagator(tc.formats)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, sc := p.Extract(context.Background(), tracing.MapCarrier(tc.headers))
+			if tc.err != nil {
+				if !errors.Is(sc.Err, tc.err) {
+					t.Errorf("want error %v, got %v", tc.err, sc.Err)
+				}
+				return
+			}
+			if sc.Err != nil {
+				t.Fatal(sc.Err)
+			}
+			sampled := sc.Sampled != nil && *sc.Sampled
+			if sc.TraceID.String() != tc.traceID || sc.ID.String() != tc.spanID || sampled != tc.sampled {
+				t.Errorf("want %s-%s-%v, got %s-%s-%v", tc.traceID, tc.spanID, tc.sampled, sc.TraceID, sc.ID, sampled)
+			}
+		})
+	}
+}
+
+func TestPropagator_Inject(t *testing.T) {
+	tid64, _ := model.TraceIDFromHex(testTraceID64)
+	tid128, _ := model.TraceIDFromHex(testTraceID128)
+	id, _ := strconv.ParseUint(testSpanID, 16, 64)
+	sid := model.ID(id)
+	sampled := true
+	testCases := map[string]struct {
+		formats []Format
+		sc      model.SpanContext
+		headers map[string]string
+	}{
+		"default": {
+			sc: model.SpanContext{TraceID: tid64, ID: sid, Sampled: &sampled},
+			headers: map[string]string{
+				b3.TraceID: testTraceID64,
+				b3.SpanID:  testSpanID,
+				b3.Sampled: "1",
+			},
+		},
+		"b3 single": {
+			formats: []Format{FormatB3Single},
+			sc:      model.SpanContext{TraceID: tid128, ID: sid, Sampled: &sampled},
+			headers: map[string]string{b3.Context: testTraceID128 + "-" + testSpanID + "-1"},
+		},
+		"traceparent 128 bits": {
+			formats: []Format{FormatTraceContext},
+			sc:      model.SpanContext{TraceID: tid128, ID: sid, Sampled: &sampled},
+			headers: map[string]string{"traceparent": "00-" + testTraceID128 + "-" + testSpanID + "-01"},
+		},
+		"traceparent 64 bits": {
+			formats: []Format{FormatTraceContext},
+			sc:      model.SpanContext{TraceID: tid64, ID: sid},
+			headers: map[string]string{"traceparent": "00-0000000000000000" + testTraceID64 + "-" + testSpanID + "-00"},
+		},
+		"traceparent debug": {
+			formats: []Format{FormatTraceContext},
+			sc:      model.SpanContext{TraceID: tid64, ID: sid, Debug: true},
+			headers: map[string]string{"traceparent": "00-0000000000000000" + testTraceID64 + "-" + testSpanID + "-01"},
+		},
+		"all formats": {
+			formats: []Format{FormatB3Single, FormatB3Multi, FormatTraceContext},
+			sc:      model.SpanContext{TraceID: tid128, ID: sid, Sampled: &sampled},
+			headers: map[string]string{
+				b3.Context:    testTraceID128 + "-" + testSpanID + "-1",
+				b3.TraceID:    testTraceID128,
+				b3.SpanID:     testSpanID,
+				b3.Sampled:    "1",
+				"traceparent": "00-" + testTraceID128 + "-" + testSpanID + "-01",
+			},
+		},
+		"invalid context": {
+			formats: []Format{FormatB3Single, FormatB3Multi, FormatTraceContext},
+			sc:      model.SpanContext{Sampled: &sampled},
+			headers: map[string]string{},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p, err := newPropagator(tc.formats)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := http.Header{}
+			p.Inject(context.Background(), tc.sc, tracing.HeaderCarrier(h))
+			if len(h) != len(tc.headers) {
+				t.Errorf("want headers %v, got %v", tc.headers, h)
+			}
+			for k, v := range tc.headers {
+				if got := h.Get(k); got != v {
+					t.Errorf("%s: want %q, got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestOTelConversion(t *testing.T) {
+	testCases := map[string]struct {
+		sc      model.SpanContext
+		traceID string
+		sampled bool
+	}{
+		"64 bits":  {model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2}, "00000000000000000000000000000001", false},
+		"128 bits": {model.SpanContext{TraceID: model.TraceID{High: 3, Low: 1}, ID: 2}, "00000000000000030000000000000001", false},
+		"debug":    {model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2, Debug: true}, "00000000000000000000000000000001", true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tsc := toOTel(tc.sc)
+			if !tsc.IsValid() || !tsc.IsRemote() || tsc.TraceID().String() != tc.traceID || tsc.SpanID().String() != "0000000000000002" || tsc.IsSampled() != tc.sampled {
+				t.Fatalf("unexpected otel span context %v", tsc)
+			}
+			sc := fromOTel(tsc)
+			if sc.TraceID != tc.sc.TraceID || sc.ID != tc.sc.ID || sc.Sampled == nil || *sc.Sampled != tc.sampled {
+				t.Errorf("want %v, got %v", tc.sc, sc)
+			}
+		})
+	}
+}
+
+func TestTraceContext_interop(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	pg := propagation.TraceContext{}
+
+	t.Run("otel to zipkin", func(t *testing.T) {
+		tr, rec := newRecordingTracer(t, &Config{Propagation: []Format{FormatTraceContext}})
+		ctx, span := tp.Tracer("test").Start(context.Background(), "client")
+		span.End()
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		pg.Inject(ctx, propagation.HeaderCarrier(r.Header))
+		tr.ServerMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), r)
+
+		spans := rec.Flush()
+		osc := span.SpanContext()
+		if len(spans) != 1 || spans[0].TraceID.String() != osc.TraceID().String() {
+			t.Fatalf("server span must join the otel trace %s: %+v", osc.TraceID(), spans)
+		}
+		if spans[0].ID.String() != osc.SpanID().String() || !spans[0].Shared {
+			t.Errorf("server span must share the otel span id %s: %+v", osc.SpanID(), spans[0])
+		}
+	})
+
+	t.Run("zipkin to otel", func(t *testing.T) {
+		tr, rec := newRecordingTracer(t, &Config{Propagation: []Format{FormatTraceContext}})
+		var tsc trace.SpanContext
+		rt := tr.ClientMiddleware(zhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			tsc = trace.SpanContextFromContext(pg.Extract(context.Background(), propagation.HeaderCarrier(r.Header)))
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}))
+		if _, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com/", nil)); err != nil {
+			t.Fatal(err)
+		}
+
+		spans := rec.Flush()
+		if len(spans) != 1 || !tsc.IsValid() || !tsc.IsSampled() {
+			t.Fatalf("otel must read the zipkin context %v: %+v", tsc, spans)
+		}
+		// 64 bits trace ids of zipkin are padded to 128 bits.
+		if tsc.TraceID().String() != "0000000000000000"+spans[0].TraceID.String() || tsc.SpanID().String() != spans[0].ID.String() {
+			t.Errorf("want %s-%s, got %s-%s", spans[0].TraceID, spans[0].ID, tsc.TraceID(), tsc.SpanID())
+		}
+	})
+}
+
+func merge(maps ...map[string]string) map[string]string {
+	m := map[string]string{}
+	for _, mm := range maps {
+		for k, v := range mm {
+			m[k] = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,105 @@
+package zipkin
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpcx"
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that traces unary RPCs.
+// Trace context is extracted from the incoming metadata in the formats of [Config.Propagation].
+func (t *Tracer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		span, ctx := t.grpcServerSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		finishGRPCSpan(span, grpcx.NewUnaryInfo(info.FullMethod, err, true))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that traces streaming RPCs.
+// Trace context is extracted from the incoming metadata in the formats of [Config.Propagation].
+func (t *Tracer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span, ctx := t.grpcServerSpan(ss.Context(), info.FullMethod)
+		ws := &grpcx.ServerStream{ServerStream: ss, Ctx: ctx}
+		err := handler(srv, ws)
+		finishGRPCSpan(span, grpcx.NewInfo(info.FullMethod, err, ws.Sent(), ws.Received()))
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that traces unary RPCs.
+// Trace context is injected into the outgoing metadata in the formats of [Config.Propagation].
+func (t *Tracer) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := t.grpcClientSpan(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishGRPCSpan(span, grpcx.NewUnaryInfo(method, err, false))
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor that traces streaming RPCs.
+// Trace context is injected into the outgoing metadata in the formats of [Config.Propagation].
+// Spans are finished when the stream ends.
+func (t *Tracer) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := t.grpcClientSpan(ctx, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishGRPCSpan(span, grpcx.NewInfo(method, err, 0, 0))
+			return nil, err
+		}
+		return grpcx.NewClientStream(cs, desc, func(err error, sent, received int64) {
+			finishGRPCSpan(span, grpcx.NewInfo(method, err, sent, received))
+		}), nil
+	}
+}
+
+func (t *Tracer) grpcServerSpan(ctx context.Context, fullMethod string) (zipkin.Span, context.Context) {
+	opts := []zipkin.SpanOption{zipkin.Kind(model.Server)}
+	if parent := zipkin.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, zipkin.Parent(parent.Context()))
+	} else {
+		var sc model.SpanContext
+		ctx, sc = t.prop.Extract(ctx, grpcx.MDCarrier(grpcx.IncomingMD(ctx)))
+		if sc.Err == nil {
+			opts = append(opts, zipkin.Parent(sc))
+		}
+	}
+	span := t.tracer.StartSpan("server+"+fullMethod, opts...)
+	t.tagBaggage(ctx, span)
+	return span, zipkin.NewContext(ctx, span)
+}
+
+func (t *Tracer) grpcClientSpan(ctx context.Context, fullMethod string) (zipkin.Span, context.Context) {
+	opts := []zipkin.SpanOption{zipkin.Kind(model.Client)}
+	if parent := zipkin.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, zipkin.Parent(parent.Context()))
+	}
+	span := t.tracer.StartSpan("client+"+fullMethod, opts...)
+	t.tagBaggage(ctx, span)
+	md := grpcx.OutgoingMD(ctx)
+	t.prop.Inject(ctx, span.Context(), grpcx.MDCarrier(md))
+	ctx = zipkin.NewContext(ctx, span)
+	return span, metadata.NewOutgoingContext(ctx, md)
+}
+
+func finishGRPCSpan(span zipkin.Span, info *grpcx.Info) {
+	span.Tag("rpc.system", "grpc")
+	span.Tag("rpc.service", info.Service)
+	span.Tag("rpc.method", info.Method)
+	span.Tag("rpc.grpc.status_code", info.Code)
+	span.Tag("rpc.messages_sent", strconv.FormatInt(info.Sent, 10))
+	span.Tag("rpc.messages_received", strconv.FormatInt(info.Received, 10))
+	if info.Err != nil {
+		zipkin.TagError.Set(span, info.Err.Error())
+	}
+	span.Finish()
+}
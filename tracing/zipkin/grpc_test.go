@@ -0,0 +1,85 @@
+package zipkin
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/internal/grpctest"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	for _, tc := range grpctest.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rec := recorder.NewReporter()
+			tr, err := New(&Config{Reporter: rec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tr.Finalize(context.Background())
+			srv, cli := grpctest.Start(t,
+				[]grpc.ServerOption{
+					grpc.UnaryInterceptor(tr.UnaryServerInterceptor()),
+					grpc.StreamInterceptor(tr.StreamServerInterceptor()),
+				},
+				[]grpc.DialOption{
+					grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor()),
+					grpc.WithStreamInterceptor(tr.StreamClientInterceptor()),
+				},
+			)
+			cli.Call(t, context.Background(), tc)
+
+			if len(srv.MD()[0].Get("x-b3-traceid")) == 0 {
+				t.Error("trace context must be propagated in the metadata")
+			}
+			spans := rec.Flush()
+			server, ok := findSpan(spans, "server+"+tc.FullMethod)
+			if !ok {
+				t.Fatal("server span not found")
+			}
+			client, ok := findSpan(spans, "client+"+tc.FullMethod)
+			if !ok {
+				t.Fatal("client span not found")
+			}
+			// Server spans share the span id of the client spans by default.
+			if server.ID != client.ID || !server.Shared || server.TraceID != client.TraceID {
+				t.Error("server span must share the client span")
+			}
+			checkGRPCSpan(t, server, tc, tc.ServerSent, tc.ServerReceived)
+			checkGRPCSpan(t, client, tc, tc.ClientSent, tc.ClientReceived)
+		})
+	}
+}
+
+func findSpan(spans []model.SpanModel, name string) (model.SpanModel, bool) {
+	for _, s := range spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return model.SpanModel{}, false
+}
+
+func checkGRPCSpan(t *testing.T, s model.SpanModel, tc grpctest.Case, sent, received int64) {
+	t.Helper()
+	want := map[string]string{
+		"rpc.system":            "grpc",
+		"rpc.service":           grpctest.Service,
+		"rpc.method":            tc.Method,
+		"rpc.grpc.status_code":  tc.Code.String(),
+		"rpc.messages_sent":     strconv.FormatInt(sent, 10),
+		"rpc.messages_received": strconv.FormatInt(received, 10),
+	}
+	for k, v := range want {
+		if s.Tags[k] != v {
+			t.Errorf("%s: want %s=%s, got %s", s.Name, k, v, s.Tags[k])
+		}
+	}
+	if _, ok := s.Tags["error"]; ok != (tc.Code != codes.OK) {
+		t.Errorf("%s: unexpected error tag %q", s.Name, s.Tags["error"])
+	}
+}
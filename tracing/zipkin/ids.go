@@ -0,0 +1,82 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/idgenerator"
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+var (
+	_ idgenerator.IDGenerator = &fixedIDGenerator{}
+)
+
+// fixedIDGenerator returns the ids set to it.
+// It is used to start spans with the ids created by another tracer.
+type fixedIDGenerator struct {
+	traceID model.TraceID
+	spanID  model.ID
+}
+
+func (g *fixedIDGenerator) SpanID(_ model.TraceID) model.ID {
+	return g.spanID
+}
+
+func (g *fixedIDGenerator) TraceID() model.TraceID {
+	return g.traceID
+}
+
+// joinTracer is the zipkin tracer that starts spans with given ids.
+type joinTracer struct {
+	mu     sync.Mutex
+	gen    *fixedIDGenerator
+	tracer *zipkin.Tracer
+}
+
+// joinSpan starts a span with the ids created by another tracer.
+// The ids in the returned context are consumed.
+func (t *Tracer) joinSpan(ctx context.Context, name string, ids tracing.SpanIDs, opts ...zipkin.SpanOption) (zipkin.Span, context.Context) {
+	sampled := ids.Sampled
+	sc := model.SpanContext{Sampled: &sampled}
+	if ids.ParentID != [8]byte{} {
+		sc.TraceID = model.TraceID{
+			High: binary.BigEndian.Uint64(ids.TraceID[:8]),
+			Low:  binary.BigEndian.Uint64(ids.TraceID[8:]),
+		}
+		sc.ID = model.ID(binary.BigEndian.Uint64(ids.ParentID[:]))
+	}
+	t.join.mu.Lock()
+	t.join.gen.traceID = model.TraceID{
+		High: binary.BigEndian.Uint64(ids.TraceID[:8]),
+		Low:  binary.BigEndian.Uint64(ids.TraceID[8:]),
+	}
+	t.join.gen.spanID = model.ID(binary.BigEndian.Uint64(ids.SpanID[:]))
+	span := t.join.tracer.StartSpan(name, append(opts, zipkin.Parent(sc))...)
+	t.join.mu.Unlock()
+	ctx = tracing.ContextWithSpanIDs(ctx, tracing.SpanIDs{})
+	return span, zipkin.NewContext(ctx, span)
+}
+
+// SpanIDs returns the ids of the current span in the ctx.
+func (t *Tracer) SpanIDs(ctx context.Context) (tracing.SpanIDs, bool) {
+	span := zipkin.SpanFromContext(ctx)
+	if span == nil {
+		return tracing.SpanIDs{}, false
+	}
+	sc := span.Context()
+	if sc.TraceID.Empty() || sc.ID == 0 {
+		return tracing.SpanIDs{}, false
+	}
+	ids := tracing.SpanIDs{Sampled: sc.Debug || (sc.Sampled != nil && *sc.Sampled)}
+	binary.BigEndian.PutUint64(ids.TraceID[:8], sc.TraceID.High)
+	binary.BigEndian.PutUint64(ids.TraceID[8:], sc.TraceID.Low)
+	binary.BigEndian.PutUint64(ids.SpanID[:], uint64(sc.ID))
+	if sc.ParentID != nil {
+		binary.BigEndian.PutUint64(ids.ParentID[:], uint64(*sc.ParentID))
+	}
+	return ids, true
+}
@@ -3,31 +3,74 @@ package zipkin
 import (
 	"context"
 	"errors"
+	"math/rand/v2"
 	"net/http"
-	"path"
-	"runtime"
 	"strconv"
+	"time"
 
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/aileron-projects/aileron-observability/recovery"
 	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/aileron-projects/aileron-observability/tracing/servicegraph"
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
 	"github.com/aileron-projects/go/znet/zhttp"
 	"github.com/aileron-projects/go/zx/zuid"
 	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
 	"github.com/openzipkin/zipkin-go/reporter"
 )
 
 var (
-	_ zhttp.ServerMiddleware = &Tracer{}
-	_ zhttp.ClientMiddleware = &Tracer{}
-	_ tracing.Tracer         = &Tracer{}
+	_ zhttp.ServerMiddleware  = &Tracer{}
+	_ zhttp.ClientMiddleware  = &Tracer{}
+	_ tracing.Tracer          = &Tracer{}
+	_ tracing.TraceMiddleware = &Tracer{}
+	_ tracing.IDTracer        = &Tracer{}
 )
 
 type Tracer struct {
 	// tracer is a zipkin tracer.
 	tracer   *zipkin.Tracer
 	reporter reporter.Reporter
+	// join starts spans with the ids
+	// created by another tracer.
+	join *joinTracer
+	// prop injects and extracts trace context
+	// in the formats of Config.Propagation.
+	prop *propagator
+	// baggageKeys is the baggage keys
+	// copied to the span tags.
+	baggageKeys []string
+	// monitor monitors the http reporter
+	// created from Config.HTTPEndpoint.
+	monitor *health.Monitor
+	// persist is the disk queue client
+	// created from Config.Persistence.
+	persist *PersistentClient
+	// sampler is the rule based sampler
+	// created from Config.Sampling.
+	sampler *sampling.Sampler
+	// spanMetrics records the metrics of finished spans
+	// given by Config.SpanMetrics. It may be nil.
+	spanMetrics *spanmetrics.Metrics
+	// serviceGraph records the service graph
+	// given by Config.ServiceGraph. It may be nil.
+	serviceGraph *servicegraph.Graph
 
-	addCaller bool
+	// caller captures the callers of root spans.
+	// It is nil if the caller is not added.
+	caller *tracing.CallerCapture
+	// spanName returns the names of http spans.
+	spanName tracing.SpanNameFunc
+	// recordPanics, if true, records panics
+	// in the server middleware.
+	recordPanics bool
+
+	// responseHeaders writes the trace id
+	// in the response headers if not nil.
+	responseHeaders *tracing.ResponseHeaderConfig
 
 	serverSpanHook func(span zipkin.Span, w http.ResponseWriter, r *http.Request)
 	clientSpanHook func(span zipkin.Span, w *http.Response, r *http.Request)
@@ -41,16 +84,25 @@ func (t *Tracer) ServerMiddleware(next http.Handler) http.Handler {
 		}
 		r = r.WithContext(context.WithValue(r.Context(), tracing.ServerCtxKey, c))
 
-		span, ctx := t.spanContext(r, "server+"+r.URL.Path)
+		var sampler *sampling.Sampler
+		if c == 1 {
+			sampler = t.sampler
+		}
+		name := t.spanName("server", r)
+		span, ctx := t.spanContext(r, name, model.Server, sampler)
 		defer span.Finish()
 		r = r.WithContext(ctx)
+		if t.recordPanics {
+			defer func() {
+				if v := recover(); v != nil {
+					recovery.Handle(v, func(p *recovery.Panic) { recordPanic(span, p) })
+				}
+			}()
+		}
 
-		if t.addCaller {
-			if ptr, file, _, ok := runtime.Caller(2); ok {
-				f, _ := runtime.CallersFrames([]uintptr{ptr}).Next()
-				span.Tag("caller.file", path.Base(path.Dir(file))+"/"+path.Base(file))
-				span.Tag("caller.func", f.Function)
-				span.Tag("caller.line", strconv.Itoa(f.Line))
+		if t.caller != nil && c == 1 { // Only for root span.
+			if cl, ok := t.caller.Capture(); ok {
+				setCaller(span, cl)
 			}
 		}
 
@@ -58,8 +110,17 @@ func (t *Tracer) ServerMiddleware(next http.Handler) http.Handler {
 			ww := zhttp.WrapResponseWriter(w)
 			w = ww
 			defer t.serverSpanHook(span, w, r)
+			if t.responseHeaders != nil {
+				ids, _ := t.SpanIDs(ctx)
+				var done func()
+				r, done = t.responseHeaders.Hook(ww, r, ids)
+				defer done()
+			}
 		}
 		next.ServeHTTP(w, r)
+		if n := t.spanName("server", r); n != name {
+			span.SetName(n) // Route may be matched by the handler.
+		}
 	})
 }
 
@@ -71,16 +132,15 @@ func (t *Tracer) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 		}
 		r = r.WithContext(context.WithValue(r.Context(), tracing.ClientCtxKey, c))
 
-		span, ctx := t.spanContext(r, "client+"+r.URL.Path)
+		span, ctx := t.spanContext(r, t.spanName("client", r), model.Client, nil)
 		defer span.Finish()
 		r = r.WithContext(ctx)
+		r.Header = cloneHeader(r.Header)
+		t.prop.Inject(ctx, span.Context(), tracing.HeaderCarrier(r.Header))
 
-		if t.addCaller {
-			if ptr, file, _, ok := runtime.Caller(2); ok {
-				f, _ := runtime.CallersFrames([]uintptr{ptr}).Next()
-				span.Tag("caller.file", path.Base(path.Dir(file))+"/"+path.Base(file))
-				span.Tag("caller.func", f.Function)
-				span.Tag("caller.line", strconv.Itoa(f.Line))
+		if t.caller != nil && c == 1 { // Only for root span.
+			if cl, ok := t.caller.Capture(); ok {
+				setCaller(span, cl)
 			}
 		}
 
@@ -92,20 +152,46 @@ func (t *Tracer) ClientMiddleware(next http.RoundTripper) http.RoundTripper {
 	})
 }
 
-// spanContext returns a new span.
-func (t *Tracer) spanContext(r *http.Request, name string) (zipkin.Span, context.Context) {
-	var span zipkin.Span
+// spanContext returns a new span of the kind.
+// If the sampler is not nil, the sampling decision of
+// a root span or a span with remote parent is made by the sampler.
+func (t *Tracer) spanContext(r *http.Request, name string, kind model.Kind, sampler *sampling.Sampler) (zipkin.Span, context.Context) {
 	ctx := r.Context()
+	opts := []zipkin.SpanOption{zipkin.Kind(kind)}
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		return t.joinSpan(ctx, name, ids, opts...)
+	}
+	var span zipkin.Span
 	if parent := zipkin.SpanFromContext(ctx); parent != nil {
-		span = t.tracer.StartSpan(name, zipkin.Parent(parent.Context()))
+		span = t.tracer.StartSpan(name, append(opts, zipkin.Parent(parent.Context()))...)
+	} else if sc, ok := remoteFromContext(ctx); ok {
+		span = t.tracer.StartSpan(name, append(opts, zipkin.Parent(sc))...)
 	} else {
-		sc := t.tracer.Extract(b3.ExtractHTTP(r))
-		if errors.Is(sc.Err, b3.ErrEmptyContext) {
-			span = t.tracer.StartSpan(name)
+		var sc model.SpanContext
+		ctx, sc = t.prop.Extract(ctx, tracing.HeaderCarrier(r.Header))
+		if sampler != nil {
+			if sc.Err != nil {
+				sc = model.SpanContext{} // Start a new trace.
+			}
+			decided := sc.Debug || sc.Sampled != nil
+			if sc.TraceID.Empty() || !decided || !sampler.ParentBased() {
+				id := sc.TraceID.Low
+				if sc.TraceID.Empty() {
+					id = rand.Uint64()
+				}
+				sampled := sampler.Sample(r, id)
+				sc.Sampled, sc.Debug = &sampled, false
+			}
+			// Root span is created with the sampling decision
+			// when the trace id is empty.
+			span = t.tracer.StartSpan(name, append(opts, zipkin.Parent(sc))...)
+		} else if errors.Is(sc.Err, b3.ErrEmptyContext) {
+			span = t.tracer.StartSpan(name, opts...)
 		} else {
-			span = t.tracer.StartSpan(name, zipkin.Parent(sc))
+			span = t.tracer.StartSpan(name, append(opts, zipkin.Parent(sc))...)
 		}
 	}
+	t.tagBaggage(ctx, span)
 	return span, zipkin.NewContext(ctx, span)
 }
 
@@ -114,20 +200,85 @@ func (t *Tracer) spanContext(r *http.Request, name string) (zipkin.Span, context
 // The returned function with finishes spans must be called when finishing spans.
 func (t *Tracer) Trace(ctx context.Context, name string, tags map[string]string) (spanCtx context.Context, finish func()) {
 	var span zipkin.Span
-	if parent := zipkin.SpanFromContext(ctx); parent != nil {
-		span = t.tracer.StartSpan(name, zipkin.Parent(parent.Context()))
+	if ids, ok := tracing.SpanIDsFromContext(ctx); ok {
+		span, spanCtx = t.joinSpan(ctx, name, ids)
 	} else {
-		span = t.tracer.StartSpan(name)
+		if parent := zipkin.SpanFromContext(ctx); parent != nil {
+			span = t.tracer.StartSpan(name, zipkin.Parent(parent.Context()))
+		} else if sc, ok := remoteFromContext(ctx); ok {
+			span = t.tracer.StartSpan(name, zipkin.Parent(sc))
+		} else {
+			span = t.tracer.StartSpan(name)
+		}
+		spanCtx = zipkin.NewContext(ctx, span)
 	}
+	t.tagBaggage(ctx, span)
 	for k, v := range tags {
 		span.Tag(k, v)
 	}
-	return zipkin.NewContext(ctx, span), span.Finish
+	return spanCtx, span.Finish
+}
+
+// Monitor returns the monitor of the http reporter created from [Config.HTTPEndpoint].
+// The monitor can be registered to a metrics backend to
+// publish the internal metrics of the reporter.
+func (t *Tracer) Monitor() *health.Monitor {
+	return t.monitor
+}
+
+// Sampler returns the rule based sampler configured by the Config.Sampling.
+// It returns nil if not configured.
+// The sampler can be registered to a metrics backend
+// to publish the sampling metrics.
+func (t *Tracer) Sampler() *sampling.Sampler {
+	return t.sampler
+}
+
+// SpanMetrics returns the span metrics given by the Config.SpanMetrics.
+// It returns nil if not configured.
+// The metrics can be registered to a metrics backend
+// to publish the request, error and duration metrics of spans.
+func (t *Tracer) SpanMetrics() *spanmetrics.Metrics {
+	return t.spanMetrics
+}
+
+// ServiceGraph returns the service graph given by the Config.ServiceGraph.
+// It returns nil if not configured.
+// The graph can be registered to a metrics backend
+// to publish the service graph metrics.
+func (t *Tracer) ServiceGraph() *servicegraph.Graph {
+	return t.serviceGraph
+}
+
+// Health returns the health status of the http reporter created from [Config.HTTPEndpoint].
+func (t *Tracer) Health() health.Status {
+	return t.monitor.Health()
 }
 
 // Finalize closes internal tracer flushing remained trace data.
 func (t *Tracer) Finalize(_ context.Context) error {
-	return t.reporter.Close()
+	err := t.reporter.Close()
+	if t.persist != nil {
+		err = errors.Join(err, t.persist.Close())
+	}
+	return err
+}
+
+// tagBaggage copies the baggage entries of the
+// configured keys in the ctx to the span tags.
+func (t *Tracer) tagBaggage(ctx context.Context, span zipkin.Span) {
+	for k, v := range tracing.SelectBaggage(ctx, t.baggageKeys) {
+		span.Tag(k, v)
+	}
+}
+
+// cloneHeader returns a copy of the h so that
+// client middleware do not modify the original request.
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return http.Header{}
+	}
+	return h.Clone()
 }
 
 func serverSpanHook(span zipkin.Span, w http.ResponseWriter, r *http.Request) {
@@ -152,6 +303,29 @@ func serverSpanHook(span zipkin.Span, w http.ResponseWriter, r *http.Request) {
 	span.Tag("http.response_content_length", strconv.FormatInt(ww.Written(), 10))
 }
 
+// setCaller sets the caller tags and the "caller.stack"
+// annotation and tag if the stack is available.
+func setCaller(span zipkin.Span, c *tracing.Caller) {
+	span.Tag("caller.file", c.File)
+	span.Tag("caller.func", c.Func)
+	span.Tag("caller.line", strconv.Itoa(c.Line))
+	if c.Stack != "" {
+		span.Annotate(time.Now(), "caller.stack")
+		span.Tag("caller.stack", c.Stack)
+	}
+}
+
+// recordPanic records the panic as the "exception" annotation
+// and tags and marks the span as an error with the status code 500.
+func recordPanic(span zipkin.Span, p *recovery.Panic) {
+	span.Annotate(time.Now(), "exception")
+	span.Tag("exception.type", p.Type())
+	span.Tag("exception.message", p.Message())
+	span.Tag("exception.stacktrace", string(p.Stack))
+	span.Tag("http.status_code", strconv.Itoa(http.StatusInternalServerError))
+	span.Tag(string(zipkin.TagError), p.Error())
+}
+
 func clientSpanHook(span zipkin.Span, w *http.Response, r *http.Request) {
 	if id := zuid.FromContext(r.Context(), "context"); id != "" {
 		span.Tag("context", id)
@@ -0,0 +1,119 @@
+package zipkin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+)
+
+var (
+	_ reporter.Reporter   = &monitoredReporter{}
+	_ zipkinhttp.HTTPDoer = &monitoredDoer{}
+	_ io.Writer           = &logWriter{}
+)
+
+// monitoredReporter counts spans sent to the reporter.
+type monitoredReporter struct {
+	reporter.Reporter
+	monitor *health.Monitor
+}
+
+func (r *monitoredReporter) Send(s model.SpanModel) {
+	r.monitor.Enqueued(1)
+	r.Reporter.Send(s)
+}
+
+// monitoredDoer records the results of requests
+// sent to the zipkin collector by the http reporter.
+type monitoredDoer struct {
+	next    zipkinhttp.HTTPDoer
+	monitor *health.Monitor
+	// persistent, if true, records retryable failures only
+	// as errors because the [PersistentClient] persists the
+	// spans and they are recorded when replayed.
+	persistent bool
+}
+
+func (d *monitoredDoer) Do(req *http.Request) (*http.Response, error) {
+	n := spanCount(req, d.monitor)
+	start := time.Now()
+	res, err := d.next.Do(req)
+	d.monitor.ObserveLatency(time.Since(start))
+	if err != nil {
+		// The http reporter retries sending the batch
+		// so the spans are not recorded as failed.
+		d.monitor.Error(err)
+		return res, err
+	}
+	replay := diskqueue.IsReplay(req.Context())
+	switch code := res.StatusCode; {
+	case code >= 200 && code <= 299 && replay:
+		d.monitor.Replayed(n, nil)
+	case code >= 200 && code <= 299:
+		d.monitor.Exported(n)
+	case d.persistent && retryable(code):
+		d.monitor.Error(fmt.Errorf("zipkin: collector responded with status code %d", code))
+	case replay:
+		d.monitor.Replayed(n, fmt.Errorf("zipkin: collector responded with status code %d", code))
+	default:
+		// The http reporter discards the batch.
+		d.monitor.Failed(n, fmt.Errorf("zipkin: collector responded with status code %d", code))
+	}
+	return res, nil
+}
+
+// spanCount returns the number of spans in the request.
+// It returns the queue length of the monitor
+// if the spans could not be counted.
+func spanCount(req *http.Request, m *health.Monitor) int {
+	if n := countSpans(req); n >= 0 {
+		return n
+	}
+	return m.QueueLength() // Unknown serializer.
+}
+
+// countSpans returns the number of spans in the JSON encoded request body.
+// It returns -1 if the body could not be read as JSON.
+func countSpans(req *http.Request) int {
+	if req.GetBody == nil {
+		return -1
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return -1
+	}
+	defer body.Close()
+	var spans []json.RawMessage
+	if err := json.NewDecoder(body).Decode(&spans); err != nil {
+		return -1
+	}
+	return len(spans)
+}
+
+// logWriter receives the logs of the http reporter
+// and records dropped spans and errors to the monitor.
+// Send failures are recorded by the monitoredDoer.
+type logWriter struct {
+	monitor *health.Monitor
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	msg := strings.TrimSpace(string(b))
+	var n int
+	if _, err := fmt.Sscanf(msg, "backlog too long, disposing %d spans", &n); err == nil {
+		w.monitor.Dropped(n)
+	} else if strings.HasPrefix(msg, "failed when ") {
+		w.monitor.Error(errors.New(msg)) // Serialization or request creation errors.
+	}
+	return len(b), nil
+}
@@ -0,0 +1,238 @@
+package zipkin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	"github.com/aileron-projects/aileron-observability/health"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+)
+
+var (
+	_ zipkinhttp.HTTPDoer = &PersistentClient{}
+)
+
+// PersistentClient is the http client for the zipkin http reporter
+// that stores requests to a disk queue when the collector is not available
+// and replays them in background until succeeded.
+// Requests failed with transport errors, 429 or 5xx status codes are persisted.
+// New requests are also persisted while the queue is not empty
+// so that spans are sent in order.
+// Persisted requests are reported to the reporter as accepted.
+// Only the Content-Type and Content-Encoding headers are persisted
+// so that credentials are not stored in the disk.
+// Other headers such as Authorization are taken from the latest
+// request sent through the client when replaying. Persisted requests
+// are therefore replayed after the first request is sent.
+// Use [NewPersistentClient] to create a new instance.
+type PersistentClient struct {
+	next    zipkinhttp.HTTPDoer
+	queue   *diskqueue.Queue
+	onError func(error)
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+	// monitor, if non-nil, records the persisted spans.
+	// Sent and replayed spans are recorded by the monitoredDoer.
+	monitor *health.Monitor
+
+	// header is the header of the latest request
+	// which is applied to replayed requests.
+	// ready is closed when the first request is received.
+	mu        sync.Mutex
+	header    http.Header
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewPersistentClient returns a new client that sends requests with the client.
+// If the client is nil, [http.DefaultClient] is used.
+// A background goroutine replays persisted requests until
+// the client is closed.
+// The client can be used with the [zipkinhttp.Client] option.
+func NewPersistentClient(client zipkinhttp.HTTPDoer, c *diskqueue.Config) (*PersistentClient, error) {
+	return newPersistentClient(client, c, nil)
+}
+
+// newPersistentClient returns a new client which records
+// the persisted spans to the monitor if non-nil.
+// The client must be the monitoredDoer of the monitor
+// so that the spans sent and replayed are recorded once.
+func newPersistentClient(client zipkinhttp.HTTPDoer, c *diskqueue.Config, m *health.Monitor) (*PersistentClient, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if m != nil {
+		client = &monitoredDoer{next: client, monitor: m, persistent: true}
+	}
+	q, err := diskqueue.Open(c)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &PersistentClient{
+		next:    client,
+		queue:   q,
+		onError: c.ErrorHandler,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		monitor: m,
+		ready:   make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		q.Replay(ctx, p.replay)
+	}()
+	return p, nil
+}
+
+// Queue returns the disk queue of the client.
+func (p *PersistentClient) Queue() *diskqueue.Queue {
+	return p.queue
+}
+
+// persistedHeaders is the headers stored in the disk.
+var persistedHeaders = []string{"Content-Type", "Content-Encoding"}
+
+// persistedRequest is the request stored in the disk.
+type persistedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body"`
+}
+
+func (p *PersistentClient) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.header = req.Header.Clone()
+	p.mu.Unlock()
+	p.readyOnce.Do(func() { close(p.ready) })
+	if p.queue.Len() == 0 {
+		res, err := p.next.Do(req)
+		if err == nil && !retryable(res.StatusCode) {
+			return res, nil
+		}
+		if err == nil {
+			res.Body.Close()
+			err = fmt.Errorf("zipkin: collector responded with status code %d", res.StatusCode)
+		}
+		if p.onError != nil {
+			p.onError(fmt.Errorf("zipkin: send failed and spans are persisted: %w", err))
+		}
+	}
+	header := http.Header{}
+	for _, k := range persistedHeaders {
+		if v := req.Header.Values(k); len(v) > 0 {
+			header[k] = v
+		}
+	}
+	b, err := json.Marshal(&persistedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: header,
+		Body:   body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.queue.Push(b); err != nil {
+		err = fmt.Errorf("zipkin: failed to persist spans: %w", err)
+		if p.monitor != nil {
+			// The http reporter keeps the batch and retries it.
+			p.monitor.Error(err)
+		}
+		return nil, err
+	}
+	if p.monitor != nil {
+		p.monitor.Persisted(spanCount(req, p.monitor))
+	}
+	return &http.Response{
+		Status:     http.StatusText(http.StatusAccepted),
+		StatusCode: http.StatusAccepted,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func (p *PersistentClient) replay(ctx context.Context, b []byte) error {
+	var pr persistedRequest
+	if err := json.Unmarshal(b, &pr); err != nil {
+		return nil // Broken entry. Discard it.
+	}
+	req, err := http.NewRequestWithContext(ctx, pr.Method, pr.URL, bytes.NewReader(pr.Body))
+	if err != nil {
+		return nil // Invalid entry. Discard it.
+	}
+	// Wait for the headers of the live requests.
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.mu.Lock()
+	req.Header = p.header.Clone()
+	p.mu.Unlock()
+	for _, k := range persistedHeaders {
+		req.Header.Del(k)
+		if v := pr.Header.Values(k); len(v) > 0 {
+			req.Header[k] = v
+		}
+	}
+	res, err := p.next.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if retryable(res.StatusCode) {
+		return fmt.Errorf("zipkin: collector responded with status code %d", res.StatusCode)
+	}
+	return nil // Other errors such as 4xx are not retried.
+}
+
+// Close stops replaying.
+// Requests which have not been replayed are kept in the disk
+// and will be replayed by the next client which uses the same directory.
+func (p *PersistentClient) Close() error {
+	p.once.Do(p.cancel)
+	<-p.done
+	return nil
+}
+
+// readBody reads the request body and
+// resets it so that it can be read again.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// retryable returns true if the request
+// should be retried for the status code.
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,183 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aileron-projects/aileron-observability/diskqueue"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyCollector is the zipkin collector which
+// replies with the status code while it is non-zero.
+type flakyCollector struct {
+	status atomic.Int32
+	mu     sync.Mutex
+	got    map[string]int
+}
+
+func (c *flakyCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if code := c.status.Load(); code != 0 {
+		w.WriteHeader(int(code))
+		return
+	}
+	var spans []struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range spans {
+		c.got[s.ID]++
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *flakyCollector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.got)
+}
+
+func TestPersistentClient_monitor(t *testing.T) {
+	testCases := map[string]struct {
+		// status is the status code replied while the collector is down.
+		status int
+		// replay is the status code replied to the replays.
+		replay int
+		// exported and failed are the number of spans
+		// recorded by the monitor.
+		exported, failed int
+	}{
+		"recovered":       {http.StatusServiceUnavailable, 0, 30, 0},
+		"rejected replay": {http.StatusServiceUnavailable, http.StatusBadRequest, 0, 30},
+		"not persisted":   {http.StatusBadRequest, 0, 0, 30},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			col := &flakyCollector{got: map[string]int{}}
+			col.status.Store(int32(tc.status))
+			svr := httptest.NewServer(col)
+			defer svr.Close()
+			tr, err := New(&Config{
+				HTTPEndpoint: svr.URL,
+				HTTPOpts:     []zipkinhttp.ReporterOption{zipkinhttp.BatchSize(10)},
+				Persistence: &diskqueue.Config{
+					Dir:        t.TempDir(),
+					Fsync:      diskqueue.FsyncNever,
+					MinBackoff: 10 * time.Millisecond,
+					MaxBackoff: 10 * time.Millisecond,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tr.Finalize(context.Background())
+
+			for range 30 {
+				tr.tracer.StartSpan("test").Finish()
+			}
+			// Wait for the spans to be persisted or failed.
+			deadline := time.Now().Add(5 * time.Second)
+			for tr.Monitor().QueueLength() > 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			col.status.Store(int32(tc.replay))
+			for tr.persist.Queue().Len() > 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond) // Make sure nothing is sent twice.
+
+			if tc.exported > 0 && col.count() != tc.exported {
+				t.Errorf("want %d spans delivered, got %d", tc.exported, col.count())
+			}
+			want := `
+# HELP observability_exporter_exported_total Number of exported items
+# TYPE observability_exporter_exported_total counter
+observability_exporter_exported_total{exporter="zipkin",signal="spans"} ` + strconv.Itoa(tc.exported) + `
+# HELP observability_exporter_failed_total Number of items failed to export
+# TYPE observability_exporter_failed_total counter
+observability_exporter_failed_total{exporter="zipkin",signal="spans"} ` + strconv.Itoa(tc.failed) + `
+`
+			err = testutil.CollectAndCompare(tr.Monitor(), strings.NewReader(want),
+				"observability_exporter_exported_total", "observability_exporter_failed_total")
+			if err != nil {
+				t.Error(err)
+			}
+			if tr.Monitor().QueueLength() != 0 {
+				t.Errorf("unexpected queue length %d", tr.Monitor().QueueLength())
+			}
+		})
+	}
+}
+
+func TestPersistentClient_header(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	replayed := make(chan http.Header, 10)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// Requests are replayed while the queue is not empty.
+		replayed <- r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer svr.Close()
+	pc, err := NewPersistentClient(nil, &diskqueue.Config{
+		Dir:        t.TempDir(),
+		Fsync:      diskqueue.FsyncNever,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	send := func(token string) {
+		req, _ := http.NewRequest(http.MethodPost, svr.URL, strings.NewReader("[]"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := pc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	send("old-secret")
+	data, _, ok, err := pc.Queue().Peek()
+	if err != nil || !ok {
+		t.Fatalf("request must be persisted: %v", err)
+	}
+	if strings.Contains(string(data), "old-secret") || !strings.Contains(string(data), "application/json") {
+		t.Errorf("only content headers must be persisted: %s", data)
+	}
+
+	down.Store(false)
+	send("new-secret")
+	select {
+	case h := <-replayed:
+		if got := h.Get("Authorization"); got != "Bearer new-secret" {
+			t.Errorf("want the credential of the live request, got %q", got)
+		}
+		if got := h.Get("Content-Type"); got != "application/json" {
+			t.Errorf("want the persisted content type, got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not replayed")
+	}
+}
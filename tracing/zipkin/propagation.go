@@ -0,0 +1,91 @@
+package zipkin
+
+import (
+	"context"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+var (
+	_ tracing.Propagator    = &Tracer{}
+	_ tracing.MessageTracer = &Tracer{}
+)
+
+type remoteKey struct{}
+
+// remoteFromContext returns the remote span context saved by [Tracer.Extract].
+func remoteFromContext(ctx context.Context) (model.SpanContext, bool) {
+	sc, ok := ctx.Value(remoteKey{}).(model.SpanContext)
+	return sc, ok
+}
+
+// Inject injects the trace context of the current span in the ctx
+// into the carrier in the formats of [Config.Propagation].
+func (t *Tracer) Inject(ctx context.Context, carrier tracing.Carrier) {
+	var sc model.SpanContext
+	if span := zipkin.SpanFromContext(ctx); span != nil {
+		sc = span.Context()
+	}
+	t.prop.Inject(ctx, sc, carrier)
+}
+
+// Extract extracts the trace context from the carrier
+// in the formats of [Config.Propagation].
+// Spans started by the tracer with the returned context
+// become children of the extracted span context.
+func (t *Tracer) Extract(ctx context.Context, carrier tracing.Carrier) context.Context {
+	ctx, sc := t.prop.Extract(ctx, carrier)
+	if sc.Err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// StartProducer starts a producer span and injects
+// its trace context into the carrier.
+func (t *Tracer) StartProducer(ctx context.Context, name string, carrier tracing.Carrier, tags map[string]string) (spanCtx context.Context, finish func()) {
+	opts := []zipkin.SpanOption{zipkin.Kind(model.Producer)}
+	if parent := zipkin.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, zipkin.Parent(parent.Context()))
+	} else if sc, ok := remoteFromContext(ctx); ok {
+		opts = append(opts, zipkin.Parent(sc))
+	}
+	span := t.tracer.StartSpan(name, opts...)
+	t.tagBaggage(ctx, span)
+	for k, v := range tags {
+		span.Tag(k, v)
+	}
+	spanCtx = zipkin.NewContext(ctx, span)
+	t.prop.Inject(spanCtx, span.Context(), carrier)
+	return spanCtx, span.Finish
+}
+
+// StartConsumer starts a consumer span for the message
+// which has the carrier as its headers.
+// Zipkin does not support links so the ids of the producer span
+// are added as "link.trace_id" and "link.span_id" tags
+// when the span has another parent.
+func (t *Tracer) StartConsumer(ctx context.Context, name string, carrier tracing.Carrier, tags map[string]string) (spanCtx context.Context, finish func()) {
+	opts := []zipkin.SpanOption{zipkin.Kind(model.Consumer)}
+	rctx, remote := t.prop.Extract(ctx, carrier)
+	ok := remote.Err == nil
+	parent := zipkin.SpanFromContext(ctx)
+	if parent != nil {
+		opts = append(opts, zipkin.Parent(parent.Context()))
+	} else if ok {
+		ctx = rctx
+		opts = append(opts, zipkin.Parent(remote))
+	}
+	span := t.tracer.StartSpan(name, opts...)
+	t.tagBaggage(ctx, span)
+	if parent != nil && ok {
+		span.Tag("link.trace_id", remote.TraceID.String())
+		span.Tag("link.span_id", remote.ID.String())
+	}
+	for k, v := range tags {
+		span.Tag(k, v)
+	}
+	return zipkin.NewContext(ctx, span), span.Finish
+}
@@ -0,0 +1,110 @@
+package zipkin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aileron-projects/aileron-observability/tracing"
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+// newRecordingTracer returns a new tracer created from the c
+// whose spans are reported to the returned recorder.
+func newRecordingTracer(t *testing.T, c *Config) (*Tracer, *recorder.ReporterRecorder) {
+	t.Helper()
+	rec := recorder.NewReporter()
+	c.Reporter = rec
+	tr, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tr.Finalize(context.Background()) })
+	return tr, rec
+}
+
+func TestTracer_InjectExtract(t *testing.T) {
+	testCases := map[string]tracing.Carrier{
+		"map":   tracing.MapCarrier{},
+		"bytes": &tracing.ByteHeaders{},
+	}
+	tr, _ := newRecordingTracer(t, &Config{})
+	span := tr.tracer.StartSpan("parent")
+	defer span.Finish()
+	ctx := zipkin.NewContext(context.Background(), span)
+	for name, carrier := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tr.Inject(ctx, carrier)
+			sc, ok := remoteFromContext(tr.Extract(context.Background(), carrier))
+			want := span.Context()
+			if !ok || sc.TraceID != want.TraceID || sc.ID != want.ID {
+				t.Errorf("want %v, got %v", want, sc)
+			}
+		})
+	}
+}
+
+func TestTracer_StartConsumer(t *testing.T) {
+	testCases := map[string]struct {
+		produce bool
+		parent  bool
+	}{
+		"child of producer":  {produce: true},
+		"link tags":          {produce: true, parent: true},
+		"no producer":        {},
+		"parent no producer": {parent: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tr, rec := newRecordingTracer(t, &Config{})
+			carrier := &tracing.ByteHeaders{}
+			var producer model.SpanContext
+			if tc.produce {
+				ctx, finish := tr.StartProducer(context.Background(), "send", carrier, map[string]string{"topic": "orders"})
+				producer = zipkin.SpanFromContext(ctx).Context()
+				finish()
+			}
+			ctx := context.Background()
+			var parent model.SpanContext
+			if tc.parent {
+				span := tr.tracer.StartSpan("poll")
+				defer span.Finish()
+				ctx = zipkin.NewContext(ctx, span)
+				parent = span.Context()
+			}
+			_, finish := tr.StartConsumer(ctx, "receive", carrier, map[string]string{"topic": "orders"})
+			finish()
+
+			spans := rec.Flush()
+			s := spans[len(spans)-1]
+			if s.Kind != model.Consumer || s.Tags["topic"] != "orders" {
+				t.Errorf("unexpected consumer span %v %v", s.Kind, s.Tags)
+			}
+			if tc.produce && (spans[0].Kind != model.Producer || len(carrier.Keys()) == 0) {
+				t.Errorf("producer must inject its trace context: %v", carrier)
+			}
+			switch {
+			case tc.parent:
+				if s.ParentID == nil || *s.ParentID != parent.ID || s.TraceID != parent.TraceID {
+					t.Errorf("want the local parent %v, got %v", parent, s.SpanContext)
+				}
+			case tc.produce:
+				if s.ParentID == nil || *s.ParentID != producer.ID || s.TraceID != producer.TraceID {
+					t.Errorf("want the producer parent %v, got %v", producer, s.SpanContext)
+				}
+			default:
+				if s.ParentID != nil {
+					t.Errorf("want a root span, got %v", s.SpanContext)
+				}
+			}
+			if tc.produce && tc.parent {
+				if s.Tags["link.trace_id"] != producer.TraceID.String() || s.Tags["link.span_id"] != producer.ID.String() {
+					t.Errorf("want link tags of the producer, got %v", s.Tags)
+				}
+			} else if _, ok := s.Tags["link.span_id"]; ok {
+				t.Errorf("want no link tags, got %v", s.Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package zipkin
+
+import (
+	"github.com/aileron-projects/aileron-observability/redact"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+var (
+	_ reporter.Reporter = &RedactingReporter{}
+)
+
+// RedactingReporter redacts the spans with the [redact.Redactor]
+// and then sends them to the next reporter.
+// Span names are redacted with [redact.Redactor.Path] and tags are
+// redacted with [redact.Redactor.Attribute]. Annotations and the
+// service name of the remote endpoint are redacted with [redact.Redactor.String].
+// Addresses of endpoints are not redacted.
+// Use [NewRedactingReporter] to create a new instance.
+type RedactingReporter struct {
+	next     reporter.Reporter
+	redactor *redact.Redactor
+}
+
+// NewRedactingReporter returns a new reporter that
+// redacts the spans and sends them to the next.
+func NewRedactingReporter(next reporter.Reporter, r *redact.Redactor) *RedactingReporter {
+	return &RedactingReporter{next: next, redactor: r}
+}
+
+func (r *RedactingReporter) Send(s model.SpanModel) {
+	s.Name = r.redactor.Path(s.Name)
+	if len(s.Tags) > 0 {
+		tags := make(map[string]string, len(s.Tags))
+		for k, v := range s.Tags {
+			if v, ok := r.redactor.Attribute(k, v); ok {
+				tags[k] = v
+			}
+		}
+		s.Tags = tags
+	}
+	if len(s.Annotations) > 0 {
+		annotations := make([]model.Annotation, 0, len(s.Annotations))
+		for _, a := range s.Annotations {
+			if v, ok := r.redactor.String(a.Value); ok {
+				a.Value = v
+				annotations = append(annotations, a)
+			}
+		}
+		s.Annotations = annotations
+	}
+	if s.RemoteEndpoint != nil {
+		ep := *s.RemoteEndpoint
+		ep.ServiceName, _ = r.redactor.String(ep.ServiceName)
+		s.RemoteEndpoint = &ep
+	}
+	r.next.Send(s)
+}
+
+func (r *RedactingReporter) Close() error {
+	return r.next.Close()
+}
@@ -0,0 +1,16 @@
+package zipkin
+
+import (
+	"github.com/aileron-projects/aileron-observability/tracing/sampling"
+	"github.com/openzipkin/zipkin-go"
+)
+
+// RuleSampler returns a zipkin sampler that makes decisions using the s.
+// Rules which require requests are not applied because zipkin samplers
+// only receive trace ids. Server spans are sampled with rules
+// by the [Tracer.ServerMiddleware] instead.
+func RuleSampler(s *sampling.Sampler) zipkin.Sampler {
+	return func(id uint64) bool {
+		return s.Sample(nil, id)
+	}
+}
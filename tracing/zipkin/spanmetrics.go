@@ -0,0 +1,96 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/aileron-projects/aileron-observability/tracing/spanmetrics"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+var (
+	_ reporter.Reporter = &SpanMetricsReporter{}
+)
+
+// SpanMetricsReporter records the spans sent to the reporter to the
+// recorders such as the [spanmetrics.Metrics] and the [servicegraph.Graph]
+// and then sends them to the next reporter.
+// Spans having the "error" tag are recorded as errors.
+// Spans having the "http.status_code" tag of an error status
+// are also recorded as errors. See [spanmetrics.HTTPStatus].
+// Only the sampled spans are sent to reporters
+// so spans not sampled are not counted.
+// Use [NewSpanMetricsReporter] to create a new instance.
+type SpanMetricsReporter struct {
+	next      reporter.Reporter
+	recorders []spanmetrics.Recorder
+}
+
+// NewSpanMetricsReporter returns a new reporter that
+// records the spans to the recorders and sends them to the next.
+func NewSpanMetricsReporter(next reporter.Reporter, rs ...spanmetrics.Recorder) *SpanMetricsReporter {
+	return &SpanMetricsReporter{next: next, recorders: rs}
+}
+
+func (r *SpanMetricsReporter) Send(s model.SpanModel) {
+	service := ""
+	if s.LocalEndpoint != nil {
+		service = s.LocalEndpoint.ServiceName
+	}
+	kind := spanKinds[s.Kind]
+	status := spanmetrics.StatusUnset
+	if _, ok := s.Tags["error"]; ok {
+		status = spanmetrics.StatusError
+	} else if v, ok := s.Tags["http.status_code"]; ok {
+		code, _ := strconv.Atoi(v)
+		status = spanmetrics.HTTPStatus(kind, code)
+	}
+	peer := ""
+	if s.RemoteEndpoint != nil {
+		peer = s.RemoteEndpoint.ServiceName
+	}
+	for _, k := range spanmetrics.PeerKeys {
+		if peer != "" {
+			break
+		}
+		peer = s.Tags[k]
+	}
+	span := &spanmetrics.Span{
+		Service:  service,
+		Name:     s.Name,
+		Kind:     kind,
+		Status:   status,
+		Duration: s.Duration,
+		Peer:     peer,
+	}
+	binary.BigEndian.PutUint64(span.IDs.TraceID[:8], s.TraceID.High)
+	binary.BigEndian.PutUint64(span.IDs.TraceID[8:], s.TraceID.Low)
+	binary.BigEndian.PutUint64(span.IDs.SpanID[:], uint64(s.ID))
+	if s.Shared {
+		// Shared server spans have the same id as the client span.
+		// Use it as the parent so that the server span is paired
+		// with the client span in the same way as other backends.
+		binary.BigEndian.PutUint64(span.IDs.ParentID[:], uint64(s.ID))
+	} else if s.ParentID != nil {
+		binary.BigEndian.PutUint64(span.IDs.ParentID[:], uint64(*s.ParentID))
+	}
+	span.IDs.Sampled = s.Sampled != nil && *s.Sampled
+	for _, rec := range r.recorders {
+		rec.Record(context.Background(), span)
+	}
+	r.next.Send(s)
+}
+
+func (r *SpanMetricsReporter) Close() error {
+	return r.next.Close()
+}
+
+var spanKinds = map[model.Kind]string{
+	model.Undetermined: spanmetrics.KindInternal,
+	model.Server:       spanmetrics.KindServer,
+	model.Client:       spanmetrics.KindClient,
+	model.Producer:     spanmetrics.KindProducer,
+	model.Consumer:     spanmetrics.KindConsumer,
+}